@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	authorizationV1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// AccessCache keeps the results of the SelfSubjectAccessReviews of callers for a while,
+// so endpoints polled by the dashboard, e.g. metrics, don't create reviews on every request
+type AccessCache struct {
+	ttl time.Duration
+
+	mu         sync.Mutex
+	entries    map[accessKey]accessEntry
+	lastPruned time.Time
+}
+
+// callers are identified by a hash of their credentials, the credentials are not kept
+type accessKey struct {
+	caller     string
+	attributes authorizationV1.ResourceAttributes
+}
+
+type accessEntry struct {
+	allowed bool
+	expires time.Time
+}
+
+func NewAccessCache(ttl time.Duration) *AccessCache {
+	return &AccessCache{
+		ttl:     ttl,
+		entries: make(map[accessKey]accessEntry),
+	}
+}
+
+// Can is Can of the caller of the config, cached. Errors are not cached.
+func (a *AccessCache) Can(config *rest.Config, client *kubernetes.Clientset, attributes authorizationV1.ResourceAttributes) (bool, error) {
+	caller, ok := callerOf(config)
+	if !ok {
+		return Can(client, attributes)
+	}
+
+	return a.can(caller, attributes, time.Now(), func() (bool, error) {
+		return Can(client, attributes)
+	})
+}
+
+func (a *AccessCache) can(caller string, attributes authorizationV1.ResourceAttributes, now time.Time, review func() (bool, error)) (bool, error) {
+	key := accessKey{caller: caller, attributes: attributes}
+
+	a.mu.Lock()
+	entry, exist := a.entries[key]
+	a.mu.Unlock()
+
+	if exist && now.Before(entry.expires) {
+		return entry.allowed, nil
+	}
+
+	// the lock is not held during the review, concurrent misses of a key may review it more than once
+	allowed, err := review()
+	if err != nil {
+		return false, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries[key] = accessEntry{allowed: allowed, expires: now.Add(a.ttl)}
+
+	// entries of callers who are gone are removed once in a while
+	if now.Sub(a.lastPruned) > a.ttl {
+		for k, e := range a.entries {
+			if !now.Before(e.expires) {
+				delete(a.entries, k)
+			}
+		}
+
+		a.lastPruned = now
+	}
+
+	return allowed, nil
+}
+
+// callerOf identifies the caller of the config by a hash of its credentials and who it impersonates.
+// Credentials of auth providers and exec plugins are not in the config, so callers using them,
+// or without any credentials, can't be told apart and are not cached.
+func callerOf(config *rest.Config) (string, bool) {
+	if config.AuthProvider != nil || config.ExecProvider != nil {
+		return "", false
+	}
+
+	if config.BearerToken == "" && config.BearerTokenFile == "" && config.Username == "" && config.Password == "" &&
+		len(config.CertData) == 0 && config.CertFile == "" {
+		return "", false
+	}
+
+	h := sha256.New()
+
+	credentials := []string{
+		config.BearerToken, config.BearerTokenFile, config.Username, config.Password,
+		string(config.CertData), config.CertFile, config.Impersonate.UserName,
+	}
+	credentials = append(credentials, config.Impersonate.Groups...)
+
+	extraKeys := make([]string, 0, len(config.Impersonate.Extra))
+	for k := range config.Impersonate.Extra {
+		extraKeys = append(extraKeys, k)
+	}
+	sort.Strings(extraKeys)
+
+	for _, k := range extraKeys {
+		credentials = append(credentials, k)
+		credentials = append(credentials, config.Impersonate.Extra[k]...)
+	}
+
+	for _, credential := range credentials {
+		h.Write([]byte(credential))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), true
+}
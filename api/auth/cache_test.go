@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestAccessCache(t *testing.T) {
+	cache := NewAccessCache(time.Minute)
+	attributes := authorizationV1.ResourceAttributes{Namespace: "kapp-a", Group: "metrics.k8s.io", Resource: "pods", Verb: "list"}
+	alice, _ := callerOf(&rest.Config{BearerToken: "alice"})
+	bob, _ := callerOf(&rest.Config{BearerToken: "bob"})
+	now := time.Now()
+
+	reviews := 0
+	review := func(allowed bool) func() (bool, error) {
+		return func() (bool, error) {
+			reviews++
+			return allowed, nil
+		}
+	}
+
+	allowed, err := cache.can(alice, attributes, now, review(true))
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+
+	// cached for the caller and the attributes
+	allowed, _ = cache.can(alice, attributes, now.Add(30*time.Second), review(false))
+	assert.True(t, allowed)
+	assert.Equal(t, 1, reviews)
+
+	allowed, _ = cache.can(bob, attributes, now, review(false))
+	assert.False(t, allowed)
+
+	other := attributes
+	other.Namespace = "kapp-b"
+	allowed, _ = cache.can(alice, other, now, review(false))
+	assert.False(t, allowed)
+	assert.Equal(t, 3, reviews)
+
+	// reviewed again once expired, and expired entries are pruned
+	allowed, _ = cache.can(alice, attributes, now.Add(2*time.Minute), review(false))
+	assert.False(t, allowed)
+	assert.Equal(t, 4, reviews)
+	assert.Len(t, cache.entries, 1)
+
+	// errors are not cached
+	_, err = cache.can(bob, other, now, func() (bool, error) { return false, errors.New("unavailable") })
+	assert.NotNil(t, err)
+	allowed, _ = cache.can(bob, other, now, review(true))
+	assert.True(t, allowed)
+
+	assert.NotEqual(t, alice, bob)
+}
+
+func TestCallerOf(t *testing.T) {
+	alice, ok := callerOf(&rest.Config{BearerToken: "alice"})
+	assert.True(t, ok)
+
+	impersonated, ok := callerOf(&rest.Config{BearerToken: "alice", Impersonate: rest.ImpersonationConfig{UserName: "bob"}})
+	assert.True(t, ok)
+	assert.NotEqual(t, alice, impersonated)
+
+	// nothing in the config tells callers of auth providers, exec plugins, or without credentials apart
+	_, ok = callerOf(&rest.Config{AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "oidc"}})
+	assert.False(t, ok)
+
+	_, ok = callerOf(&rest.Config{ExecProvider: &clientcmdapi.ExecConfig{Command: "aws"}})
+	assert.False(t, ok)
+
+	_, ok = callerOf(&rest.Config{})
+	assert.False(t, ok)
+}
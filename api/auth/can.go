@@ -1,12 +1,21 @@
 package auth
 
 import (
+	authorizationV1 "k8s.io/api/authorization/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-func xxx(client *kubernetes.Clientset) {
-	//client.AuthorizationV1().SelfSubjectAccessReviews()
-	//v1.SelfSubjectAccessReview{}
-	//v1.SelfSubjectRulesReview{}
+// Can checks if the owner of the client is allowed to access the resource, with a SelfSubjectAccessReview
+func Can(client *kubernetes.Clientset, attributes authorizationV1.ResourceAttributes) (bool, error) {
+	review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationV1.SelfSubjectAccessReview{
+		Spec: authorizationV1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+		},
+	})
 
+	if err != nil {
+		return false, err
+	}
+
+	return review.Status.Allowed, nil
 }
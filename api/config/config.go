@@ -6,6 +6,7 @@ import (
 	"github.com/urfave/cli/v2"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type Config struct {
@@ -16,6 +17,9 @@ type Config struct {
 	KubernetesApiServerCAFilePath string
 	KubeConfigPath                string
 	CorsAllowedOrigins            cli.StringSlice
+	MetricsRetention              time.Duration
+	MetricsSnapshotPath           string
+	MetricsSnapshotInterval       time.Duration
 }
 
 func fileExists(filename string) bool {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/kapp-staging/kapp/api/errors"
 	"github.com/kapp-staging/kapp/api/resources"
 	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
 	"github.com/labstack/echo/v4"
@@ -39,6 +40,32 @@ func (h *ApiHandler) handleGetApplicationDetails(c echo.Context) error {
 		return err
 	}
 
+	res.Monitoring = h.applicationMonitoring(application)
+
+	return c.JSON(200, res)
+}
+
+// preview the application spec with an overlay applied, the overlay is given by query param,
+// or the one currently in use if it's absent
+func (h *ApiHandler) handleGetResolvedApplication(c echo.Context) error {
+	application, err := getKappApplication(c)
+
+	if err != nil {
+		return err
+	}
+
+	overlay := application.Spec.Overlay
+
+	if params := c.QueryParams(); params["overlay"] != nil {
+		overlay = c.QueryParam("overlay")
+	}
+
+	res, err := resources.BuildResolvedApplication(application, overlay)
+
+	if err != nil {
+		return errors.NewBadRequest(err.Error())
+	}
+
 	return c.JSON(200, res)
 }
 
@@ -103,6 +130,14 @@ func createKappApplication(c echo.Context) (*v1alpha1.Application, error) {
 		return nil, err
 	}
 
+	if err := resources.CheckLinkedEnvsAccess(k8sClient, crdApplication); err != nil {
+		return nil, err
+	}
+
+	if err := resources.CheckApplicationQuota(k8sClient, crdApplication, nil); err != nil {
+		return nil, err
+	}
+
 	bts, _ := json.Marshal(crdApplication)
 	var application v1alpha1.Application
 	err = k8sClient.RESTClient().Post().Body(bts).AbsPath(kappApplicationUrl(c)).Do().Into(&application)
@@ -121,6 +156,10 @@ func updateKappApplication(c echo.Context) (*v1alpha1.Application, error) {
 		return nil, err
 	}
 
+	if err := resources.CheckLinkedEnvsAccess(k8sClient, crdApplication); err != nil {
+		return nil, err
+	}
+
 	fetched, err := getKappApplication(c)
 
 	if err != nil {
@@ -128,6 +167,10 @@ func updateKappApplication(c echo.Context) (*v1alpha1.Application, error) {
 	}
 	crdApplication.ResourceVersion = fetched.ResourceVersion
 
+	if err := resources.CheckApplicationQuota(k8sClient, crdApplication, fetched); err != nil {
+		return nil, err
+	}
+
 	bts, _ := json.Marshal(crdApplication)
 	var application v1alpha1.Application
 	err = k8sClient.RESTClient().Put().Body(bts).AbsPath(kappApplicationUrl(c)).Do().Into(&application)
@@ -194,6 +237,8 @@ func getApplicationFromContext(c echo.Context) (*v1alpha1.Application, error) {
 			IsActive:   req.Application.IsActive,
 			SharedEnv:  req.Application.SharedEnvs,
 			Components: req.Application.Components,
+			Overlays:   req.Application.Overlays,
+			Overlay:    req.Application.Overlay,
 		},
 	}
 
@@ -201,26 +246,18 @@ func getApplicationFromContext(c echo.Context) (*v1alpha1.Application, error) {
 }
 
 func (h *ApiHandler) applicationResponse(c echo.Context, application *v1alpha1.Application) (*resources.ApplicationDetails, error) {
-	k8sClient := getK8sClient(c)
-	k8sClientConfig := getK8sClientConfig(c)
-
-	builder := resources.Builder{
-		K8sClient: k8sClient,
-		Logger:    h.logger,
-		Config:    k8sClientConfig,
+	builder, err := h.applicationBuilder(c)
+	if err != nil {
+		return nil, err
 	}
 
 	return builder.BuildApplicationDetails(application)
 }
 
 func (h *ApiHandler) applicationListResponse(c echo.Context, applicationList *v1alpha1.ApplicationList) ([]resources.ApplicationDetails, error) {
-	k8sClient := getK8sClient(c)
-	k8sClientConfig := getK8sClientConfig(c)
-
-	builder := resources.Builder{
-		K8sClient: k8sClient,
-		Logger:    h.logger,
-		Config:    k8sClientConfig,
+	builder, err := h.applicationBuilder(c)
+	if err != nil {
+		return nil, err
 	}
 
 	return builder.BuildApplicationListResponse(applicationList)
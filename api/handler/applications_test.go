@@ -148,6 +148,59 @@ func (suite *ApplicationsHandlerTestSuite) TestDeleteAppliation() {
 	suite.Equal(http.StatusNoContent, rec.Code)
 }
 
+func (suite *ApplicationsHandlerTestSuite) TestResolvedApplication() {
+	body := `{
+  "application": {
+    "name": "test4",
+    "namespace": "test4",
+    "sharedEnvs": [{
+      "name": "domain",
+      "value": "staging.example.com"
+    }],
+    "components": [{
+      "name": "web",
+      "image": "nginx:1.17"
+    }],
+    "overlays": [{
+      "name": "production",
+      "sharedEnv": [{
+        "name": "domain",
+        "value": "example.com"
+      }],
+      "components": [{
+        "name": "web",
+        "replicas": 3,
+        "imageTag": "1.18"
+      }]
+    }]
+  }
+}`
+
+	rec := suite.NewRequest(http.MethodPost, "/v1alpha1/applications/test4", body)
+	suite.Equal(http.StatusCreated, rec.Code)
+
+	// no overlay in use
+	var res resources.Application
+	rec = suite.NewRequest(http.MethodGet, "/v1alpha1/applications/test4/test4/resolved", nil)
+	rec.BodyAsJSON(&res)
+
+	suite.Equal(200, rec.Code)
+	suite.Equal("nginx:1.17", res.Components[0].Image)
+	suite.Equal("staging.example.com", res.SharedEnvs[0].Value)
+
+	rec = suite.NewRequest(http.MethodGet, "/v1alpha1/applications/test4/test4/resolved?overlay=production", nil)
+	rec.BodyAsJSON(&res)
+
+	suite.Equal(200, rec.Code)
+	suite.Equal("nginx:1.18", res.Components[0].Image)
+	suite.Equal(int32(3), *res.Components[0].Replicas)
+	suite.Equal("example.com", res.SharedEnvs[0].Value)
+	suite.Equal(0, len(res.Overlays))
+
+	rec = suite.NewRequest(http.MethodGet, "/v1alpha1/applications/test4/test4/resolved?overlay=unknown", nil)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+}
+
 func TestApplicationsHanlderTestSuite(t *testing.T) {
 	suite.Run(t, new(ApplicationsHandlerTestSuite))
 }
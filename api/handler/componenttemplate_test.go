@@ -4,6 +4,8 @@ import (
 	"github.com/kapp-staging/kapp/api/resources"
 	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
 	"github.com/stretchr/testify/suite"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"net/http"
 	"testing"
 )
@@ -121,6 +123,108 @@ func (suite *ComponentTemplateTestSuite) TestDeleteCT() {
 	suite.Equal(http.StatusNoContent, rec.Code)
 }
 
+func (suite *ComponentTemplateTestSuite) TestRenderCT() {
+	body := `{
+  "name": "redis",
+  "image": "redis:${version}",
+  "parameters": [{
+	"name": "version",
+	"type": "string",
+	"default": "5"
+  }, {
+	"name": "port",
+	"type": "integer",
+	"required": true
+  }],
+  "ports": [{
+	"name": "redis",
+	"containerPort": "${port}"
+  }]
+}`
+
+	rec := suite.NewRequest(http.MethodPost, "/v1alpha1/componenttemplates", body)
+	suite.Equal(201, rec.Code)
+
+	// missing required parameter
+	rec = suite.NewRequest(http.MethodPost, "/v1alpha1/componenttemplates/redis/render", `{"values": {}}`)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+
+	var res v1alpha1.ComponentSpec
+	rec = suite.NewRequest(http.MethodPost, "/v1alpha1/componenttemplates/redis/render", `{"values": {"port": "6379"}}`)
+	rec.BodyAsJSON(&res)
+
+	suite.Equal(200, rec.Code)
+	suite.Equal("redis:5", res.Image)
+	suite.Equal(uint32(6379), res.Ports[0].ContainerPort)
+}
+
+func (suite *ComponentTemplateTestSuite) TestCreateCTWithUndefinedParameter() {
+	body := `{
+  "name": "web4",
+  "image": "busybox:${tag}"
+}`
+
+	rec := suite.NewRequest(http.MethodPost, "/v1alpha1/componenttemplates", body)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+}
+
+func (suite *ComponentTemplateTestSuite) TestNamespacedCT() {
+	_, err := suite.k8sClinet.CoreV1().Namespaces().Create(&coreV1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{Name: "kapp-ct"},
+	})
+	suite.Nil(err)
+
+	body := `{
+  "name": "web5",
+  "image": "busybox"
+}`
+
+	var res v1alpha1.ComponentTemplateSpec
+	rec := suite.NewRequest(http.MethodPost, "/v1alpha1/namespaces/kapp-ct/componenttemplates", body)
+	rec.BodyAsJSON(&res)
+
+	suite.Equal(201, rec.Code)
+	suite.Equal("web5", res.Name)
+
+	var list []v1alpha1.ComponentTemplateSpec
+	rec = suite.NewRequest(http.MethodGet, "/v1alpha1/namespaces/kapp-ct/componenttemplates", nil)
+	rec.BodyAsJSON(&list)
+
+	suite.Equal(200, rec.Code)
+	suite.Equal(1, len(list))
+
+	// not visible as a cluster template
+	rec = suite.NewRequest(http.MethodGet, "/v1alpha1/componenttemplates", nil)
+	rec.BodyAsJSON(&list)
+
+	for _, template := range list {
+		suite.NotEqual("web5", template.Name)
+	}
+
+	rec = suite.NewRequest(http.MethodDelete, "/v1alpha1/namespaces/kapp-ct/componenttemplates/web5", nil)
+	suite.Equal(http.StatusNoContent, rec.Code)
+}
+
+func (suite *ComponentTemplateTestSuite) TestInstallCTCatalog() {
+	var catalog []v1alpha1.ComponentTemplateSpec
+	rec := suite.NewRequest(http.MethodGet, "/v1alpha1/componenttemplates/catalog", nil)
+	rec.BodyAsJSON(&catalog)
+
+	suite.Equal(200, rec.Code)
+	suite.Equal(5, len(catalog))
+
+	var installed []v1alpha1.ComponentTemplateSpec
+	rec = suite.NewRequest(http.MethodPost, "/v1alpha1/componenttemplates/catalog/install", nil)
+	rec.BodyAsJSON(&installed)
+
+	suite.Equal(200, rec.Code)
+	suite.Equal(5, len(installed))
+
+	// installing twice is fine
+	rec = suite.NewRequest(http.MethodPost, "/v1alpha1/componenttemplates/catalog/install", nil)
+	suite.Equal(200, rec.Code)
+}
+
 func TestComponentTemplateHanlderTestSuite(t *testing.T) {
 	suite.Run(t, new(ComponentTemplateTestSuite))
 }
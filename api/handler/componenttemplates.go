@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"github.com/kapp-staging/kapp/api/errors"
 	"github.com/kapp-staging/kapp/api/resources"
 	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
 	"github.com/labstack/echo/v4"
@@ -39,6 +40,28 @@ func (h *ApiHandler) handleUpdateComponentTemplate(c echo.Context) error {
 	return c.JSON(200, h.componentTemplateResponse(c, componentTemplate))
 }
 
+func (h *ApiHandler) handleRenderComponentTemplate(c echo.Context) error {
+	componentTemplate, err := getKappComponentTemplate(c)
+
+	if err != nil {
+		return err
+	}
+
+	var req resources.RenderComponentTemplateRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	component, err := v1alpha1.RenderComponentTemplate(componentTemplate.Spec, req.Values)
+
+	if err != nil {
+		return errors.NewBadRequest(err.Error())
+	}
+
+	return c.JSON(200, component)
+}
+
 func (h *ApiHandler) handleDeleteComponentTemplate(c echo.Context) error {
 	err := deleteKappComponentTemplate(c)
 	if err != nil {
@@ -47,7 +70,72 @@ func (h *ApiHandler) handleDeleteComponentTemplate(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+func (h *ApiHandler) handleGetComponentTemplateCatalog(c echo.Context) error {
+	templates, err := resources.GetComponentTemplateCatalog()
+
+	if err != nil {
+		return err
+	}
+
+	res := []interface{}{}
+
+	for i := range templates {
+		res = append(res, templates[i].Spec)
+	}
+
+	return c.JSON(200, res)
+}
+
+// install all templates in catalog as ComponentTemplates.
+// Existing templates are updated only if their versions are different from the catalog.
+func (h *ApiHandler) handleInstallComponentTemplateCatalog(c echo.Context) error {
+	templates, err := resources.GetComponentTemplateCatalog()
+
+	if err != nil {
+		return err
+	}
+
+	k8sClient := getK8sClient(c)
+	res := []interface{}{}
+
+	for i := range templates {
+		template := templates[i]
+		url := "/apis/core.kapp.dev/v1alpha1/componenttemplates/" + template.Name
+
+		var fetched v1alpha1.ComponentTemplate
+		err := k8sClient.RESTClient().Get().AbsPath(url).Do().Into(&fetched)
+
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		var installed v1alpha1.ComponentTemplate
+
+		if errors.IsNotFound(err) {
+			bts, _ := json.Marshal(template)
+			err = k8sClient.RESTClient().Post().Body(bts).AbsPath("/apis/core.kapp.dev/v1alpha1/componenttemplates").Do().Into(&installed)
+		} else if fetched.Spec.Version != template.Spec.Version {
+			template.ResourceVersion = fetched.ResourceVersion
+			bts, _ := json.Marshal(template)
+			err = k8sClient.RESTClient().Put().Body(bts).AbsPath(url).Do().Into(&installed)
+		} else {
+			installed = fetched
+		}
+
+		if err != nil {
+			return err
+		}
+
+		res = append(res, installed.Spec)
+	}
+
+	return c.JSON(200, res)
+}
+
 // Helper functions
+//
+// Routes with a namespace param work on NamespacedComponentTemplates, others work on ComponentTemplates.
+// The two kinds share the same schema, so both are decoded into v1alpha1.ComponentTemplate here.
 
 func deleteKappComponentTemplate(c echo.Context) error {
 	k8sClient := getK8sClient(c)
@@ -65,11 +153,17 @@ func createKappComponentTemplate(c echo.Context) (*v1alpha1.ComponentTemplate, e
 	}
 
 	bts, _ := json.Marshal(crdComponentTemplate)
-	var componentTemplate v1alpha1.ComponentTemplate
-	err = k8sClient.RESTClient().Post().Body(bts).AbsPath(kappComponentTemplateUrl(c)).Do().Into(&componentTemplate)
+	res, err := k8sClient.RESTClient().Post().Body(bts).AbsPath(kappComponentTemplateUrl(c)).DoRaw()
+
 	if err != nil {
 		return nil, err
 	}
+
+	var componentTemplate v1alpha1.ComponentTemplate
+	if err := json.Unmarshal(res, &componentTemplate); err != nil {
+		return nil, err
+	}
+
 	return &componentTemplate, nil
 }
 
@@ -91,49 +185,67 @@ func updateKappComponentTemplate(c echo.Context) (*v1alpha1.ComponentTemplate, e
 	crdComponentTemplate.ResourceVersion = fetched.ResourceVersion
 
 	bts, _ := json.Marshal(crdComponentTemplate)
-	var componentTemplate v1alpha1.ComponentTemplate
-	err = k8sClient.RESTClient().Put().Body(bts).AbsPath(kappComponentTemplateUrl(c)).Do().Into(&componentTemplate)
+	res, err := k8sClient.RESTClient().Put().Body(bts).AbsPath(kappComponentTemplateUrl(c)).DoRaw()
 
 	if err != nil {
 		return nil, err
 	}
 
+	var componentTemplate v1alpha1.ComponentTemplate
+	if err := json.Unmarshal(res, &componentTemplate); err != nil {
+		return nil, err
+	}
+
 	return &componentTemplate, nil
 }
 
 func getKappComponentTemplate(c echo.Context) (*v1alpha1.ComponentTemplate, error) {
 	k8sClient := getK8sClient(c)
-	var fetched v1alpha1.ComponentTemplate
-	err := k8sClient.RESTClient().Get().AbsPath(kappComponentTemplateUrl(c)).Do().Into(&fetched)
+	res, err := k8sClient.RESTClient().Get().AbsPath(kappComponentTemplateUrl(c)).DoRaw()
 	if err != nil {
 		return nil, err
 	}
+
+	var fetched v1alpha1.ComponentTemplate
+	if err := json.Unmarshal(res, &fetched); err != nil {
+		return nil, err
+	}
+
 	return &fetched, nil
 }
 
 func getKappComponentTemplateList(c echo.Context) (*v1alpha1.ComponentTemplateList, error) {
 	k8sClient := getK8sClient(c)
-	var fetched v1alpha1.ComponentTemplateList
-	err := k8sClient.RESTClient().Get().AbsPath(kappComponentTemplateUrl(c)).Do().Into(&fetched)
+	res, err := k8sClient.RESTClient().Get().AbsPath(kappComponentTemplateUrl(c)).DoRaw()
 	if err != nil {
 		return nil, err
 	}
+
+	var fetched v1alpha1.ComponentTemplateList
+	if err := json.Unmarshal(res, &fetched); err != nil {
+		return nil, err
+	}
+
 	return &fetched, nil
 }
 
 func kappComponentTemplateUrl(c echo.Context) string {
-	//namespace := c.Param("namespace")
+	namespace := c.Param("namespace")
 	name := c.Param("name")
 
-	//if namespace == "" && name == "" {
-	//	return "/apis/core.kapp.dev/v1alpha1/componenttemplates"
-	//}
+	var url string
+
+	if namespace == "" {
+		url = "/apis/core.kapp.dev/v1alpha1/componenttemplates"
+	} else {
+		url = "/apis/core.kapp.dev/v1alpha1/namespaces/" + namespace + "/namespacedcomponenttemplates"
+	}
 
 	if name == "" {
-		//return "/apis/core.kapp.dev/v1alpha1/namespaces/" + namespace + "/componenttemplates"
-		return "/apis/core.kapp.dev/v1alpha1/componenttemplates"
+		return url
 	}
-	return "/apis/core.kapp.dev/v1alpha1/componenttemplates/" + name
+
+	return url + "/" + name
 }
 
 func getComponentTemplateFromContext(c echo.Context) (*v1alpha1.ComponentTemplate, error) {
@@ -143,13 +255,24 @@ func getComponentTemplateFromContext(c echo.Context) (*v1alpha1.ComponentTemplat
 		return nil, err
 	}
 
+	if err := v1alpha1.TryValidateComponentTemplate(req); err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+
+	kind := "ComponentTemplate"
+
+	if c.Param("namespace") != "" {
+		kind = "NamespacedComponentTemplate"
+	}
+
 	crdComponentTemplate := &v1alpha1.ComponentTemplate{
 		TypeMeta: metaV1.TypeMeta{
-			Kind:       "ComponentTemplate",
+			Kind:       kind,
 			APIVersion: "core.kapp.dev/v1alpha1",
 		},
 		ObjectMeta: metaV1.ObjectMeta{
-			Name: req.Name,
+			Name:      req.Name,
+			Namespace: c.Param("namespace"),
 		},
 		Spec: req,
 	}
@@ -1,6 +1,14 @@
 package handler
 
-import "github.com/labstack/echo/v4"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kapp-staging/kapp/api/errors"
+	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
+	"github.com/labstack/echo/v4"
+	"k8s.io/client-go/kubernetes"
+)
 
 func (h *ApiHandler) handleGetDependencies(c echo.Context) error {
 	k8sClient := getK8sClient(c)
@@ -13,9 +21,79 @@ func (h *ApiHandler) handleGetDependencies(c echo.Context) error {
 	return c.JSONBlob(200, res)
 }
 
-// TODO list support dependencies
+// types of dependencies the controller has a driver for, with the schema of their config
 func (h *ApiHandler) handleGetAvailableDependencies(c echo.Context) error {
 	return c.JSON(200, H{
-		"dependencies": []string{},
+		"dependencies": v1alpha1.ListDependencyTypes(),
 	})
 }
+
+func (h *ApiHandler) handleGetAvailableDependency(c echo.Context) error {
+	info, exist := v1alpha1.GetDependencyTypeInfo(c.Param("type"))
+	if !exist {
+		return errors.NewNotFound("unknown dependency type: " + c.Param("type"))
+	}
+
+	return c.JSON(200, info)
+}
+
+// checks a DependencySpec against the config schema of its type, the same way the Dependency webhook does
+func (h *ApiHandler) handleValidateDependency(c echo.Context) error {
+	var spec v1alpha1.DependencySpec
+	if err := c.Bind(&spec); err != nil {
+		return err
+	}
+
+	if err := v1alpha1.ValidateDependencySpec(spec); err != nil {
+		return errors.NewBadRequest(err.Error())
+	}
+
+	return c.NoContent(200)
+}
+
+// running dependencies decide where metrics, logs and monitoring of the endpoints polled by the dashboard come from,
+// they are listed again after this long
+const runningDependenciesTTL = 10 * time.Second
+
+// getRunningDependencies is runningDependencies, cached for runningDependenciesTTL
+func (h *ApiHandler) getRunningDependencies(k8sClient *kubernetes.Clientset) ([]v1alpha1.Dependency, error) {
+	h.runningDependenciesMu.Lock()
+	defer h.runningDependenciesMu.Unlock()
+
+	now := time.Now()
+	if now.Before(h.runningDependenciesExpires) {
+		return h.runningDependencies, nil
+	}
+
+	dependencies, err := runningDependencies(k8sClient)
+	if err != nil {
+		return nil, err
+	}
+
+	h.runningDependencies = dependencies
+	h.runningDependenciesExpires = now.Add(runningDependenciesTTL)
+
+	return dependencies, nil
+}
+
+// dependencies which are running, sorted by name
+func runningDependencies(k8sClient *kubernetes.Clientset) ([]v1alpha1.Dependency, error) {
+	res, err := k8sClient.RESTClient().Get().AbsPath("/apis/core.kapp.dev/v1alpha1/dependencies").DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var dependencies v1alpha1.DependencyList
+	if err := json.Unmarshal(res, &dependencies); err != nil {
+		return nil, err
+	}
+
+	var rst []v1alpha1.Dependency
+	for _, dep := range dependencies.Items {
+		if dep.Status.Status == v1alpha1.DependencyStatusRunning {
+			rst = append(rst, dep)
+		}
+	}
+
+	return rst, nil
+}
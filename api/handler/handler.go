@@ -1,12 +1,18 @@
 package handler
 
 import (
+	"sync"
+	"time"
+
+	"github.com/kapp-staging/kapp/api/auth"
 	"github.com/kapp-staging/kapp/api/client"
+	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
@@ -16,9 +22,22 @@ var (
 	}
 )
 
+// results of access reviews of callers are reused for this long
+const accessCacheTTL = time.Minute
+
 type ApiHandler struct {
 	clientManager *client.ClientManager
 	logger        *logrus.Logger
+	accessCache   *auth.AccessCache
+
+	// created once by getClusterClient
+	clusterClientMu sync.Mutex
+	clusterClient   *kubernetes.Clientset
+
+	// cached by getRunningDependencies
+	runningDependenciesMu      sync.Mutex
+	runningDependencies        []v1alpha1.Dependency
+	runningDependenciesExpires time.Time
 }
 
 type H map[string]interface{}
@@ -38,6 +57,8 @@ func (h *ApiHandler) Install(e *echo.Echo) {
 
 	gV1.GET("/dependencies", h.handleGetDependencies)
 	gV1.GET("/dependencies/available", h.handleGetAvailableDependencies)
+	gV1.GET("/dependencies/available/:type", h.handleGetAvailableDependency)
+	gV1.POST("/dependencies/validate", h.handleValidateDependency)
 
 	gv1Alpha1 := e.Group("/v1alpha1")
 	gv1Alpha1.GET("/logs", h.logWebsocketHandler)
@@ -47,6 +68,9 @@ func (h *ApiHandler) Install(e *echo.Echo) {
 	gv1Alpha1WithAuth.GET("/applications", h.handleGetApplications)
 	gv1Alpha1WithAuth.GET("/applications/:namespace", h.handleGetApplications)
 	gv1Alpha1WithAuth.GET("/applications/:namespace/:name", h.handleGetApplicationDetails)
+	gv1Alpha1WithAuth.GET("/applications/:namespace/:name/resolved", h.handleGetResolvedApplication)
+	gv1Alpha1WithAuth.GET("/applications/:namespace/:name/logs", h.handleGetApplicationLogs)
+	gv1Alpha1WithAuth.GET("/logs/search", h.handleSearchLogs)
 	gv1Alpha1WithAuth.PUT("/applications/:namespace/:name", h.handleUpdateApplicationNew)
 	gv1Alpha1WithAuth.DELETE("/applications/:namespace/:name", h.handleDeleteApplication)
 	gv1Alpha1WithAuth.POST("/applications/:namespace", h.handleCreateApplicationNew)
@@ -54,7 +78,15 @@ func (h *ApiHandler) Install(e *echo.Echo) {
 	gv1Alpha1WithAuth.GET("/componenttemplates", h.handleGetComponentTemplates)
 	gv1Alpha1WithAuth.POST("/componenttemplates", h.handleCreateComponentTemplate)
 	gv1Alpha1WithAuth.PUT("/componenttemplates/:name", h.handleUpdateComponentTemplate)
+	gv1Alpha1WithAuth.POST("/componenttemplates/:name/render", h.handleRenderComponentTemplate)
 	gv1Alpha1WithAuth.DELETE("/componenttemplates/:name", h.handleDeleteComponentTemplate)
+	gv1Alpha1WithAuth.GET("/componenttemplates/catalog", h.handleGetComponentTemplateCatalog)
+	gv1Alpha1WithAuth.POST("/componenttemplates/catalog/install", h.handleInstallComponentTemplateCatalog)
+	gv1Alpha1WithAuth.GET("/namespaces/:namespace/componenttemplates", h.handleGetComponentTemplates)
+	gv1Alpha1WithAuth.POST("/namespaces/:namespace/componenttemplates", h.handleCreateComponentTemplate)
+	gv1Alpha1WithAuth.PUT("/namespaces/:namespace/componenttemplates/:name", h.handleUpdateComponentTemplate)
+	gv1Alpha1WithAuth.POST("/namespaces/:namespace/componenttemplates/:name/render", h.handleRenderComponentTemplate)
+	gv1Alpha1WithAuth.DELETE("/namespaces/:namespace/componenttemplates/:name", h.handleDeleteComponentTemplate)
 
 	gv1Alpha1WithAuth.GET("/files/:namespace", h.handleListFiles)
 	gv1Alpha1WithAuth.POST("/files/:namespace", h.handleCreateFile)
@@ -64,11 +96,19 @@ func (h *ApiHandler) Install(e *echo.Echo) {
 
 	gv1Alpha1WithAuth.GET("/nodes/metrics", h.handleGetNodeMetricsNew)
 
+	gv1Alpha1WithAuth.GET("/usage/namespaces", h.handleGetNamespacesUsage)
+	gv1Alpha1WithAuth.GET("/usage/applications", h.handleGetApplicationsUsage)
+	gv1Alpha1WithAuth.GET("/usage/applications/:namespace", h.handleGetApplicationsUsage)
+	gv1Alpha1WithAuth.GET("/usage/nodes", h.handleGetNodesUsage)
+
 	gv1Alpha1WithAuth.DELETE("/pods/:namespace/:name", h.handleDeletePod)
 
 	gv1Alpha1WithAuth.GET("/namespaces", h.handleListNamespaces)
 	gv1Alpha1WithAuth.POST("/namespaces/:name", h.handleCreateNamespace)
 	gv1Alpha1WithAuth.DELETE("/namespaces/:name", h.handleDeleteNamespace)
+	gv1Alpha1WithAuth.GET("/namespaces/:name/quota", h.handleGetNamespaceQuota)
+	gv1Alpha1WithAuth.PUT("/namespaces/:name/quota", h.handleSetNamespaceQuota)
+	gv1Alpha1WithAuth.DELETE("/namespaces/:name/quota", h.handleDeleteNamespaceQuota)
 
 	gv1Alpha1WithAuth.GET("/rolebindings", h.handleListRoleBindings)
 	gv1Alpha1WithAuth.POST("/rolebindings", h.handleCreateRoleBinding)
@@ -81,5 +121,6 @@ func NewApiHandler(clientManager *client.ClientManager) *ApiHandler {
 	return &ApiHandler{
 		clientManager: clientManager,
 		logger:        logrus.New(),
+		accessCache:   auth.NewAccessCache(accessCacheTTL),
 	}
 }
@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"github.com/kapp-staging/kapp/api/resources"
 	"github.com/labstack/echo/v4"
 	"k8s.io/metrics/pkg/client/clientset/versioned"
 )
@@ -58,6 +57,20 @@ func (h *ApiHandler) handleGetNodeMetricsNew(c echo.Context) error {
 		nodeNames = append(nodeNames, n.Name)
 	}
 
-	resp := resources.GetFilteredNodeMetrics(nodeNames)
+	query, err := metricsQueryFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	provider, err := h.getMetricsProvider(c)
+	if err != nil {
+		return err
+	}
+
+	resp, err := provider.GetNodeMetrics(nodeNames, query)
+	if err != nil {
+		return err
+	}
+
 	return c.JSON(200, resp)
 }
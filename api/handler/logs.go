@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/kapp-staging/kapp/api/auth"
+	"github.com/kapp-staging/kapp/api/errors"
+	"github.com/kapp-staging/kapp/api/resources"
+	"github.com/labstack/echo/v4"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultLogQueryRange    = time.Hour
+	defaultLogQueryPageSize = 100
+	maxLogQueryPageSize     = 1000
+)
+
+// historical logs of the pods of an application, from the log dependency
+func (h *ApiHandler) handleGetApplicationLogs(c echo.Context) error {
+	query, err := logQueryFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	namespace := c.Param("namespace")
+	query.Namespaces = []string{namespace}
+	query.Application = c.Param("name")
+
+	if err := canGetPodLogs(getK8sClient(c), namespace); err != nil {
+		return err
+	}
+
+	return h.queryLogs(c, query)
+}
+
+// search historical logs in a namespace, or in all the kapp namespaces the caller can read logs of
+func (h *ApiHandler) handleSearchLogs(c echo.Context) error {
+	query, err := logQueryFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	query.Application = c.QueryParam("application")
+	k8sClient := getK8sClient(c)
+
+	if namespace := c.QueryParam("namespace"); namespace != "" {
+		if err := canGetPodLogs(k8sClient, namespace); err != nil {
+			return err
+		}
+
+		query.Namespaces = []string{namespace}
+	} else {
+		clusterClient, err := h.getClusterClient()
+		if err != nil {
+			return err
+		}
+
+		namespaces, err := logReadableNamespaces(clusterClient, k8sClient)
+		if err != nil {
+			return err
+		}
+
+		if len(namespaces) == 0 {
+			return c.JSON(200, &resources.LogPage{Entries: []resources.LogEntry{}})
+		}
+
+		query.Namespaces = namespaces
+	}
+
+	return h.queryLogs(c, query)
+}
+
+func (h *ApiHandler) queryLogs(c echo.Context, query *resources.LogQuery) error {
+	backend, err := h.getLogBackend()
+	if err != nil {
+		return err
+	}
+
+	page, err := backend.QueryLogs(query)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, page)
+}
+
+// component, pod, q, page from 1, pageSize, and start and end in RFC3339, the last hour by default
+func logQueryFromContext(c echo.Context) (*resources.LogQuery, error) {
+	query := &resources.LogQuery{
+		Component: c.QueryParam("component"),
+		Pod:       c.QueryParam("pod"),
+		Text:      c.QueryParam("q"),
+		End:       time.Now(),
+		Limit:     defaultLogQueryPageSize,
+	}
+
+	if end := c.QueryParam("end"); end != "" {
+		t, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			return nil, errors.NewBadRequest("invalid end: " + err.Error())
+		}
+
+		query.End = t
+	}
+
+	query.Start = query.End.Add(-defaultLogQueryRange)
+	if start := c.QueryParam("start"); start != "" {
+		t, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			return nil, errors.NewBadRequest("invalid start: " + err.Error())
+		}
+
+		query.Start = t
+	}
+
+	if !query.Start.Before(query.End) {
+		return nil, errors.NewBadRequest("start should be before end")
+	}
+
+	if pageSize := c.QueryParam("pageSize"); pageSize != "" {
+		n, err := strconv.Atoi(pageSize)
+		if err != nil || n <= 0 || n > maxLogQueryPageSize {
+			return nil, errors.NewBadRequest("pageSize should be between 1 and " + strconv.Itoa(maxLogQueryPageSize))
+		}
+
+		query.Limit = n
+	}
+
+	if page := c.QueryParam("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n <= 0 {
+			return nil, errors.NewBadRequest("page should be a positive integer")
+		}
+
+		query.Offset = (n - 1) * query.Limit
+	}
+
+	if query.Offset+query.Limit >= resources.MaxLogResultWindow {
+		return nil, errors.NewBadRequest("only the newest " + strconv.Itoa(resources.MaxLogResultWindow-1) +
+			" entries can be paged, narrow the time range")
+	}
+
+	return query, nil
+}
+
+// logs are read from the log backend with the permission of the api server,
+// so the caller has to be allowed to read logs of pods in the namespace
+func canGetPodLogs(k8sClient *kubernetes.Clientset, namespace string) error {
+	allowed, err := auth.Can(k8sClient, podLogsAttributes(namespace))
+
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return errors.NewForbidden("not allowed to read logs of pods in namespace " + namespace)
+	}
+
+	return nil
+}
+
+func podLogsAttributes(namespace string) authorizationV1.ResourceAttributes {
+	return authorizationV1.ResourceAttributes{
+		Namespace:   namespace,
+		Resource:    "pods",
+		Subresource: "log",
+		Verb:        "get",
+	}
+}
+
+// kapp namespaces in which the caller can read logs of pods.
+// The namespaces are listed by the api server, the caller may only be bound to roles in some of them.
+func logReadableNamespaces(clusterClient, k8sClient *kubernetes.Clientset) ([]string, error) {
+	namespaces, err := resources.ListNamespaces(clusterClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var rst []string
+	for _, ns := range namespaces {
+		allowed, err := auth.Can(k8sClient, podLogsAttributes(ns.Name))
+		if err != nil {
+			return nil, err
+		}
+
+		if allowed {
+			rst = append(rst, ns.Name)
+		}
+	}
+
+	return rst, nil
+}
+
+// getLogBackend returns the backend of the running log dependency
+func (h *ApiHandler) getLogBackend() (resources.LogBackend, error) {
+	k8sClient, err := h.getClusterClient()
+	if err != nil {
+		return nil, err
+	}
+
+	dependencies, err := h.getRunningDependencies(k8sClient)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dep := range dependencies {
+		switch dep.Spec.Type {
+		case "loki":
+			return resources.NewLokiLogBackend(k8sClient), nil
+		case "log":
+			return resources.NewElasticsearchLogBackend(k8sClient)
+		}
+	}
+
+	return nil, errors.NewNotFound("no log dependency is running")
+}
+
+// client with the identity of the api server, shared by all requests
+func (h *ApiHandler) getClusterClient() (*kubernetes.Clientset, error) {
+	h.clusterClientMu.Lock()
+	defer h.clusterClientMu.Unlock()
+
+	if h.clusterClient != nil {
+		return h.clusterClient, nil
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(h.clientManager.ClusterConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	h.clusterClient = k8sClient
+
+	return k8sClient, nil
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/kapp-staging/kapp/api/errors"
+	"github.com/kapp-staging/kapp/api/resources"
+	"github.com/labstack/echo/v4"
+	authorizationV1 "k8s.io/api/authorization/v1"
+)
+
+// points of a history are limited, as they are all sent to the browser
+const maxMetricsPoints = 11000
+
+// metricsStart and metricsEnd in RFC3339, the range of the scraper by default,
+// and metricsStep as a duration, e.g. 1m, the resolution of the provider by default
+func metricsQueryFromContext(c echo.Context) (*resources.MetricsQuery, error) {
+	query := resources.NewDefaultMetricsQuery()
+	defaultRange := query.End.Sub(query.Start)
+
+	if end := c.QueryParam("metricsEnd"); end != "" {
+		t, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			return nil, errors.NewBadRequest("invalid metricsEnd: " + err.Error())
+		}
+
+		query.End = t
+		query.Start = t.Add(-defaultRange)
+	}
+
+	if start := c.QueryParam("metricsStart"); start != "" {
+		t, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			return nil, errors.NewBadRequest("invalid metricsStart: " + err.Error())
+		}
+
+		query.Start = t
+	}
+
+	if !query.Start.Before(query.End) {
+		return nil, errors.NewBadRequest("metricsStart should be before metricsEnd")
+	}
+
+	if step := c.QueryParam("metricsStep"); step != "" {
+		d, err := time.ParseDuration(step)
+		if err != nil || d <= 0 {
+			return nil, errors.NewBadRequest("metricsStep should be a positive duration, e.g. 1m")
+		}
+
+		if query.End.Sub(query.Start)/d > maxMetricsPoints {
+			return nil, errors.NewBadRequest("too many points, at most " + strconv.Itoa(maxMetricsPoints) +
+				" points in the range, use a larger metricsStep")
+		}
+
+		query.Step = d
+	}
+
+	return query, nil
+}
+
+// prometheus of the running kube-prometheus dependency, or the scraper of metrics-server.
+// Both read metrics with the permission of the api server, so the provider only returns
+// the metrics the caller is allowed to read from metrics-server.
+func (h *ApiHandler) getMetricsProvider(c echo.Context) (resources.MetricsProvider, error) {
+	k8sClient, err := h.getClusterClient()
+	if err != nil {
+		return nil, err
+	}
+
+	dependencies, err := h.getRunningDependencies(k8sClient)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := resources.ScraperMetricsProvider
+
+	for _, dep := range dependencies {
+		if dep.Spec.Type == "kube-prometheus" {
+			provider = resources.NewPrometheusMetricsProvider(k8sClient)
+			break
+		}
+	}
+
+	return &authorizedMetricsProvider{
+		MetricsProvider: provider,
+		can: func(attributes authorizationV1.ResourceAttributes) (bool, error) {
+			return h.accessCache.Can(getK8sClientConfig(c), getK8sClient(c), attributes)
+		},
+	}, nil
+}
+
+// authorizedMetricsProvider checks the RBAC of the caller on the metrics api before reading metrics
+type authorizedMetricsProvider struct {
+	resources.MetricsProvider
+	can func(attributes authorizationV1.ResourceAttributes) (bool, error)
+}
+
+func (p *authorizedMetricsProvider) GetNodeMetrics(nodes []string, query *resources.MetricsQuery) (resources.NodesMetricHistories, error) {
+	allowed, err := p.can(authorizationV1.ResourceAttributes{
+		Group:    "metrics.k8s.io",
+		Resource: "nodes",
+		Verb:     "list",
+	})
+
+	if err != nil {
+		return resources.NodesMetricHistories{}, err
+	}
+
+	if !allowed {
+		return resources.NodesMetricHistories{}, errors.NewForbidden("not allowed to read metrics of nodes")
+	}
+
+	return p.MetricsProvider.GetNodeMetrics(nodes, query)
+}
+
+func (p *authorizedMetricsProvider) GetComponentMetrics(namespace, application string, components []string, query *resources.MetricsQuery) (map[string]resources.ComponentMetrics, error) {
+	if err := p.checkPodMetrics(namespace); err != nil {
+		return nil, err
+	}
+
+	return p.MetricsProvider.GetComponentMetrics(namespace, application, components, query)
+}
+
+func (p *authorizedMetricsProvider) GetApplicationsComponentMetrics(namespace string, applications map[string][]string, query *resources.MetricsQuery) (map[string]map[string]resources.ComponentMetrics, error) {
+	if err := p.checkPodMetrics(namespace); err != nil {
+		return nil, err
+	}
+
+	return p.MetricsProvider.GetApplicationsComponentMetrics(namespace, applications, query)
+}
+
+func (p *authorizedMetricsProvider) checkPodMetrics(namespace string) error {
+	allowed, err := p.can(authorizationV1.ResourceAttributes{
+		Namespace: namespace,
+		Group:     "metrics.k8s.io",
+		Resource:  "pods",
+		Verb:      "list",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return errors.NewForbidden("not allowed to read metrics of pods in namespace " + namespace)
+	}
+
+	return nil
+}
+
+// builder of the details of applications, with the metrics selected by the request
+func (h *ApiHandler) applicationBuilder(c echo.Context) (*resources.Builder, error) {
+	query, err := metricsQueryFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := h.getMetricsProvider(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resources.Builder{
+		K8sClient:       getK8sClient(c),
+		Logger:          h.logger,
+		Config:          getK8sClientConfig(c),
+		MetricsProvider: provider,
+		MetricsQuery:    query,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/kapp-staging/kapp/api/resources"
+	"github.com/stretchr/testify/assert"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type fakeMetricsProvider struct {
+	calls int
+}
+
+func (p *fakeMetricsProvider) GetNodeMetrics(nodes []string, query *resources.MetricsQuery) (resources.NodesMetricHistories, error) {
+	p.calls++
+	return resources.NodesMetricHistories{}, nil
+}
+
+func (p *fakeMetricsProvider) GetComponentMetrics(namespace, application string, components []string, query *resources.MetricsQuery) (map[string]resources.ComponentMetrics, error) {
+	p.calls++
+	return map[string]resources.ComponentMetrics{}, nil
+}
+
+func (p *fakeMetricsProvider) GetApplicationsComponentMetrics(namespace string, applications map[string][]string, query *resources.MetricsQuery) (map[string]map[string]resources.ComponentMetrics, error) {
+	p.calls++
+	return map[string]map[string]resources.ComponentMetrics{}, nil
+}
+
+func TestAuthorizedMetricsProviderNamespaceUser(t *testing.T) {
+	provider := &fakeMetricsProvider{}
+
+	// a user bound to the reader role of kapp-team only, without any cluster permissions
+	var checked []authorizationV1.ResourceAttributes
+	authorized := &authorizedMetricsProvider{
+		MetricsProvider: provider,
+		can: func(attributes authorizationV1.ResourceAttributes) (bool, error) {
+			checked = append(checked, attributes)
+			return attributes.Namespace == "kapp-team" && attributes.Group == "metrics.k8s.io" &&
+				attributes.Resource == "pods" && attributes.Verb == "list", nil
+		},
+	}
+
+	query := resources.NewDefaultMetricsQuery()
+
+	metrics, err := authorized.GetComponentMetrics("kapp-team", "app", []string{"web"}, query)
+	assert.Nil(t, err)
+	assert.NotNil(t, metrics)
+	assert.Equal(t, 1, provider.calls)
+
+	_, err = authorized.GetComponentMetrics("kapp-other", "app", []string{"web"}, query)
+	assert.True(t, k8sErrors.IsForbidden(err))
+
+	_, err = authorized.GetApplicationsComponentMetrics("kapp-team", map[string][]string{"app": {"web"}}, query)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, provider.calls)
+
+	_, err = authorized.GetApplicationsComponentMetrics("kapp-other", map[string][]string{"app": {"web"}}, query)
+	assert.True(t, k8sErrors.IsForbidden(err))
+
+	_, err = authorized.GetNodeMetrics([]string{"node-1"}, query)
+	assert.True(t, k8sErrors.IsForbidden(err))
+
+	assert.Equal(t, 2, provider.calls)
+	assert.Equal(t, []authorizationV1.ResourceAttributes{
+		{Namespace: "kapp-team", Group: "metrics.k8s.io", Resource: "pods", Verb: "list"},
+		{Namespace: "kapp-other", Group: "metrics.k8s.io", Resource: "pods", Verb: "list"},
+		{Namespace: "kapp-team", Group: "metrics.k8s.io", Resource: "pods", Verb: "list"},
+		{Namespace: "kapp-other", Group: "metrics.k8s.io", Resource: "pods", Verb: "list"},
+		{Group: "metrics.k8s.io", Resource: "nodes", Verb: "list"},
+	}, checked)
+}
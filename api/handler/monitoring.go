@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"github.com/kapp-staging/kapp/api/resources"
+	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
+)
+
+// prometheus targets of the components with metrics and firing alerts, nil if kube-prometheus is not running.
+// Prometheus is queried by the api server, the caller is already allowed to read the application.
+// Monitoring is optional in the details, errors are logged only.
+func (h *ApiHandler) applicationMonitoring(application *v1alpha1.Application) *resources.ApplicationMonitoring {
+	k8sClient, err := h.getClusterClient()
+	if err != nil {
+		h.logger.Error(err)
+		return nil
+	}
+
+	dependencies, err := h.getRunningDependencies(k8sClient)
+	if err != nil {
+		h.logger.Error(err)
+		return nil
+	}
+
+	for _, dep := range dependencies {
+		if dep.Spec.Type != "kube-prometheus" {
+			continue
+		}
+
+		monitoring := &resources.ApplicationMonitoring{
+			TargetsURL:   prometheusTargetsURL(dep),
+			DashboardURL: grafanaDashboardURL(dep, application),
+			Targets:      []resources.PrometheusTarget{},
+			Alerts:       []resources.PrometheusAlert{},
+		}
+
+		targets, err := resources.GetPrometheusTargetsOfApplication(k8sClient, application.Namespace, application.Name)
+		if err != nil {
+			h.logger.Error(err)
+		} else {
+			monitoring.Targets = targets
+		}
+
+		alerts, err := resources.GetFiringAlertsOfApplication(k8sClient, application.Namespace, application.Name)
+		if err != nil {
+			h.logger.Error(err)
+		} else {
+			monitoring.Alerts = alerts
+		}
+
+		return monitoring
+	}
+
+	return nil
+}
+
+// targets page of prometheus exposed by the ingress of kube-prometheus, https if certificates are issued by cert-manager
+func prometheusTargetsURL(dep v1alpha1.Dependency) string {
+	config, err := v1alpha1.ParseKubePrometheusConfig(dep.Spec.Config)
+	if err != nil || config.PrometheusHost == "" {
+		return ""
+	}
+
+	return kubePrometheusScheme(config) + "://" + config.PrometheusHost + "/targets"
+}
+
+// dashboard of the application generated by the controller, in the grafana exposed by the ingress of kube-prometheus
+func grafanaDashboardURL(dep v1alpha1.Dependency, application *v1alpha1.Application) string {
+	config, err := v1alpha1.ParseKubePrometheusConfig(dep.Spec.Config)
+	if err != nil || config.GrafanaHost == "" {
+		return ""
+	}
+
+	return kubePrometheusScheme(config) + "://" + config.GrafanaHost + "/d/" +
+		v1alpha1.GrafanaDashboardUID(application.Namespace, application.Name)
+}
+
+func kubePrometheusScheme(config *v1alpha1.KubePrometheusConfig) string {
+	if config.CertManager != "" {
+		return "https"
+	}
+
+	return "http"
+}
@@ -35,3 +35,39 @@ func (h *ApiHandler) handleDeleteNamespace(c echo.Context) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+func (h *ApiHandler) handleGetNamespaceQuota(c echo.Context) error {
+	quota, err := resources.GetNamespaceQuota(getK8sClient(c), c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, quota)
+}
+
+func (h *ApiHandler) handleSetNamespaceQuota(c echo.Context) error {
+	var quota resources.NamespaceQuota
+
+	if err := c.Bind(&quota); err != nil {
+		return err
+	}
+
+	status, err := resources.SetNamespaceQuota(getK8sClient(c), c.Param("name"), &quota)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+func (h *ApiHandler) handleDeleteNamespaceQuota(c echo.Context) error {
+	err := resources.DeleteNamespaceQuota(getK8sClient(c), c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/kapp-staging/kapp/api/resources"
+	"github.com/labstack/echo/v4"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	"k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+func (h *ApiHandler) handleGetNamespacesUsage(c echo.Context) error {
+	metricClient, err := versioned.NewForConfig(getK8sClientConfig(c))
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := h.usageReadableNamespaces(c)
+	if err != nil {
+		return err
+	}
+
+	usage, err := resources.GetNamespacesUsage(getK8sClient(c), metricClient.MetricsV1beta1(), namespaces)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+func (h *ApiHandler) handleGetApplicationsUsage(c echo.Context) error {
+	metricClient, err := versioned.NewForConfig(getK8sClientConfig(c))
+	if err != nil {
+		return err
+	}
+
+	namespaces := []string{c.Param("namespace")}
+	if c.Param("namespace") == "" {
+		if namespaces, err = h.usageReadableNamespaces(c); err != nil {
+			return err
+		}
+	}
+
+	usage, err := resources.GetApplicationsUsage(getK8sClient(c), metricClient.MetricsV1beta1(), namespaces)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+// nodes are grouped by the value of the label query param, e.g. a label of node pools
+func (h *ApiHandler) handleGetNodesUsage(c echo.Context) error {
+	metricClient, err := versioned.NewForConfig(getK8sClientConfig(c))
+	if err != nil {
+		return err
+	}
+
+	usage, err := resources.GetNodesUsage(getK8sClient(c), metricClient.MetricsV1beta1(), c.QueryParam("label"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+// kapp namespaces in which the caller can list pods and their metrics.
+// The namespaces are listed by the api server, the caller may only be bound to roles in some of them.
+func (h *ApiHandler) usageReadableNamespaces(c echo.Context) ([]string, error) {
+	clusterClient, err := h.getClusterClient()
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := resources.ListNamespaces(clusterClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var rst []string
+	for _, ns := range namespaces {
+		allowed := true
+
+		for _, group := range []string{"", "metrics.k8s.io"} {
+			can, err := h.accessCache.Can(getK8sClientConfig(c), getK8sClient(c), authorizationV1.ResourceAttributes{
+				Namespace: ns.Name,
+				Group:     group,
+				Resource:  "pods",
+				Verb:      "list",
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			allowed = allowed && can
+		}
+
+		if allowed {
+			rst = append(rst, ns.Name)
+		}
+	}
+
+	return rst, nil
+}
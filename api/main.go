@@ -2,21 +2,28 @@ package main
 
 import (
 	"context"
+	_ "github.com/joho/godotenv/autoload"
 	"github.com/kapp-staging/kapp/api/client"
 	"github.com/kapp-staging/kapp/api/handler"
 	"github.com/kapp-staging/kapp/api/resources"
 	"github.com/kapp-staging/kapp/api/server"
-	_ "github.com/joho/godotenv/autoload"
 )
 
 import (
 	"github.com/kapp-staging/kapp/api/config"
 	"github.com/urfave/cli/v2"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"sort"
+	"syscall"
+	"time"
 )
 
+// how long requests in flight are waited for when the server is stopped
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	runningConfig := &config.Config{}
 
@@ -75,6 +82,27 @@ func main() {
 				Destination: &runningConfig.KubeConfigPath,
 				EnvVars:     []string{"KUBE_CONFIG_PATH"},
 			},
+			&cli.DurationFlag{
+				Name:        "metrics-retention",
+				Usage:       "How long the metrics scraped from the metrics server are kept. Metrics older than 15 minutes are downsampled.",
+				Value:       resources.DefaultMetricsRetention,
+				Destination: &runningConfig.MetricsRetention,
+				EnvVars:     []string{"METRICS_RETENTION"},
+			},
+			&cli.StringFlag{
+				Name: "metrics-snapshot-path",
+				Usage: "The file the scraped metrics are saved to periodically and restored from on start, e.g. on a persistent volume. " +
+					"If it's blank, metrics are only kept in memory and lost when the server restarts.",
+				Destination: &runningConfig.MetricsSnapshotPath,
+				EnvVars:     []string{"METRICS_SNAPSHOT_PATH"},
+			},
+			&cli.DurationFlag{
+				Name:        "metrics-snapshot-interval",
+				Usage:       "How often the scraped metrics are saved to --metrics-snapshot-path.",
+				Value:       time.Minute,
+				Destination: &runningConfig.MetricsSnapshotInterval,
+				EnvVars:     []string{"METRICS_SNAPSHOT_INTERVAL"},
+			},
 			&cli.StringFlag{
 				Name:        "log-level",
 				Value:       "INFO",
@@ -103,7 +131,42 @@ func run(runningConfig *config.Config) {
 	apiHandler := handler.NewApiHandler(clientManager)
 	apiHandler.Install(e)
 
-	resources.StartMetricsScraper(context.Background(), clientManager.ClusterConfig)
+	if err := resources.ConfigureMetricsStore(runningConfig.MetricsRetention, runningConfig.MetricsSnapshotPath); err != nil {
+		log.Println("[Error] restore metrics snapshot failed:", err)
+	}
+
+	// cancelled on SIGTERM or SIGINT, e.g. when the pod is deleted
+	ctx, stop := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		<-signals
+		stop()
+	}()
+
+	snapshotsDone := resources.StartMetricsSnapshots(ctx, runningConfig.MetricsSnapshotPath, runningConfig.MetricsSnapshotInterval)
+
+	if err := resources.StartMetricsScraper(ctx, clientManager.ClusterConfig); err != nil {
+		log.Println("[Error] start metrics scraper failed:", err)
+	}
+
+	go func() {
+		if err := e.Start(runningConfig.GetServerAddress()); err != nil && err != http.ErrServerClosed {
+			log.Println("[Error] server stopped:", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+
+	// requests in flight are finished, and the last metrics snapshot is written before exiting
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Println("[Error] shutdown server failed:", err)
+	}
 
-	e.Logger.Fatal(e.Start(runningConfig.GetServerAddress()))
+	<-snapshotsDone
 }
@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/kapp-staging/kapp/api/errors"
 	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
 	appsV1 "k8s.io/api/apps/v1"
+	authorizationV1 "k8s.io/api/authorization/v1"
 	v1betav1 "k8s.io/api/batch/v1beta1"
 	coreV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
 )
 
 type ListMeta struct {
@@ -103,6 +107,8 @@ type ApplicationDetails struct {
 	ComponentsStatus []ComponentStatus `json:"componentsStatus"`
 	PodNames         []string          `json:"podNames"`
 	Metrics          MetricHistories   `json:"metrics"`
+	// only when the kube-prometheus dependency is running
+	Monitoring *ApplicationMonitoring `json:"monitoring,omitempty"`
 }
 
 type CreateOrUpdateApplicationRequest struct {
@@ -115,9 +121,76 @@ type Application struct {
 	IsActive   bool                     `json:"isActive"`
 	SharedEnvs []v1alpha1.EnvVar        `json:"sharedEnvs"`
 	Components []v1alpha1.ComponentSpec `json:"components"`
+
+	Overlays []v1alpha1.ApplicationOverlay `json:"overlays,omitempty"`
+	Overlay  string                        `json:"overlay,omitempty"`
+}
+
+// CheckLinkedEnvsAccess makes sure the user can read the applications in other namespaces
+// which the linked envs of the application refer to.
+func CheckLinkedEnvsAccess(k8sClient *kubernetes.Clientset, application *v1alpha1.Application) error {
+	// by namespace/application, the permission may be granted on single applications
+	checked := make(map[string]bool)
+
+	for _, target := range v1alpha1.GetCrossApplicationLinkedEnvTargets(application.Spec) {
+		key := target.Namespace + "/" + target.Application
+
+		if target.Namespace == application.Namespace || checked[key] {
+			continue
+		}
+
+		review, err := k8sClient.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationV1.SelfSubjectAccessReview{
+			Spec: authorizationV1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationV1.ResourceAttributes{
+					Namespace: target.Namespace,
+					Resource:  "applications",
+					Name:      target.Application,
+					Verb:      "get",
+					Group:     "core.kapp.dev",
+				},
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if !review.Status.Allowed {
+			return errors.NewForbidden(fmt.Sprintf("no permission to link to application %s in namespace %s", target.Application, target.Namespace))
+		}
+
+		checked[key] = true
+	}
+
+	return nil
+}
+
+// BuildResolvedApplication shows what the controller will reconcile when the overlay is in use
+func BuildResolvedApplication(application *v1alpha1.Application, overlay string) (*Application, error) {
+	spec, err := v1alpha1.ResolveApplicationSpec(application.Spec, overlay)
+
+	if err != nil {
+		return nil, err
+	}
+
+	formatEnvs(spec.SharedEnv)
+	formatApplicationComponents(spec.Components)
+
+	return &Application{
+		Name:       application.Name,
+		Namespace:  application.Namespace,
+		IsActive:   spec.IsActive,
+		SharedEnvs: spec.SharedEnv,
+		Components: spec.Components,
+		Overlay:    spec.Overlay,
+	}, nil
 }
 
 func (builder *Builder) BuildApplicationDetails(application *v1alpha1.Application) (*ApplicationDetails, error) {
+	return builder.buildApplicationDetails(application, builder.getComponentMetrics(application))
+}
+
+func (builder *Builder) buildApplicationDetails(application *v1alpha1.Application, component2MetricMap map[string]ComponentMetrics) (*ApplicationDetails, error) {
 	ns := application.Namespace
 	listOptions := labelsBelongsToApplication(application.Name)
 
@@ -137,7 +210,7 @@ func (builder *Builder) BuildApplicationDetails(application *v1alpha1.Applicatio
 		return nil, err
 	}
 
-	componentsStatusList := builder.buildApplicationComponentStatus(application, resources)
+	componentsStatusList := builder.buildApplicationComponentStatus(application, resources, component2MetricMap)
 
 	formatEnvs(application.Spec.SharedEnv)
 	formatApplicationComponents(application.Spec.Components)
@@ -164,6 +237,8 @@ func (builder *Builder) BuildApplicationDetails(application *v1alpha1.Applicatio
 			IsActive:   application.Spec.IsActive,
 			SharedEnvs: application.Spec.SharedEnv,
 			Components: application.Spec.Components,
+			Overlays:   application.Spec.Overlays,
+			Overlay:    application.Spec.Overlay,
 		},
 		PodNames:         podNames,
 		ComponentsStatus: componentsStatusList,
@@ -213,9 +288,19 @@ func formatApplicationComponents(components []v1alpha1.ComponentSpec) {
 func (builder *Builder) BuildApplicationListResponse(applications *v1alpha1.ApplicationList) ([]ApplicationDetails, error) {
 	apps := []ApplicationDetails{}
 
+	// metrics of the applications of a namespace are read at once, by namespace then application
+	namespaceMetrics := make(map[string]map[string]map[string]ComponentMetrics)
+	for i := range applications.Items {
+		namespace := applications.Items[i].Namespace
+		if _, exist := namespaceMetrics[namespace]; !exist {
+			namespaceMetrics[namespace] = builder.getApplicationsComponentMetrics(namespace, applications.Items)
+		}
+	}
+
 	// TODO concurrent build response items
 	for i := range applications.Items {
-		item, err := builder.BuildApplicationDetails(&applications.Items[i])
+		application := &applications.Items[i]
+		item, err := builder.buildApplicationDetails(application, namespaceMetrics[application.Namespace][application.Name])
 
 		if err != nil {
 			return nil, err
@@ -227,11 +312,9 @@ func (builder *Builder) BuildApplicationListResponse(applications *v1alpha1.Appl
 	return apps, nil
 }
 
-func (builder *Builder) buildApplicationComponentStatus(application *v1alpha1.Application, resources *Resources) []ComponentStatus {
+func (builder *Builder) buildApplicationComponentStatus(application *v1alpha1.Application, resources *Resources, component2MetricMap map[string]ComponentMetrics) []ComponentStatus {
 	res := []ComponentStatus{}
 
-	componentKey2MetricMap := getComponentKey2MetricMap()
-
 	for i := range application.Spec.Components {
 		component := application.Spec.Components[i]
 
@@ -281,8 +364,7 @@ func (builder *Builder) buildApplicationComponentStatus(application *v1alpha1.Ap
 			//componentStatus.PodInfo = getPodsInfo(deployment.Status.Replicas, deployment.Spec.Replicas, pods)
 			//componentStatus.PodInfo.Warnings = filterPodWarningEvents(resources.EventList.Items, pods)
 
-			componentKey := fmt.Sprintf("%s-%s", application.Namespace, component.Name)
-			componentMetrics := componentKey2MetricMap[componentKey]
+			componentMetrics := component2MetricMap[component.Name]
 			componentStatus.ComponentMetrics = componentMetrics
 
 			componentStatus.Pods = getPods(pods, resources.EventList.Items, componentMetrics)
@@ -300,6 +382,73 @@ func (builder *Builder) buildApplicationComponentStatus(application *v1alpha1.Ap
 	return res
 }
 
+// metrics are not essential to the status, they are empty if the provider fails,
+// or if the caller is not allowed to read them
+func (builder *Builder) getComponentMetrics(application *v1alpha1.Application) map[string]ComponentMetrics {
+	provider, query := builder.metricsProviderAndQuery()
+
+	metrics, err := provider.GetComponentMetrics(application.Namespace, application.Name, componentNames(application), query)
+	if k8sErrors.IsForbidden(err) {
+		builder.Logger.Debug(err)
+		return nil
+	}
+
+	if err != nil {
+		builder.Logger.Error(err)
+		return nil
+	}
+
+	return metrics
+}
+
+// getApplicationsComponentMetrics is getComponentMetrics of the applications in the namespace, by application
+func (builder *Builder) getApplicationsComponentMetrics(namespace string, applications []v1alpha1.Application) map[string]map[string]ComponentMetrics {
+	provider, query := builder.metricsProviderAndQuery()
+
+	components := make(map[string][]string)
+	for i := range applications {
+		if applications[i].Namespace == namespace {
+			components[applications[i].Name] = componentNames(&applications[i])
+		}
+	}
+
+	metrics, err := provider.GetApplicationsComponentMetrics(namespace, components, query)
+	if k8sErrors.IsForbidden(err) {
+		builder.Logger.Debug(err)
+		return nil
+	}
+
+	if err != nil {
+		builder.Logger.Error(err)
+		return nil
+	}
+
+	return metrics
+}
+
+func (builder *Builder) metricsProviderAndQuery() (MetricsProvider, *MetricsQuery) {
+	provider := builder.MetricsProvider
+	if provider == nil {
+		provider = ScraperMetricsProvider
+	}
+
+	query := builder.MetricsQuery
+	if query == nil {
+		query = NewDefaultMetricsQuery()
+	}
+
+	return provider, query
+}
+
+func componentNames(application *v1alpha1.Application) []string {
+	components := make([]string, 0, len(application.Spec.Components))
+	for _, component := range application.Spec.Components {
+		components = append(components, component.Name)
+	}
+
+	return components
+}
+
 func getPods(pods []coreV1.Pod, events []coreV1.Event, componentMetrics ComponentMetrics) []PodStatus {
 	res := []PodStatus{}
 
@@ -177,4 +177,8 @@ type Builder struct {
 	K8sClient *kubernetes.Clientset
 	Logger    *logrus.Logger
 	Config    *rest.Config
+
+	// metrics of components are read from the scraper in the default range if they are not set
+	MetricsProvider MetricsProvider
+	MetricsQuery    *MetricsQuery
 }
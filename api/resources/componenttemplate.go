@@ -3,3 +3,8 @@ package resources
 import "github.com/kapp-staging/kapp/controller/api/v1alpha1"
 
 type CreateOrUpdateComponentTemplateRequest = v1alpha1.ComponentTemplateSpec
+
+type RenderComponentTemplateRequest struct {
+	// parameter name -> value, parameters not given here use their default values
+	Values map[string]string `json:"values"`
+}
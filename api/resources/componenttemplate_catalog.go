@@ -0,0 +1,208 @@
+package resources
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
+	"io"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// built-in ComponentTemplates, can be installed into a cluster with one api call.
+// Bump the version of a template when changing it, installed templates are only updated when the version differs.
+const componentTemplateCatalogYaml = `
+apiVersion: core.kapp.dev/v1alpha1
+kind: ComponentTemplate
+metadata:
+  name: nginx
+spec:
+  name: nginx
+  version: 0.1.0
+  description: nginx web server
+  workloadType: server
+  image: "nginx:${version}"
+  parameters:
+    - name: version
+      type: string
+      default: "1.17-alpine"
+      description: tag of the nginx image
+  ports:
+    - name: http
+      protocol: TCP
+      containerPort: 80
+      servicePort: 80
+  cpu: 100m
+  memory: 128Mi
+---
+apiVersion: core.kapp.dev/v1alpha1
+kind: ComponentTemplate
+metadata:
+  name: redis
+spec:
+  name: redis
+  version: 0.1.0
+  description: redis in-memory data store, data is persisted in a volume
+  workloadType: server
+  image: "redis:${version}"
+  parameters:
+    - name: version
+      type: string
+      default: "5.0-alpine"
+      description: tag of the redis image
+    - name: storage
+      type: quantity
+      default: 1Gi
+      description: size of the data volume
+  args:
+    - redis-server
+    - --appendonly
+    - "yes"
+  ports:
+    - name: redis
+      protocol: TCP
+      containerPort: 6379
+      servicePort: 6379
+  volumes:
+    - path: /data
+      size: "${storage}"
+      type: pvc
+  cpu: 100m
+  memory: 256Mi
+---
+apiVersion: core.kapp.dev/v1alpha1
+kind: ComponentTemplate
+metadata:
+  name: postgres
+spec:
+  name: postgres
+  version: 0.1.0
+  description: postgresql database
+  workloadType: server
+  image: "postgres:${version}"
+  parameters:
+    - name: version
+      type: string
+      default: "12-alpine"
+      description: tag of the postgres image
+    - name: password
+      type: string
+      required: true
+      description: password of the postgres superuser
+    - name: storage
+      type: quantity
+      default: 5Gi
+      description: size of the data volume
+  env:
+    - name: POSTGRES_PASSWORD
+      type: static
+      value: "${password}"
+    - name: PGDATA
+      type: static
+      value: /var/lib/postgresql/data/pgdata
+  ports:
+    - name: postgres
+      protocol: TCP
+      containerPort: 5432
+      servicePort: 5432
+  volumes:
+    - path: /var/lib/postgresql/data
+      size: "${storage}"
+      type: pvc
+  cpu: 250m
+  memory: 512Mi
+---
+apiVersion: core.kapp.dev/v1alpha1
+kind: ComponentTemplate
+metadata:
+  name: mysql
+spec:
+  name: mysql
+  version: 0.1.0
+  description: mysql database
+  workloadType: server
+  image: "mysql:${version}"
+  parameters:
+    - name: version
+      type: string
+      default: "8.0"
+      description: tag of the mysql image
+    - name: password
+      type: string
+      required: true
+      description: password of the mysql root user
+    - name: storage
+      type: quantity
+      default: 5Gi
+      description: size of the data volume
+  env:
+    - name: MYSQL_ROOT_PASSWORD
+      type: static
+      value: "${password}"
+  ports:
+    - name: mysql
+      protocol: TCP
+      containerPort: 3306
+      servicePort: 3306
+  volumes:
+    - path: /var/lib/mysql
+      size: "${storage}"
+      type: pvc
+  cpu: 250m
+  memory: 512Mi
+---
+apiVersion: core.kapp.dev/v1alpha1
+kind: ComponentTemplate
+metadata:
+  name: memcached
+spec:
+  name: memcached
+  version: 0.1.0
+  description: memcached in-memory key-value store
+  workloadType: server
+  image: "memcached:${version}"
+  parameters:
+    - name: version
+      type: string
+      default: "1.5-alpine"
+      description: tag of the memcached image
+    - name: memory
+      type: integer
+      default: "64"
+      description: memory for items in megabytes
+  args:
+    - -m
+    - "${memory}"
+  ports:
+    - name: memcached
+      protocol: TCP
+      containerPort: 11211
+      servicePort: 11211
+  cpu: 100m
+  memory: 128Mi
+`
+
+func GetComponentTemplateCatalog() ([]v1alpha1.ComponentTemplate, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewBufferString(componentTemplateCatalogYaml), 4096)
+
+	var templates []v1alpha1.ComponentTemplate
+
+	for {
+		var template v1alpha1.ComponentTemplate
+
+		if err := decoder.Decode(&template); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return nil, err
+		}
+
+		if err := v1alpha1.TryValidateComponentTemplate(template.Spec); err != nil {
+			return nil, fmt.Errorf("invalid template %s in catalog, %s", template.Name, err)
+		}
+
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
@@ -0,0 +1,25 @@
+package resources
+
+import (
+	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetComponentTemplateCatalog(t *testing.T) {
+	templates, err := GetComponentTemplateCatalog()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(templates))
+
+	for _, template := range templates {
+		assert.Equal(t, template.Name, template.Spec.Name)
+		assert.NotEmpty(t, template.Spec.Version)
+	}
+
+	postgres, err := v1alpha1.RenderComponentTemplate(templates[2].Spec, map[string]string{"password": "pwd"})
+	assert.Nil(t, err)
+	assert.Equal(t, "postgres:12-alpine", postgres.Image)
+	assert.Equal(t, uint32(5432), postgres.Ports[0].ContainerPort)
+	assert.Equal(t, "5Gi", postgres.Volumes[0].Size.String())
+}
@@ -0,0 +1,210 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kapp-staging/kapp/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LogQuery filters historical logs of pods
+type LogQuery struct {
+	// logs of pods in any of the namespaces, it should not be empty
+	Namespaces  []string
+	Application string
+	Component   string
+	Pod         string
+	Start       time.Time
+	End         time.Time
+	// free text the log line contains
+	Text string
+	// number of the newest entries to skip, and the size of the page
+	Offset int
+	Limit  int
+}
+
+type LogEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Namespace   string    `json:"namespace"`
+	Application string    `json:"application,omitempty"`
+	Component   string    `json:"component,omitempty"`
+	Pod         string    `json:"pod"`
+	Container   string    `json:"container,omitempty"`
+	Line        string    `json:"line"`
+}
+
+// LogPage is a page of entries, newest first
+type LogPage struct {
+	Entries []LogEntry `json:"logs"`
+	// there are older entries after this page
+	HasMore bool `json:"hasMore"`
+}
+
+// LogBackend is where historical logs are kept, it's installed as a dependency
+type LogBackend interface {
+	// QueryLogs returns the page of entries matching the query, newest first
+	QueryLogs(query *LogQuery) (*LogPage, error)
+}
+
+// newLogPage cuts the page out of the newest offset+limit+1 entries,
+// the extra one tells if there are more
+func newLogPage(entries []LogEntry, query *LogQuery) *LogPage {
+	if len(entries) <= query.Offset {
+		return &LogPage{Entries: []LogEntry{}}
+	}
+
+	entries = entries[query.Offset:]
+	page := &LogPage{Entries: entries, HasMore: len(entries) > query.Limit}
+
+	if page.HasMore {
+		page.Entries = entries[:query.Limit]
+	}
+
+	return page
+}
+
+const (
+	lokiNamespace   = "kapp-loki"
+	lokiServiceName = "loki"
+	lokiServicePort = "3100"
+)
+
+// MaxLokiResultWindow is the max offset+limit of a query of loki, less than MaxLogResultWindow,
+// the default max_entries_limit_per_query of loki, which the loki dependency doesn't change
+const MaxLokiResultWindow = 5000
+
+// lokiLogBackend queries the loki installed by the loki dependency, through the service proxy of the api server.
+// labels are set by promtail, see resources/loki_1.0.0.yaml of the controller
+type lokiLogBackend struct {
+	k8sClient *kubernetes.Clientset
+}
+
+func NewLokiLogBackend(k8sClient *kubernetes.Clientset) LogBackend {
+	return &lokiLogBackend{k8sClient: k8sClient}
+}
+
+// loki has no offset, the entries before the page are queried and dropped
+func (b *lokiLogBackend) QueryLogs(query *LogQuery) (*LogPage, error) {
+	if query.Offset+query.Limit >= MaxLokiResultWindow {
+		return nil, errors.NewBadRequest("only the newest " + strconv.Itoa(MaxLokiResultWindow-1) +
+			" entries can be paged, narrow the time range")
+	}
+
+	limit := query.Offset + query.Limit + 1
+
+	res, err := b.k8sClient.CoreV1().Services(lokiNamespace).
+		ProxyGet("http", lokiServiceName, lokiServicePort, "/loki/api/v1/query_range", map[string]string{
+			"query":     lokiLogQL(query),
+			"start":     strconv.FormatInt(query.Start.UnixNano(), 10),
+			"end":       strconv.FormatInt(query.End.UnixNano(), 10),
+			"limit":     strconv.Itoa(limit),
+			"direction": "backward",
+		}).DoRaw()
+
+	if err != nil {
+		return nil, fmt.Errorf("fail to query loki: %s", err)
+	}
+
+	entries, err := parseLokiStreams(res, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLogPage(entries, query), nil
+}
+
+// e.g. {namespace="kapp-ns",kapp_application="app",kapp_component="web"} |= "error"
+func lokiLogQL(query *LogQuery) string {
+	var matchers []string
+
+	if len(query.Namespaces) == 1 {
+		matchers = append(matchers, "namespace="+strconv.Quote(query.Namespaces[0]))
+	} else {
+		quoted := make([]string, len(query.Namespaces))
+		for i, ns := range query.Namespaces {
+			quoted[i] = regexp.QuoteMeta(ns)
+		}
+
+		matchers = append(matchers, "namespace=~"+strconv.Quote(strings.Join(quoted, "|")))
+	}
+
+	if query.Application != "" {
+		matchers = append(matchers, "kapp_application="+strconv.Quote(query.Application))
+	}
+
+	if query.Component != "" {
+		matchers = append(matchers, "kapp_component="+strconv.Quote(query.Component))
+	}
+
+	if query.Pod != "" {
+		matchers = append(matchers, "pod="+strconv.Quote(query.Pod))
+	}
+
+	logQL := "{" + strings.Join(matchers, ",") + "}"
+
+	if query.Text != "" {
+		logQL += " |= " + strconv.Quote(query.Text)
+	}
+
+	return logQL
+}
+
+type lokiQueryResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Stream map[string]string `json:"stream"`
+			// [timestamp in nanoseconds, line]
+			Values [][2]string `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// entries of all streams, newest first
+func parseLokiStreams(res []byte, limit int) ([]LogEntry, error) {
+	var resp lokiQueryResponse
+	if err := json.Unmarshal(res, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("loki query status: %s", resp.Status)
+	}
+
+	entries := []LogEntry{}
+	for _, stream := range resp.Data.Result {
+		for _, value := range stream.Values {
+			ns, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, LogEntry{
+				Timestamp:   time.Unix(0, ns),
+				Namespace:   stream.Stream["namespace"],
+				Application: stream.Stream["kapp_application"],
+				Component:   stream.Stream["kapp_component"],
+				Pod:         stream.Stream["pod"],
+				Container:   stream.Stream["container"],
+				Line:        value[1],
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,187 @@
+package resources
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the elasticsearch of the log dependency, created by reconcileES of the controller.
+// names of the service and the secrets are given by the elastic operator
+const (
+	esNamespace     = "kapp-log"
+	esURL           = "https://elasticsearch-es-http.kapp-log.svc:9200"
+	esUserSecret    = "elasticsearch-es-elastic-user"
+	esUser          = "elastic"
+	esCertSecret    = "elasticsearch-es-http-certs-public"
+	esFilebeatIndex = "filebeat-*"
+)
+
+// MaxLogResultWindow is the max offset+limit of a query, the default index.max_result_window of elasticsearch
+const MaxLogResultWindow = 10000
+
+// elasticsearchLogBackend queries the elasticsearch installed by the log dependency,
+// where filebeat ships the logs with the metadata of pods.
+// The service proxy of the api server drops the Authorization header,
+// so the elasticsearch is requested directly, which only works in the cluster.
+type elasticsearchLogBackend struct {
+	httpClient *http.Client
+	password   string
+}
+
+// NewElasticsearchLogBackend reads the password of the elastic user and the ca of elasticsearch with the client
+func NewElasticsearchLogBackend(k8sClient *kubernetes.Clientset) (LogBackend, error) {
+	userSecret, err := k8sClient.CoreV1().Secrets(esNamespace).Get(esUserSecret, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	certSecret, err := k8sClient.CoreV1().Secrets(esNamespace).Get(esCertSecret, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certSecret.Data["ca.crt"]) {
+		return nil, fmt.Errorf("no ca in secret %s/%s", esNamespace, esCertSecret)
+	}
+
+	return &elasticsearchLogBackend{
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		password: string(userSecret.Data[esUser]),
+	}, nil
+}
+
+func (b *elasticsearchLogBackend) QueryLogs(query *LogQuery) (*LogPage, error) {
+	body, err := json.Marshal(esSearchBody(query))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, esURL+"/"+esFilebeatIndex+"/_search", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(esUser, b.password)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to query elasticsearch: %s", err)
+	}
+	defer resp.Body.Close()
+
+	res, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to query elasticsearch, status %d: %s", resp.StatusCode, res)
+	}
+
+	entries, err := parseEsHits(res)
+	if err != nil {
+		return nil, err
+	}
+
+	// one more entry is requested to tell if there are more
+	return newLogPage(entries, &LogQuery{Limit: query.Limit}), nil
+}
+
+// esSearchBody is a search of the filebeat documents, newest first.
+// Filters don't affect the score, only the free text is matched as a phrase.
+func esSearchBody(query *LogQuery) map[string]interface{} {
+	filters := []interface{}{
+		map[string]interface{}{"terms": map[string]interface{}{"kubernetes.namespace": query.Namespaces}},
+		map[string]interface{}{"range": map[string]interface{}{
+			"@timestamp": map[string]interface{}{
+				"gte":    query.Start.UTC().Format(time.RFC3339Nano),
+				"lte":    query.End.UTC().Format(time.RFC3339Nano),
+				"format": "strict_date_optional_time",
+			},
+		}},
+	}
+
+	terms := []struct{ field, value string }{
+		{"kubernetes.labels.kapp-application", query.Application},
+		{"kubernetes.labels.kapp-component", query.Component},
+		{"kubernetes.pod.name", query.Pod},
+	}
+
+	for _, term := range terms {
+		if term.value != "" {
+			filters = append(filters, map[string]interface{}{"term": map[string]interface{}{term.field: term.value}})
+		}
+	}
+
+	boolQuery := map[string]interface{}{"filter": filters}
+	if query.Text != "" {
+		boolQuery["must"] = []interface{}{
+			map[string]interface{}{"match_phrase": map[string]interface{}{"message": query.Text}},
+		}
+	}
+
+	return map[string]interface{}{
+		"from":  query.Offset,
+		"size":  query.Limit + 1,
+		"sort":  []interface{}{map[string]interface{}{"@timestamp": map[string]interface{}{"order": "desc"}}},
+		"query": map[string]interface{}{"bool": boolQuery},
+	}
+}
+
+type esSearchResponse struct {
+	Hits struct {
+		Hits []struct {
+			Source struct {
+				Timestamp  time.Time `json:"@timestamp"`
+				Message    string    `json:"message"`
+				Kubernetes struct {
+					Namespace string            `json:"namespace"`
+					Labels    map[string]string `json:"labels"`
+					Pod       struct {
+						Name string `json:"name"`
+					} `json:"pod"`
+					Container struct {
+						Name string `json:"name"`
+					} `json:"container"`
+				} `json:"kubernetes"`
+			} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func parseEsHits(res []byte) ([]LogEntry, error) {
+	var resp esSearchResponse
+	if err := json.Unmarshal(res, &resp); err != nil {
+		return nil, err
+	}
+
+	entries := make([]LogEntry, 0, len(resp.Hits.Hits))
+	for _, hit := range resp.Hits.Hits {
+		source := hit.Source
+		entries = append(entries, LogEntry{
+			Timestamp:   source.Timestamp,
+			Namespace:   source.Kubernetes.Namespace,
+			Application: source.Kubernetes.Labels["kapp-application"],
+			Component:   source.Kubernetes.Labels["kapp-component"],
+			Pod:         source.Kubernetes.Pod.Name,
+			Container:   source.Kubernetes.Container.Name,
+			Line:        source.Message,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,91 @@
+package resources
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEsSearchBody(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	body, err := json.Marshal(esSearchBody(&LogQuery{
+		Namespaces:  []string{"kapp-a", "kapp-b"},
+		Application: "app",
+		Pod:         "web-0",
+		Start:       start,
+		End:         start.Add(time.Hour),
+		Text:        "timeout",
+		Offset:      20,
+		Limit:       10,
+	}))
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+  "from": 20,
+  "size": 11,
+  "sort": [{"@timestamp": {"order": "desc"}}],
+  "query": {
+    "bool": {
+      "filter": [
+        {"terms": {"kubernetes.namespace": ["kapp-a", "kapp-b"]}},
+        {"range": {"@timestamp": {"gte": "2020-01-01T00:00:00Z", "lte": "2020-01-01T01:00:00Z", "format": "strict_date_optional_time"}}},
+        {"term": {"kubernetes.labels.kapp-application": "app"}},
+        {"term": {"kubernetes.pod.name": "web-0"}}
+      ],
+      "must": [{"match_phrase": {"message": "timeout"}}]
+    }
+  }
+}`, string(body))
+}
+
+func TestParseEsHits(t *testing.T) {
+	res := []byte(`{
+  "hits": {
+    "total": {"value": 2, "relation": "eq"},
+    "hits": [
+      {
+        "_source": {
+          "@timestamp": "2020-01-01T00:00:02.000Z",
+          "message": "b",
+          "kubernetes": {
+            "namespace": "kapp-ns",
+            "labels": {"kapp-application": "app", "kapp-component": "web"},
+            "pod": {"name": "web-0"},
+            "container": {"name": "web"}
+          }
+        }
+      },
+      {
+        "_source": {
+          "@timestamp": "2020-01-01T00:00:01.000Z",
+          "message": "a",
+          "kubernetes": {"namespace": "kapp-ns", "pod": {"name": "job-0"}}
+        }
+      }
+    ]
+  }
+}`)
+
+	entries, err := parseEsHits(res)
+	assert.Nil(t, err)
+	assert.Equal(t, []LogEntry{
+		{
+			Timestamp:   time.Date(2020, 1, 1, 0, 0, 2, 0, time.UTC),
+			Namespace:   "kapp-ns",
+			Application: "app",
+			Component:   "web",
+			Pod:         "web-0",
+			Container:   "web",
+			Line:        "b",
+		},
+		{
+			Timestamp: time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC),
+			Namespace: "kapp-ns",
+			Pod:       "job-0",
+			Line:      "a",
+		},
+	}, entries)
+}
@@ -0,0 +1,79 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestLokiLogQL(t *testing.T) {
+	assert.Equal(t, `{namespace="kapp-ns"}`, lokiLogQL(&LogQuery{Namespaces: []string{"kapp-ns"}}))
+	assert.Equal(t, `{namespace=~"kapp-a|kapp-b\\.c"}`, lokiLogQL(&LogQuery{Namespaces: []string{"kapp-a", "kapp-b.c"}}))
+
+	assert.Equal(t,
+		`{namespace="kapp-ns",kapp_application="app",kapp_component="web",pod="web-0"} |= "say \"hi\""`,
+		lokiLogQL(&LogQuery{
+			Namespaces:  []string{"kapp-ns"},
+			Application: "app",
+			Component:   "web",
+			Pod:         "web-0",
+			Text:        `say "hi"`,
+		}))
+}
+
+func TestParseLokiStreams(t *testing.T) {
+	res := []byte(`{
+  "status": "success",
+  "data": {
+    "resultType": "streams",
+    "result": [
+      {
+        "stream": {"namespace": "kapp-ns", "kapp_application": "app", "kapp_component": "web", "pod": "web-0", "container": "web"},
+        "values": [["1580000003000000000", "c"], ["1580000001000000000", "a"]]
+      },
+      {
+        "stream": {"namespace": "kapp-ns", "pod": "web-1", "container": "web"},
+        "values": [["1580000002000000000", "b"]]
+      }
+    ]
+  }
+}`)
+
+	entries, err := parseLokiStreams(res, 2)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "c", entries[0].Line)
+	assert.Equal(t, "web", entries[0].Component)
+	assert.Equal(t, time.Unix(0, 1580000003000000000), entries[0].Timestamp)
+	assert.Equal(t, "b", entries[1].Line)
+	assert.Equal(t, "web-1", entries[1].Pod)
+
+	_, err = parseLokiStreams([]byte(`{"status": "error"}`), 10)
+	assert.NotNil(t, err)
+}
+
+func TestNewLogPage(t *testing.T) {
+	entries := []LogEntry{{Line: "e"}, {Line: "d"}, {Line: "c"}, {Line: "b"}, {Line: "a"}}
+
+	page := newLogPage(entries, &LogQuery{Offset: 2, Limit: 2})
+	assert.True(t, page.HasMore)
+	assert.Equal(t, []LogEntry{{Line: "c"}, {Line: "b"}}, page.Entries)
+
+	page = newLogPage(entries, &LogQuery{Offset: 3, Limit: 2})
+	assert.False(t, page.HasMore)
+	assert.Equal(t, []LogEntry{{Line: "b"}, {Line: "a"}}, page.Entries)
+
+	page = newLogPage(entries, &LogQuery{Offset: 6, Limit: 2})
+	assert.False(t, page.HasMore)
+	assert.Empty(t, page.Entries)
+}
+
+func TestLokiResultWindow(t *testing.T) {
+	backend := NewLokiLogBackend(nil)
+
+	// rejected before loki is queried
+	_, err := backend.QueryLogs(&LogQuery{Namespaces: []string{"kapp-ns"}, Offset: 4950, Limit: 50})
+	assert.True(t, k8sErrors.IsBadRequest(err))
+}
@@ -4,8 +4,8 @@ import (
 	"container/heap"
 	"context"
 	"fmt"
-	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
 	"github.com/influxdata/influxdb/pkg/slices"
+	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -44,19 +44,15 @@ import (
 //	return channel
 //}
 
-// componentA -> {pod1 -> metric, metric, ...}
-//               {pod2 -> metric, metric, ...}
-var componentMetricDB = make(map[string]map[string][]metricv1beta1.PodMetrics)
-
-// nodeName1 -> {metricT1, metricT2, ...}
-// nodeName2 -> {metricT1, ...}
-var nodeMetricDB = make(map[string][]metricv1beta1.NodeMetrics)
+// pods of components, keyed by the component, e.g. namespace-component, and the pod
+var podMetricsStore = newMetricsStore(defaultMetricsTiers)
 
-// todo lock
+// nodes, keyed by the node
+var nodeMetricsStore = newMetricsStore(defaultMetricsTiers)
 
 var metricResolution = 5 * time.Second
 
-//var metricResolution = 30 * time.Second
+// var metricResolution = 30 * time.Second
 var metricDuration = 15 * time.Minute
 
 func StartMetricsScraper(ctx context.Context, config *rest.Config) error {
@@ -78,81 +74,93 @@ func StartMetricsScraper(ctx context.Context, config *rest.Config) error {
 			select {
 			case <-ctx.Done():
 				ticker.Stop()
+				return
 			case <-ticker.C:
-				// get all kapp-applications
-				var appList v1alpha1.ApplicationList
-				err = k8sClient.RESTClient().Get().AbsPath("/apis/core.kapp.dev/v1alpha1/applications").Do().Into(&appList)
-				if err != nil {
-					fmt.Errorf("fail get applications, err: %s", err)
-				}
-
-				//fmt.Println("apps found:", len(appList.Items))
-
-				// get metrics under apps' ns
-				ns2MetricsListMap := make(map[string]*metricv1beta1.PodMetricsList)
-				for _, app := range appList.Items {
-					if _, exist := ns2MetricsListMap[app.Namespace]; exist {
-						continue
-					}
+				scrapeMetrics(k8sClient, metricClient)
+			}
+		}
+	}()
 
-					metricsList, err := metricClient.PodMetricses(app.Namespace).List(metav1.ListOptions{})
-					if err != nil {
-						fmt.Printf("fail to list podMetrics for ns: %s, err: %s\n", app.Namespace, err)
-					}
+	return nil
+}
 
-					ns2MetricsListMap[app.Namespace] = metricsList
-					//fmt.Printf("metrics found under ns(%s): %d\n", app.Namespace, len(metricsList.Items))
-				}
+func scrapeMetrics(k8sClient *kubernetes.Clientset, metricClient *mclientv1beta1.MetricsV1beta1Client) {
+	// series are only evicted after a complete scrape, not because of a failed request
+	complete := true
 
-				for _, app := range appList.Items {
-					metricsList, exist := ns2MetricsListMap[app.Namespace]
-					if !exist {
-						continue
-					}
+	// get all kapp-applications
+	var appList v1alpha1.ApplicationList
+	err := k8sClient.RESTClient().Get().AbsPath("/apis/core.kapp.dev/v1alpha1/applications").Do().Into(&appList)
+	if err != nil {
+		fmt.Printf("fail get applications, err: %s\n", err)
+		complete = false
+	}
 
-					cacheMetricsForAppIntoLocalDB(app, metricsList)
-				}
+	// get metrics under apps' ns
+	ns2MetricsListMap := make(map[string]*metricv1beta1.PodMetricsList)
+	for _, app := range appList.Items {
+		if _, exist := ns2MetricsListMap[app.Namespace]; exist {
+			continue
+		}
 
-				// nodes metrics
-				nodeMetricsList, err := metricClient.NodeMetricses().List(metav1.ListOptions{})
-				if err != nil {
-					fmt.Errorf("fail get nodes, err: %s", err)
-				}
+		metricsList, err := metricClient.PodMetricses(app.Namespace).List(metav1.ListOptions{})
+		if err != nil {
+			fmt.Printf("fail to list podMetrics for ns: %s, err: %s\n", app.Namespace, err)
+			complete = false
+			continue
+		}
 
-				//nodeMetricsCnt := 0
-				//if nodeMetricsList != nil {
-				//	nodeMetricsCnt = len(nodeMetricsList.Items)
-				//}
-				//fmt.Printf("node metrics found %d\n", nodeMetricsCnt)
+		ns2MetricsListMap[app.Namespace] = metricsList
+	}
 
-				cacheMetricsForNodesIntoLocalDB(nodeMetricsList)
-			}
+	alivePods := make(map[seriesKey]bool)
+	for _, app := range appList.Items {
+		metricsList, exist := ns2MetricsListMap[app.Namespace]
+		if !exist {
+			continue
 		}
-	}()
 
-	return nil
-}
+		cacheMetricsForAppIntoLocalDB(app, metricsList, alivePods)
+	}
+
+	if complete {
+		evictDeadSeries(podMetricsStore, alivePods, time.Now())
+	}
 
-func cacheMetricsForNodesIntoLocalDB(nodeMetricsList *metricv1beta1.NodeMetricsList) {
-	if nodeMetricsList == nil {
+	// nodes metrics
+	nodeMetricsList, err := metricClient.NodeMetricses().List(metav1.ListOptions{})
+	if err != nil {
+		fmt.Printf("fail get nodes, err: %s\n", err)
 		return
 	}
 
-	for _, nodeMetrics := range nodeMetricsList.Items {
-		nodeName := nodeMetrics.Name
+	aliveNodes := cacheMetricsForNodesIntoLocalDB(nodeMetricsList)
+	evictDeadSeries(nodeMetricsStore, aliveNodes, time.Now())
+}
 
-		v := nodeMetricDB[nodeName]
+// series of deleted pods and nodes are kept for the retention of the store,
+// so the history of a component still covers the pods replaced by its rollouts
+func evictDeadSeries(store *metricsStore, alive map[seriesKey]bool, now time.Time) {
+	store.evict(alive, now.Add(-store.retention()))
+}
 
-		nodeMetrics = alignMetricsByMinute(nodeMetrics)
-		if len(v) > 0 && v[len(v)-1].Timestamp.Unix() == nodeMetrics.Timestamp.Unix() {
-			// ignore duplicate
-			continue
-		}
+func cacheMetricsForNodesIntoLocalDB(nodeMetricsList *metricv1beta1.NodeMetricsList) map[seriesKey]bool {
+	alive := make(map[seriesKey]bool, len(nodeMetricsList.Items))
 
-		v = append(v, nodeMetrics)
+	for _, nodeMetrics := range nodeMetricsList.Items {
+		key := seriesKey{Name: nodeMetrics.Name}
+		alive[key] = true
 
-		nodeMetricDB[nodeName] = v
+		// duplicates are ignored by the store
+		nodeMetrics = alignMetricsByMinute(nodeMetrics)
+		nodeMetricsStore.add(key, usagePoint{
+			Timestamp: nodeMetrics.Timestamp.Time,
+			CPU:       uint64(nodeMetrics.Usage.Cpu().MilliValue()),
+			Memory:    uint64(nodeMetrics.Usage.Memory().Value()),
+		})
 	}
+
+	return alive
 }
 
 func alignMetricsByMinute(metrics metricv1beta1.NodeMetrics) metricv1beta1.NodeMetrics {
@@ -163,16 +171,13 @@ func alignMetricsByMinute(metrics metricv1beta1.NodeMetrics) metricv1beta1.NodeM
 	return metrics
 }
 
-func cacheMetricsForAppIntoLocalDB(app v1alpha1.Application, metricsList *metricv1beta1.PodMetricsList) {
+// cacheMetricsForAppIntoLocalDB adds the pods of the components of the app into the store,
+// and marks them alive
+func cacheMetricsForAppIntoLocalDB(app v1alpha1.Application, metricsList *metricv1beta1.PodMetricsList, alive map[seriesKey]bool) {
 
 	for _, component := range app.Spec.Components {
 		componentKey := fmt.Sprintf("%s-%s", app.Namespace, component.Name)
 
-		if _, exist := componentMetricDB[componentKey]; !exist {
-			// init map: pod -> metrics time serials
-			componentMetricDB[componentKey] = make(map[string][]metricv1beta1.PodMetrics)
-		}
-
 		for _, podMetrics := range metricsList.Items {
 
 			if podMetrics.Namespace != app.Namespace {
@@ -195,29 +200,11 @@ func cacheMetricsForAppIntoLocalDB(app v1alpha1.Application, metricsList *metric
 				continue
 			}
 
-			vPodMetricsSlice := componentMetricDB[componentKey][podMetrics.Name]
-
-			// ignore if ts is same
-			if len(vPodMetricsSlice) > 0 &&
-				vPodMetricsSlice[len(vPodMetricsSlice)-1].Timestamp.Unix() == podMetrics.Timestamp.Unix() {
-				continue
-			}
-
-			vPodMetricsSlice = append(vPodMetricsSlice, podMetrics)
+			key := seriesKey{Group: componentKey, Name: podMetrics.Name}
+			alive[key] = true
 
-			// purge old metrics
-			for len(vPodMetricsSlice) > 0 {
-				cutTs := time.Now().Add(-1 * metricDuration)
-				if vPodMetricsSlice[0].Timestamp.Time.After(cutTs) {
-					break
-				}
-
-				vPodMetricsSlice = vPodMetricsSlice[1:]
-			}
-
-			componentMetricDB[componentKey][podMetrics.Name] = vPodMetricsSlice
-
-			//fmt.Println(fmt.Sprintf("%s -> %s", componentKey, podMetrics.Name), vPodMetricsSlice, len(vPodMetricsSlice))
+			// duplicates are ignored by the store
+			podMetricsStore.add(key, podUsage(podMetrics))
 		}
 	}
 }
@@ -234,17 +221,22 @@ type NodeMetricHistories struct {
 	Memory MetricHistory `json:"memory"`
 }
 
-func GetFilteredNodeMetrics(nodes []string) NodesMetricHistories {
+func GetFilteredNodeMetrics(nodes []string, query *MetricsQuery) NodesMetricHistories {
 	var nodeMetricHistoriesList []NodeMetricHistories
 
 	var cpuHistoryList []MetricHistory
 	var memHistoryList []MetricHistory
-	for nodeName, nodeMetricsList := range nodeMetricDB {
-		if !slices.Exists(nodes, nodeName) {
-			continue
-		}
 
-		oneNode := getNodeMetricHistories(nodeName, nodeMetricsList)
+	histories := nodeMetricsStore.histories(func(key seriesKey) bool {
+		return slices.Exists(nodes, key.Name)
+	}, query)
+
+	for key, nodeHistories := range histories {
+		oneNode := NodeMetricHistories{
+			Name:   key.Name,
+			CPU:    nodeHistories.CPU,
+			Memory: nodeHistories.Memory,
+		}
 		nodeMetricHistoriesList = append(nodeMetricHistoriesList, oneNode)
 
 		cpuHistoryList = append(cpuHistoryList, oneNode.CPU)
@@ -261,43 +253,26 @@ func GetFilteredNodeMetrics(nodes []string) NodesMetricHistories {
 	}
 }
 
-func getNodeMetricHistories(name string, list []metricv1beta1.NodeMetrics) NodeMetricHistories {
-	var memHistory MetricHistory
-	var cpuHistory MetricHistory
-	for _, nodeMetric := range list {
-		memHistory = append(memHistory, MetricPoint{
-			Timestamp: nodeMetric.Timestamp.Time,
-			Value:     uint64(nodeMetric.Usage.Memory().Value()),
-		})
-
-		cpuHistory = append(cpuHistory, MetricPoint{
-			Timestamp: nodeMetric.Timestamp.Time,
-			Value:     uint64(nodeMetric.Usage.Cpu().Value()),
-		})
-	}
-
-	return NodeMetricHistories{
-		Name:   name,
-		Memory: memHistory,
-		CPU:    cpuHistory,
-	}
-
-}
-
 // componentName -> componentMetricsSum
-func getComponentKey2MetricMap() map[string]ComponentMetrics {
+func getComponentKey2MetricMap(query *MetricsQuery) map[string]ComponentMetrics {
 	rst := make(map[string]ComponentMetrics)
 
-	for compName, podMap := range componentMetricDB {
-		compMetrics := ComponentMetrics{
-			Name: compName,
-			Pods: make(map[string]MetricHistories),
-		}
+	histories := podMetricsStore.histories(func(seriesKey) bool { return true }, query)
 
-		for podName, metricsHistory := range podMap {
-			compMetrics.Pods[podName] = toMetricSum(metricsHistory)
+	for key, podHistories := range histories {
+		compMetrics, exist := rst[key.Group]
+		if !exist {
+			compMetrics = ComponentMetrics{
+				Name: key.Group,
+				Pods: make(map[string]MetricHistories),
+			}
 		}
 
+		compMetrics.Pods[key.Name] = podHistories
+		rst[key.Group] = compMetrics
+	}
+
+	for compName, compMetrics := range rst {
 		podsMetricsSum := aggregatePodsSum(compMetrics.Pods)
 		compMetrics.Memory = podsMetricsSum.Memory
 		compMetrics.CPU = podsMetricsSum.CPU
@@ -442,30 +417,17 @@ func (pq *PriorityQueue) Pop() interface{} {
 //	heap.Fix(pq, item.index)
 //}
 
-func toMetricSum(history []metricv1beta1.PodMetrics) MetricHistories {
-	sum := MetricHistories{}
-
-	for _, podMetrics := range history {
-		var memSum uint64
-		var cpuSum uint64
+// podUsage is the sum of the usage of the containers of the pod
+func podUsage(podMetrics metricv1beta1.PodMetrics) usagePoint {
+	point := usagePoint{Timestamp: podMetrics.Timestamp.Time}
 
-		for _, container := range podMetrics.Containers {
-			mem := container.Usage.Memory()
-			cpu := container.Usage.Cpu()
+	for _, container := range podMetrics.Containers {
+		mem := container.Usage.Memory()
+		cpu := container.Usage.Cpu()
 
-			memSum += uint64(mem.Value())
-			cpuSum += uint64(cpu.Value())
-		}
-
-		sum.Memory = append(sum.Memory, MetricPoint{
-			Timestamp: podMetrics.Timestamp.Time,
-			Value:     memSum,
-		})
-		sum.CPU = append(sum.CPU, MetricPoint{
-			Timestamp: podMetrics.Timestamp.Time,
-			Value:     cpuSum,
-		})
+		point.Memory += uint64(mem.Value())
+		point.CPU += uint64(cpu.MilliValue())
 	}
 
-	return sum
+	return point
 }
@@ -0,0 +1,332 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// prometheus keeps at most 11000 points of a series in a query
+const maxPrometheusPoints = 11000
+
+// the step when the query doesn't set one, about this many points are returned
+const defaultPrometheusPoints = 120
+
+// scrape interval of the kubelet in kube-prometheus, rates are computed over a longer window
+const minPrometheusRateWindow = time.Minute
+
+// prometheusMetricsProvider queries the prometheus installed by the kube-prometheus dependency,
+// with the cadvisor metrics of kubelet, and the pod labels of kube-state-metrics
+type prometheusMetricsProvider struct {
+	k8sClient *kubernetes.Clientset
+}
+
+func NewPrometheusMetricsProvider(k8sClient *kubernetes.Clientset) MetricsProvider {
+	return &prometheusMetricsProvider{k8sClient: k8sClient}
+}
+
+// usage of the root cgroup of nodes, the same as metrics-server
+func (p *prometheusMetricsProvider) GetNodeMetrics(nodes []string, query *MetricsQuery) (NodesMetricHistories, error) {
+	step := prometheusStep(query)
+
+	cpu, err := p.queryRange(fmt.Sprintf(
+		`sum by (node) (rate(container_cpu_usage_seconds_total{job="kubelet",id="/"}[%s])) * 1000`,
+		prometheusRateWindow(step)), "node", query, step)
+	if err != nil {
+		return NodesMetricHistories{}, err
+	}
+
+	memory, err := p.queryRange(
+		`sum by (node) (container_memory_working_set_bytes{job="kubelet",id="/"})`, "node", query, step)
+	if err != nil {
+		return NodesMetricHistories{}, err
+	}
+
+	var nodeMetricHistoriesList []NodeMetricHistories
+	var cpuHistoryList []MetricHistory
+	var memHistoryList []MetricHistory
+
+	for _, node := range nodes {
+		if cpu[node] == nil && memory[node] == nil {
+			continue
+		}
+
+		nodeMetricHistoriesList = append(nodeMetricHistoriesList, NodeMetricHistories{
+			Name:   node,
+			CPU:    cpu[node],
+			Memory: memory[node],
+		})
+
+		cpuHistoryList = append(cpuHistoryList, cpu[node])
+		memHistoryList = append(memHistoryList, memory[node])
+	}
+
+	return NodesMetricHistories{
+		CPU:    aggregateHistoryList(cpuHistoryList),
+		Memory: aggregateHistoryList(memHistoryList),
+		Nodes:  nodeMetricHistoriesList,
+	}, nil
+}
+
+// usage of containers, joined with the kapp labels of their pods
+func (p *prometheusMetricsProvider) GetComponentMetrics(namespace, application string, components []string, query *MetricsQuery) (map[string]ComponentMetrics, error) {
+	metrics, err := p.queryComponentMetrics(namespace, "label_kapp_application="+strconv.Quote(application), query)
+	if err != nil {
+		return nil, err
+	}
+
+	return prometheusComponentMetrics(metrics[application], components), nil
+}
+
+// the applications of the namespace are read by the same queries, instead of two queries of each application
+func (p *prometheusMetricsProvider) GetApplicationsComponentMetrics(namespace string, applications map[string][]string, query *MetricsQuery) (map[string]map[string]ComponentMetrics, error) {
+	metrics, err := p.queryComponentMetrics(namespace, `label_kapp_application!=""`, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rst := make(map[string]map[string]ComponentMetrics, len(applications))
+	for application, components := range applications {
+		rst[application] = prometheusComponentMetrics(metrics[application], components)
+	}
+
+	return rst, nil
+}
+
+// application -> component -> pod -> histories, of the pods selected by the matcher of the application label
+func (p *prometheusMetricsProvider) queryComponentMetrics(namespace, applicationMatcher string, query *MetricsQuery) (map[string]map[string]map[string]MetricHistories, error) {
+	step := prometheusStep(query)
+	podLabels := fmt.Sprintf(
+		`max by (namespace, pod, label_kapp_application, label_kapp_component) (kube_pod_labels{namespace=%s,%s})`,
+		strconv.Quote(namespace), applicationMatcher)
+	containers := fmt.Sprintf(`{job="kubelet",namespace=%s,container!="",container!="POD"}`, strconv.Quote(namespace))
+
+	cpu, err := p.queryRangeByComponentPod(fmt.Sprintf(
+		`sum by (namespace, label_kapp_application, label_kapp_component, pod) (rate(container_cpu_usage_seconds_total%s[%s]) * on (namespace, pod) group_left(label_kapp_application, label_kapp_component) %s) * 1000`,
+		containers, prometheusRateWindow(step), podLabels), query, step)
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := p.queryRangeByComponentPod(fmt.Sprintf(
+		`sum by (namespace, label_kapp_application, label_kapp_component, pod) (container_memory_working_set_bytes%s * on (namespace, pod) group_left(label_kapp_application, label_kapp_component) %s)`,
+		containers, podLabels), query, step)
+	if err != nil {
+		return nil, err
+	}
+
+	rst := make(map[string]map[string]map[string]MetricHistories)
+	pods := func(application, component string) map[string]MetricHistories {
+		if rst[application] == nil {
+			rst[application] = make(map[string]map[string]MetricHistories)
+		}
+
+		if rst[application][component] == nil {
+			rst[application][component] = make(map[string]MetricHistories)
+		}
+
+		return rst[application][component]
+	}
+
+	for _, s := range cpu {
+		histories := pods(s.application, s.component)
+		h := histories[s.pod]
+		h.CPU = s.history
+		histories[s.pod] = h
+	}
+
+	for _, s := range memory {
+		histories := pods(s.application, s.component)
+		h := histories[s.pod]
+		h.Memory = s.history
+		histories[s.pod] = h
+	}
+
+	return rst, nil
+}
+
+// the components which have pods with metrics
+func prometheusComponentMetrics(metrics map[string]map[string]MetricHistories, components []string) map[string]ComponentMetrics {
+	rst := make(map[string]ComponentMetrics, len(components))
+
+	for _, component := range components {
+		pods := metrics[component]
+		if len(pods) == 0 {
+			continue
+		}
+
+		rst[component] = ComponentMetrics{
+			Name:            component,
+			MetricHistories: aggregatePodsSum(pods),
+			Pods:            pods,
+		}
+	}
+
+	return rst
+}
+
+type componentPodSeries struct {
+	application string
+	component   string
+	pod         string
+	history     MetricHistory
+}
+
+func (p *prometheusMetricsProvider) queryRangeByComponentPod(promQL string, query *MetricsQuery, step time.Duration) ([]componentPodSeries, error) {
+	res, err := p.doQueryRange(promQL, query, step)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := parsePrometheusMatrix(res)
+	if err != nil {
+		return nil, err
+	}
+
+	rst := make([]componentPodSeries, 0, len(series))
+	for _, s := range series {
+		rst = append(rst, componentPodSeries{
+			application: s.labels["label_kapp_application"],
+			component:   s.labels["label_kapp_component"],
+			pod:         s.labels["pod"],
+			history:     s.history,
+		})
+	}
+
+	return rst, nil
+}
+
+// value of the label -> history
+func (p *prometheusMetricsProvider) queryRange(promQL, label string, query *MetricsQuery, step time.Duration) (map[string]MetricHistory, error) {
+	res, err := p.doQueryRange(promQL, query, step)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := parsePrometheusMatrix(res)
+	if err != nil {
+		return nil, err
+	}
+
+	rst := make(map[string]MetricHistory, len(series))
+	for _, s := range series {
+		rst[s.labels[label]] = s.history
+	}
+
+	return rst, nil
+}
+
+func (p *prometheusMetricsProvider) doQueryRange(promQL string, query *MetricsQuery, step time.Duration) ([]byte, error) {
+	return queryPrometheus(p.k8sClient, "/api/v1/query_range", map[string]string{
+		"query": promQL,
+		"start": strconv.FormatInt(query.Start.Unix(), 10),
+		"end":   strconv.FormatInt(query.End.Unix(), 10),
+		"step":  strconv.FormatFloat(step.Seconds(), 'f', -1, 64),
+	})
+}
+
+// the step of the query, or one giving about defaultPrometheusPoints points,
+// no less than a second, and no more points than prometheus allows
+func prometheusStep(query *MetricsQuery) time.Duration {
+	step := query.Step
+	if step <= 0 {
+		step = query.End.Sub(query.Start) / defaultPrometheusPoints
+	}
+
+	if min := query.End.Sub(query.Start) / maxPrometheusPoints; step < min {
+		step = min
+	}
+
+	if step < time.Second {
+		return time.Second
+	}
+
+	// in whole seconds, rounded up to keep the points within the limit
+	if rounded := step.Truncate(time.Second); rounded < step {
+		step = rounded + time.Second
+	}
+
+	return step
+}
+
+func prometheusRateWindow(step time.Duration) string {
+	window := 2 * step
+	if window < minPrometheusRateWindow {
+		window = minPrometheusRateWindow
+	}
+
+	return strconv.Itoa(int(window.Seconds())) + "s"
+}
+
+type prometheusSeries struct {
+	labels  map[string]string
+	history MetricHistory
+}
+
+type prometheusMatrixResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			// [unix timestamp in seconds, value]
+			Values [][2]interface{} `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func parsePrometheusMatrix(res []byte) ([]prometheusSeries, error) {
+	var resp prometheusMatrixResponse
+	if err := json.Unmarshal(res, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("prometheus query status: %s, %s", resp.Status, resp.Error)
+	}
+
+	if resp.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("prometheus query result type: %s", resp.Data.ResultType)
+	}
+
+	series := make([]prometheusSeries, 0, len(resp.Data.Result))
+	for _, result := range resp.Data.Result {
+		history := MetricHistory{}
+
+		for _, value := range result.Values {
+			ts, ok := value[0].(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid timestamp of prometheus sample: %v", value[0])
+			}
+
+			str, ok := value[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid value of prometheus sample: %v", value[1])
+			}
+
+			v, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			// NaN of a rate without samples
+			if math.IsNaN(v) || v < 0 {
+				continue
+			}
+
+			history = append(history, MetricPoint{
+				Timestamp: time.Unix(0, int64(ts*float64(time.Second))),
+				Value:     uint64(math.Round(v)),
+			})
+		}
+
+		series = append(series, prometheusSeries{labels: result.Metric, history: history})
+	}
+
+	return series, nil
+}
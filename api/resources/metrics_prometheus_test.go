@@ -0,0 +1,93 @@
+package resources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestPrometheusStep(t *testing.T) {
+	end := time.Unix(100000, 0)
+
+	assert.Equal(t, 30*time.Second, prometheusStep(&MetricsQuery{Start: end.Add(-time.Hour), End: end}))
+	assert.Equal(t, 5*time.Second, prometheusStep(&MetricsQuery{Start: end.Add(-time.Hour), End: end, Step: 5 * time.Second}))
+	assert.Equal(t, time.Second, prometheusStep(&MetricsQuery{Start: end.Add(-time.Minute), End: end}))
+
+	// no more points than prometheus allows
+	assert.Equal(t, 8*time.Second, prometheusStep(&MetricsQuery{Start: end.Add(-24 * time.Hour), End: end, Step: time.Second}))
+
+	assert.Equal(t, "60s", prometheusRateWindow(5*time.Second))
+	assert.Equal(t, "600s", prometheusRateWindow(5*time.Minute))
+}
+
+func TestParsePrometheusMatrix(t *testing.T) {
+	res := []byte(`{
+  "status": "success",
+  "data": {
+    "resultType": "matrix",
+    "result": [
+      {
+        "metric": {"label_kapp_component": "web", "pod": "web-0"},
+        "values": [[1580000000, "250.4"], [1580000030.5, "NaN"], [1580000060, "300"]]
+      }
+    ]
+  }
+}`)
+
+	series, err := parsePrometheusMatrix(res)
+	assert.Nil(t, err)
+	assert.Len(t, series, 1)
+	assert.Equal(t, "web-0", series[0].labels["pod"])
+	assert.Equal(t, MetricHistory{
+		{Timestamp: time.Unix(1580000000, 0), Value: 250},
+		{Timestamp: time.Unix(1580000060, 0), Value: 300},
+	}, series[0].history)
+
+	_, err = parsePrometheusMatrix([]byte(`{"status": "error", "error": "parse error"}`))
+	assert.NotNil(t, err)
+}
+
+func TestPrometheusApplicationsComponentMetrics(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/kapp-monitoring/services/http:prometheus-k8s:web/proxy/api/v1/query_range", r.URL.Path)
+		queries = append(queries, r.URL.Query().Get("query"))
+
+		_, _ = w.Write([]byte(`{"status": "success", "data": {"resultType": "matrix", "result": [
+  {"metric": {"namespace": "kapp-ns", "label_kapp_application": "shop", "label_kapp_component": "web", "pod": "web-1"}, "values": [[1577836800, "100"]]},
+  {"metric": {"namespace": "kapp-ns", "label_kapp_application": "shop", "label_kapp_component": "web", "pod": "web-2"}, "values": [[1577836800, "50"]]},
+  {"metric": {"namespace": "kapp-ns", "label_kapp_application": "blog", "label_kapp_component": "web", "pod": "web-1"}, "values": [[1577836800, "10"]]}
+]}}`))
+	}))
+	defer server.Close()
+
+	k8sClient, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	assert.Nil(t, err)
+
+	end := time.Unix(1577836800, 0)
+	query := &MetricsQuery{Start: end.Add(-time.Hour), End: end, Step: time.Minute}
+
+	metrics, err := NewPrometheusMetricsProvider(k8sClient).GetApplicationsComponentMetrics("kapp-ns", map[string][]string{
+		"shop":  {"web", "worker"},
+		"blog":  {"web"},
+		"empty": {"web"},
+	}, query)
+	assert.Nil(t, err)
+
+	// cpu and memory of all applications
+	assert.Len(t, queries, 2)
+	for _, q := range queries {
+		assert.Contains(t, q, `kube_pod_labels{namespace="kapp-ns",label_kapp_application!=""}`)
+	}
+
+	assert.Len(t, metrics["shop"], 1)
+	assert.Len(t, metrics["shop"]["web"].Pods, 2)
+	assert.Equal(t, uint64(150), metrics["shop"]["web"].CPU[0].Value)
+	assert.Equal(t, uint64(10), metrics["blog"]["web"].Memory[0].Value)
+	assert.Len(t, metrics["empty"], 0)
+}
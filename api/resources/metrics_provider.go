@@ -0,0 +1,104 @@
+package resources
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetricsQuery selects the time range of metric histories, and the distance between their points
+type MetricsQuery struct {
+	Start time.Time
+	End   time.Time
+	// 0 keeps the resolution of the provider
+	Step time.Duration
+}
+
+// NewDefaultMetricsQuery is the range kept by the scraper, at its resolution
+func NewDefaultMetricsQuery() *MetricsQuery {
+	now := time.Now()
+
+	return &MetricsQuery{
+		Start: now.Add(-metricDuration),
+		End:   now,
+	}
+}
+
+// MetricsProvider is where the metric histories of nodes and components come from.
+// CPU is in millicores, memory is in bytes.
+type MetricsProvider interface {
+	// GetNodeMetrics returns the histories of the nodes, and their sum
+	GetNodeMetrics(nodes []string, query *MetricsQuery) (NodesMetricHistories, error)
+
+	// GetComponentMetrics returns the histories of the components of an application, and of their pods,
+	// key is the name of the component
+	GetComponentMetrics(namespace, application string, components []string, query *MetricsQuery) (map[string]ComponentMetrics, error)
+
+	// GetApplicationsComponentMetrics is GetComponentMetrics of many applications of a namespace at once,
+	// applications are the components by application, and the result is keyed by application then component
+	GetApplicationsComponentMetrics(namespace string, applications map[string][]string, query *MetricsQuery) (map[string]map[string]ComponentMetrics, error)
+}
+
+// scraperMetricsProvider reads the metrics scraped from metrics-server by StartMetricsScraper
+type scraperMetricsProvider struct{}
+
+var ScraperMetricsProvider MetricsProvider = scraperMetricsProvider{}
+
+func (scraperMetricsProvider) GetNodeMetrics(nodes []string, query *MetricsQuery) (NodesMetricHistories, error) {
+	return GetFilteredNodeMetrics(nodes, query), nil
+}
+
+// components are keyed by namespace and name in the scraper
+func (scraperMetricsProvider) GetComponentMetrics(namespace, application string, components []string, query *MetricsQuery) (map[string]ComponentMetrics, error) {
+	return scraperComponentMetrics(getComponentKey2MetricMap(query), namespace, components), nil
+}
+
+func (scraperMetricsProvider) GetApplicationsComponentMetrics(namespace string, applications map[string][]string, query *MetricsQuery) (map[string]map[string]ComponentMetrics, error) {
+	key2Metrics := getComponentKey2MetricMap(query)
+	rst := make(map[string]map[string]ComponentMetrics, len(applications))
+
+	for application, components := range applications {
+		rst[application] = scraperComponentMetrics(key2Metrics, namespace, components)
+	}
+
+	return rst, nil
+}
+
+func scraperComponentMetrics(key2Metrics map[string]ComponentMetrics, namespace string, components []string) map[string]ComponentMetrics {
+	rst := make(map[string]ComponentMetrics, len(components))
+
+	for _, component := range components {
+		if metrics, exist := key2Metrics[fmt.Sprintf("%s-%s", namespace, component)]; exist {
+			rst[component] = metrics
+		}
+	}
+
+	return rst
+}
+
+// sample keeps the points in the range of the query.
+// With a step, the last point of each step is kept, at the start of the step,
+// so histories of different pods or nodes are aligned when they are aggregated.
+func (history MetricHistory) sample(query *MetricsQuery) MetricHistory {
+	var rst MetricHistory
+
+	for _, point := range history {
+		if point.Timestamp.Before(query.Start) || point.Timestamp.After(query.End) {
+			continue
+		}
+
+		if query.Step <= 0 {
+			rst = append(rst, point)
+			continue
+		}
+
+		point.Timestamp = query.Start.Add(point.Timestamp.Sub(query.Start) / query.Step * query.Step)
+
+		if len(rst) > 0 && rst[len(rst)-1].Timestamp.Equal(point.Timestamp) {
+			rst[len(rst)-1] = point
+		} else {
+			rst = append(rst, point)
+		}
+	}
+
+	return rst
+}
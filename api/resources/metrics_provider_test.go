@@ -0,0 +1,34 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleMetricHistory(t *testing.T) {
+	history := MetricHistory{
+		{Timestamp: time.Unix(5, 0), Value: 1},
+		{Timestamp: time.Unix(10, 0), Value: 2},
+		{Timestamp: time.Unix(15, 0), Value: 3},
+		{Timestamp: time.Unix(20, 0), Value: 4},
+		{Timestamp: time.Unix(25, 0), Value: 5},
+		{Timestamp: time.Unix(30, 0), Value: 6},
+	}
+
+	assert.Equal(t, MetricHistory{
+		{Timestamp: time.Unix(10, 0), Value: 2},
+		{Timestamp: time.Unix(15, 0), Value: 3},
+		{Timestamp: time.Unix(20, 0), Value: 4},
+	}, history.sample(&MetricsQuery{Start: time.Unix(10, 0), End: time.Unix(20, 0)}))
+
+	// the last point of each step
+	assert.Equal(t, MetricHistory{
+		{Timestamp: time.Unix(10, 0), Value: 3},
+		{Timestamp: time.Unix(20, 0), Value: 5},
+		{Timestamp: time.Unix(30, 0), Value: 6},
+	}, history.sample(&MetricsQuery{Start: time.Unix(10, 0), End: time.Unix(30, 0), Step: 10 * time.Second}))
+
+	assert.Nil(t, history.sample(&MetricsQuery{Start: time.Unix(40, 0), End: time.Unix(50, 0)}))
+}
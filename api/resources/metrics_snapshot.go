@@ -0,0 +1,207 @@
+package resources
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// metricsSnapshot is the content of a store written to disk, so the history survives restarts of the api server
+type metricsSnapshot struct {
+	Tiers  []metricsTier
+	Series []seriesSnapshot
+}
+
+type seriesSnapshot struct {
+	Key     seriesKey
+	Points  [][]usagePoint
+	Buckets []usageBucket
+}
+
+type metricsSnapshotFile struct {
+	Pods  metricsSnapshot
+	Nodes metricsSnapshot
+}
+
+// ConfigureMetricsStore keeps the scraped metrics for the retention,
+// and restores them from the snapshot at snapshotPath if it exists.
+// It should be called before the scraper is started.
+func ConfigureMetricsStore(retention time.Duration, snapshotPath string) error {
+	if retention <= 0 {
+		retention = DefaultMetricsRetention
+	}
+
+	tiers := metricsTiersOf(retention)
+	podMetricsStore = newMetricsStore(tiers)
+	nodeMetricsStore = newMetricsStore(tiers)
+
+	if snapshotPath == "" {
+		return nil
+	}
+
+	return loadMetricsSnapshot(snapshotPath)
+}
+
+// StartMetricsSnapshots writes the scraped metrics into the file at path every interval,
+// and once more when the ctx is done. The file is expected to be on a persistent volume.
+// The returned channel is closed after the last snapshot is written.
+func StartMetricsSnapshots(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+
+	if path == "" {
+		close(done)
+		return done
+	}
+
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	fmt.Printf("metrics snapshots written to %s every %s\n", path, interval)
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer close(done)
+
+		for {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+
+				if err := saveMetricsSnapshot(path); err != nil {
+					fmt.Printf("fail to save metrics snapshot, err: %s\n", err)
+				}
+
+				return
+			case <-ticker.C:
+				if err := saveMetricsSnapshot(path); err != nil {
+					fmt.Printf("fail to save metrics snapshot, err: %s\n", err)
+				}
+			}
+		}
+	}()
+
+	return done
+}
+
+// saveMetricsSnapshot writes a temp file in the same directory and renames it,
+// so a crash while writing never leaves a broken snapshot behind
+func saveMetricsSnapshot(path string) error {
+	file := metricsSnapshotFile{
+		Pods:  podMetricsStore.snapshot(),
+		Nodes: nodeMetricsStore.snapshot(),
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(&file); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func loadMetricsSnapshot(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	var file metricsSnapshotFile
+	if err := gob.NewDecoder(f).Decode(&file); err != nil {
+		return fmt.Errorf("fail to decode metrics snapshot %s, err: %s", path, err)
+	}
+
+	podMetricsStore.restore(file.Pods)
+	nodeMetricsStore.restore(file.Nodes)
+
+	return nil
+}
+
+func (s *metricsStore) snapshot() metricsSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot := metricsSnapshot{
+		Tiers:  s.tiers,
+		Series: make([]seriesSnapshot, 0, len(s.series)),
+	}
+
+	for key, series := range s.series {
+		item := seriesSnapshot{
+			Key:     key,
+			Points:  make([][]usagePoint, len(series.rings)),
+			Buckets: append([]usageBucket(nil), series.buckets...),
+		}
+
+		for i, ring := range series.rings {
+			points := make([]usagePoint, 0, ring.len)
+			ring.each(func(point usagePoint) {
+				points = append(points, point)
+			})
+			item.Points[i] = points
+		}
+
+		snapshot.Series = append(snapshot.Series, item)
+	}
+
+	return snapshot
+}
+
+// restore adds the series of the snapshot into the store. Points of a tier go into the tier
+// of the same resolution, which may keep less of them if the retention is shorter now.
+// Tiers which don't exist any more are dropped.
+func (s *metricsStore) restore(snapshot metricsSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tierIndex := make(map[int]int, len(snapshot.Tiers))
+	for i, tier := range snapshot.Tiers {
+		for j, current := range s.tiers {
+			if tier.Resolution == current.Resolution {
+				tierIndex[i] = j
+			}
+		}
+	}
+
+	for _, item := range snapshot.Series {
+		series := s.newSeries()
+
+		for i, points := range item.Points {
+			j, ok := tierIndex[i]
+			if !ok {
+				continue
+			}
+
+			for _, point := range points {
+				series.rings[j].push(point)
+			}
+
+			if i < len(item.Buckets) {
+				series.buckets[j] = item.Buckets[i]
+			}
+		}
+
+		s.series[item.Key] = series
+	}
+}
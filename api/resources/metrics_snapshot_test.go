@@ -0,0 +1,99 @@
+package resources
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsSnapshot(t *testing.T) {
+	store := newMetricsStore(testMetricsTiers)
+	key := seriesKey{Group: "ns-web", Name: "web-0"}
+
+	for i := 0; i < 14; i++ {
+		store.add(key, usagePoint{Timestamp: time.Unix(int64(i), 0), CPU: uint64(i), Memory: 10})
+	}
+
+	restored := newMetricsStore(testMetricsTiers)
+	restored.restore(store.snapshot())
+	assert.Equal(t, store.histories(allSeries, allTime()), restored.histories(allSeries, allTime()))
+
+	// the pending bucket is restored too, so the next coarse point is the average of [12, 16)
+	for i := 14; i < 17; i++ {
+		store.add(key, usagePoint{Timestamp: time.Unix(int64(i), 0), CPU: uint64(i)})
+		restored.add(key, usagePoint{Timestamp: time.Unix(int64(i), 0), CPU: uint64(i)})
+	}
+	assert.Equal(t, store.histories(allSeries, allTime()), restored.histories(allSeries, allTime()))
+
+	// a shorter retention keeps the newest points of the tiers left
+	shorter := newMetricsStore(testMetricsTiers[:1])
+	shorter.restore(store.snapshot())
+	histories := shorter.histories(allSeries, allTime())[key]
+	assert.Len(t, histories.CPU, 4)
+	assert.Equal(t, int64(16), histories.CPU[3].Timestamp.Unix())
+}
+
+func TestMetricsSnapshotFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "metrics.snapshot")
+
+	defer func(pods, nodes *metricsStore) {
+		podMetricsStore = pods
+		nodeMetricsStore = nodes
+	}(podMetricsStore, nodeMetricsStore)
+
+	// a missing snapshot is not an error
+	assert.Nil(t, ConfigureMetricsStore(time.Hour, path))
+
+	now := time.Now().Truncate(time.Second)
+	nodeMetricsStore.add(seriesKey{Name: "node-1"}, usagePoint{Timestamp: now, CPU: 100, Memory: 200})
+	podMetricsStore.add(seriesKey{Group: "ns-web", Name: "web-0"}, usagePoint{Timestamp: now, CPU: 1, Memory: 2})
+	assert.Nil(t, saveMetricsSnapshot(path))
+
+	assert.Nil(t, ConfigureMetricsStore(time.Hour, path))
+	metrics := GetFilteredNodeMetrics([]string{"node-1"}, &MetricsQuery{Start: now.Add(-time.Minute), End: now.Add(time.Minute)})
+	assert.Len(t, metrics.Nodes, 1)
+	assert.Len(t, metrics.Nodes[0].CPU, 1)
+	assert.Equal(t, uint64(100), metrics.Nodes[0].CPU[0].Value)
+	assert.Len(t, podMetricsStore.histories(allSeries, allTime()), 1)
+
+	// only the snapshot is left in the directory
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestStartMetricsSnapshotsSavesWhenDone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "metrics.snapshot")
+
+	defer func(pods, nodes *metricsStore) {
+		podMetricsStore = pods
+		nodeMetricsStore = nodes
+	}(podMetricsStore, nodeMetricsStore)
+
+	assert.Nil(t, ConfigureMetricsStore(time.Hour, path))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := StartMetricsSnapshots(ctx, path, time.Hour)
+
+	cancel()
+	<-done
+
+	_, err = os.Stat(path)
+	assert.Nil(t, err)
+
+	// nothing to wait for without a path
+	<-StartMetricsSnapshots(context.Background(), "", time.Hour)
+}
@@ -0,0 +1,336 @@
+package resources
+
+import (
+	"sync"
+	"time"
+)
+
+// metricsTier keeps the points of a series at a resolution, for a duration.
+// Points of a tier are averaged into the buckets of the next tier, which has a coarser resolution.
+type metricsTier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// DefaultMetricsRetention is how long the scraped metrics are kept by default
+const DefaultMetricsRetention = 24 * time.Hour
+
+var defaultMetricsTiers = metricsTiersOf(DefaultMetricsRetention)
+
+// metricsTiersOf keeps 15 minutes at the resolution of the scraper, up to 3 hours at 1 minute,
+// and the rest of the retention at 5 minutes
+func metricsTiersOf(retention time.Duration) []metricsTier {
+	tiers := []metricsTier{
+		{Resolution: metricResolution, Retention: metricDuration},
+	}
+
+	if retention <= metricDuration {
+		return tiers
+	}
+
+	if retention <= 3*time.Hour {
+		return append(tiers, metricsTier{Resolution: time.Minute, Retention: retention})
+	}
+
+	return append(tiers,
+		metricsTier{Resolution: time.Minute, Retention: 3 * time.Hour},
+		metricsTier{Resolution: 5 * time.Minute, Retention: retention},
+	)
+}
+
+// usagePoint is the usage of a pod or a node at a time, cpu in millicores, memory in bytes
+type usagePoint struct {
+	Timestamp time.Time
+	CPU       uint64
+	Memory    uint64
+	// the highest usage of the points averaged into this one, zero for scraped points
+	PeakCPU    uint64
+	PeakMemory uint64
+}
+
+// peak is the highest usage of the point, and of the points averaged into it
+func (p usagePoint) peak() ResourceAmount {
+	peak := ResourceAmount{CPU: p.CPU, Memory: p.Memory}
+
+	if p.PeakCPU > peak.CPU {
+		peak.CPU = p.PeakCPU
+	}
+
+	if p.PeakMemory > peak.Memory {
+		peak.Memory = p.PeakMemory
+	}
+
+	return peak
+}
+
+// seriesKey identifies a series in a store, group is the component of a pod, empty for nodes
+type seriesKey struct {
+	Group string
+	Name  string
+}
+
+// metricsStore keeps series of usage in ring buffers of fixed sizes, one for each tier,
+// so the memory of a series is bounded however long it's scraped.
+// It's written by the scraper and read by handlers concurrently.
+type metricsStore struct {
+	mu     sync.RWMutex
+	tiers  []metricsTier
+	series map[seriesKey]*metricsSeries
+}
+
+type metricsSeries struct {
+	rings []*usageRing
+	// the bucket being averaged for each tier but the first one
+	buckets []usageBucket
+}
+
+type usageBucket struct {
+	Start  time.Time
+	CPU    uint64
+	Memory uint64
+	Count  uint64
+	// spikes are kept, they are flattened in the averages
+	PeakCPU    uint64
+	PeakMemory uint64
+}
+
+func newMetricsStore(tiers []metricsTier) *metricsStore {
+	return &metricsStore{
+		tiers:  tiers,
+		series: make(map[seriesKey]*metricsSeries),
+	}
+}
+
+func (s *metricsStore) newSeries() *metricsSeries {
+	series := &metricsSeries{
+		rings:   make([]*usageRing, len(s.tiers)),
+		buckets: make([]usageBucket, len(s.tiers)),
+	}
+
+	for i, tier := range s.tiers {
+		series.rings[i] = newUsageRing(int(tier.Retention / tier.Resolution))
+	}
+
+	return series
+}
+
+// add appends the point to the series, points not newer than the last one are ignored
+func (s *metricsStore) add(key seriesKey, point usagePoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, exist := s.series[key]
+	if !exist {
+		series = s.newSeries()
+		s.series[key] = series
+	}
+
+	if last, ok := series.rings[0].last(); ok && !point.Timestamp.After(last.Timestamp) {
+		return
+	}
+
+	s.addToTier(series, 0, point)
+}
+
+func (s *metricsStore) addToTier(series *metricsSeries, tier int, point usagePoint) {
+	series.rings[tier].push(point)
+
+	next := tier + 1
+	if next >= len(s.tiers) {
+		return
+	}
+
+	bucket := &series.buckets[next]
+	start := point.Timestamp.Truncate(s.tiers[next].Resolution)
+
+	// the point is in a new bucket, the previous one is complete
+	if bucket.Count > 0 && !start.Equal(bucket.Start) {
+		s.addToTier(series, next, usagePoint{
+			Timestamp:  bucket.Start,
+			CPU:        bucket.CPU / bucket.Count,
+			Memory:     bucket.Memory / bucket.Count,
+			PeakCPU:    bucket.PeakCPU,
+			PeakMemory: bucket.PeakMemory,
+		})
+
+		*bucket = usageBucket{}
+	}
+
+	bucket.Start = start
+	bucket.CPU += point.CPU
+	bucket.Memory += point.Memory
+	bucket.Count++
+
+	peak := point.peak()
+	if peak.CPU > bucket.PeakCPU {
+		bucket.PeakCPU = peak.CPU
+	}
+
+	if peak.Memory > bucket.PeakMemory {
+		bucket.PeakMemory = peak.Memory
+	}
+}
+
+// histories returns the histories of the series accepted by the filter, sampled by the query.
+// Older points come from coarser tiers, where the finer tiers don't have points any more.
+func (s *metricsStore) histories(filter func(key seriesKey) bool, query *MetricsQuery) map[seriesKey]MetricHistories {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rst := make(map[seriesKey]MetricHistories)
+
+	for key, series := range s.series {
+		if !filter(key) {
+			continue
+		}
+
+		points := series.points()
+
+		histories := MetricHistories{
+			CPU:    make(MetricHistory, 0, len(points)),
+			Memory: make(MetricHistory, 0, len(points)),
+		}
+
+		for _, point := range points {
+			histories.CPU = append(histories.CPU, MetricPoint{Timestamp: point.Timestamp, Value: point.CPU})
+			histories.Memory = append(histories.Memory, MetricPoint{Timestamp: point.Timestamp, Value: point.Memory})
+		}
+
+		histories.CPU = histories.CPU.sample(query)
+		histories.Memory = histories.Memory.sample(query)
+		rst[key] = histories
+	}
+
+	return rst
+}
+
+// peaks returns the highest usage of the series accepted by the filter since the time,
+// including the spikes averaged into the points of coarser tiers
+func (s *metricsStore) peaks(filter func(key seriesKey) bool, since time.Time) map[seriesKey]ResourceAmount {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rst := make(map[seriesKey]ResourceAmount)
+
+	for key, series := range s.series {
+		if !filter(key) {
+			continue
+		}
+
+		var peak ResourceAmount
+		for _, point := range series.points() {
+			if point.Timestamp.Before(since) {
+				continue
+			}
+
+			p := point.peak()
+			if p.CPU > peak.CPU {
+				peak.CPU = p.CPU
+			}
+
+			if p.Memory > peak.Memory {
+				peak.Memory = p.Memory
+			}
+		}
+
+		rst[key] = peak
+	}
+
+	return rst
+}
+
+// points are the points of all tiers, oldest first.
+// Older points come from coarser tiers, where the finer tiers don't have points any more.
+func (series *metricsSeries) points() []usagePoint {
+	var points []usagePoint
+
+	for i := len(series.rings) - 1; i >= 0; i-- {
+		var before *time.Time
+		if i > 0 {
+			if oldest, ok := series.rings[i-1].first(); ok {
+				before = &oldest.Timestamp
+			}
+		}
+
+		series.rings[i].each(func(point usagePoint) {
+			if before == nil || point.Timestamp.Before(*before) {
+				points = append(points, point)
+			}
+		})
+	}
+
+	return points
+}
+
+// evict removes the series which are not alive, e.g. of deleted pods or nodes,
+// once they have no points after staleBefore, so their recent history is still shown for a while
+func (s *metricsStore) evict(alive map[seriesKey]bool, staleBefore time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, series := range s.series {
+		if alive[key] {
+			continue
+		}
+
+		if last, ok := series.rings[0].last(); ok && last.Timestamp.After(staleBefore) {
+			continue
+		}
+
+		delete(s.series, key)
+	}
+}
+
+// retention is how long the series are kept, the retention of the coarsest tier
+func (s *metricsStore) retention() time.Duration {
+	return s.tiers[len(s.tiers)-1].Retention
+}
+
+// usageRing is a ring buffer of points, the oldest point is overwritten when it's full
+type usageRing struct {
+	points []usagePoint
+	start  int
+	len    int
+}
+
+func newUsageRing(size int) *usageRing {
+	if size < 1 {
+		size = 1
+	}
+
+	return &usageRing{points: make([]usagePoint, size)}
+}
+
+func (r *usageRing) push(point usagePoint) {
+	if r.len < len(r.points) {
+		r.points[(r.start+r.len)%len(r.points)] = point
+		r.len++
+		return
+	}
+
+	r.points[r.start] = point
+	r.start = (r.start + 1) % len(r.points)
+}
+
+func (r *usageRing) first() (usagePoint, bool) {
+	if r.len == 0 {
+		return usagePoint{}, false
+	}
+
+	return r.points[r.start], true
+}
+
+func (r *usageRing) last() (usagePoint, bool) {
+	if r.len == 0 {
+		return usagePoint{}, false
+	}
+
+	return r.points[(r.start+r.len-1)%len(r.points)], true
+}
+
+// each calls fn with the points from the oldest to the newest
+func (r *usageRing) each(fn func(point usagePoint)) {
+	for i := 0; i < r.len; i++ {
+		fn(r.points[(r.start+i)%len(r.points)])
+	}
+}
@@ -0,0 +1,165 @@
+package resources
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMetricsTiers = []metricsTier{
+	{Resolution: time.Second, Retention: 4 * time.Second},
+	{Resolution: 4 * time.Second, Retention: 16 * time.Second},
+}
+
+func allSeries(seriesKey) bool { return true }
+
+func allTime() *MetricsQuery {
+	return &MetricsQuery{Start: time.Unix(0, 0), End: time.Unix(1<<40, 0)}
+}
+
+func TestUsageRing(t *testing.T) {
+	ring := newUsageRing(3)
+
+	_, ok := ring.first()
+	assert.False(t, ok)
+
+	for i := 1; i <= 5; i++ {
+		ring.push(usagePoint{Timestamp: time.Unix(int64(i), 0)})
+	}
+
+	var timestamps []int64
+	ring.each(func(point usagePoint) {
+		timestamps = append(timestamps, point.Timestamp.Unix())
+	})
+	assert.Equal(t, []int64{3, 4, 5}, timestamps)
+
+	first, _ := ring.first()
+	last, _ := ring.last()
+	assert.Equal(t, int64(3), first.Timestamp.Unix())
+	assert.Equal(t, int64(5), last.Timestamp.Unix())
+}
+
+func TestMetricsStoreDownsample(t *testing.T) {
+	store := newMetricsStore(testMetricsTiers)
+	key := seriesKey{Group: "ns-web", Name: "web-0"}
+
+	for i := 0; i < 12; i++ {
+		store.add(key, usagePoint{Timestamp: time.Unix(int64(i), 0), CPU: uint64(i), Memory: 10})
+	}
+
+	// duplicates and older points are ignored
+	store.add(key, usagePoint{Timestamp: time.Unix(11, 0), CPU: 100})
+	store.add(key, usagePoint{Timestamp: time.Unix(3, 0), CPU: 100})
+
+	histories := store.histories(allSeries, allTime())[key]
+
+	// averages of [0, 4) and [4, 8) from the coarse tier, and the last 4 points at full resolution
+	var timestamps []int64
+	var cpu []uint64
+	for _, point := range histories.CPU {
+		timestamps = append(timestamps, point.Timestamp.Unix())
+		cpu = append(cpu, point.Value)
+	}
+
+	assert.Equal(t, []int64{0, 4, 8, 9, 10, 11}, timestamps)
+	assert.Equal(t, []uint64{1, 5, 8, 9, 10, 11}, cpu)
+	assert.Equal(t, uint64(10), histories.Memory[0].Value)
+
+	// the query is applied to the merged history
+	histories = store.histories(allSeries, &MetricsQuery{Start: time.Unix(4, 0), End: time.Unix(9, 0)})[key]
+	assert.Len(t, histories.CPU, 3)
+}
+
+func TestMetricsStoreBounded(t *testing.T) {
+	store := newMetricsStore(testMetricsTiers)
+	key := seriesKey{Name: "node-1"}
+
+	for i := 0; i < 1000; i++ {
+		store.add(key, usagePoint{Timestamp: time.Unix(int64(i), 0)})
+	}
+
+	// 4 points at full resolution, 16 / 4 downsampled points
+	histories := store.histories(allSeries, allTime())[key]
+	assert.Len(t, histories.CPU, 8)
+	assert.Equal(t, int64(999), histories.CPU[len(histories.CPU)-1].Timestamp.Unix())
+}
+
+func TestMetricsStoreEvict(t *testing.T) {
+	store := newMetricsStore(testMetricsTiers)
+	alive := seriesKey{Name: "node-1"}
+	deleted := seriesKey{Name: "node-2"}
+	recentlyDeleted := seriesKey{Name: "node-3"}
+
+	store.add(alive, usagePoint{Timestamp: time.Unix(10, 0)})
+	store.add(deleted, usagePoint{Timestamp: time.Unix(10, 0)})
+	store.add(recentlyDeleted, usagePoint{Timestamp: time.Unix(20, 0)})
+
+	store.evict(map[seriesKey]bool{alive: true}, time.Unix(15, 0))
+
+	histories := store.histories(allSeries, allTime())
+	assert.Len(t, histories, 2)
+	assert.Contains(t, histories, alive)
+	assert.Contains(t, histories, recentlyDeleted)
+}
+
+func TestEvictDeadSeriesAfterRollout(t *testing.T) {
+	store := newMetricsStore(defaultMetricsTiers)
+	replaced := seriesKey{Group: "ns-web", Name: "web-1"}
+	current := seriesKey{Group: "ns-web", Name: "web-2"}
+
+	now := time.Now().Truncate(time.Minute)
+	for ts := now.Add(-2 * time.Hour); ts.Before(now.Add(-time.Hour)); ts = ts.Add(metricResolution) {
+		store.add(replaced, usagePoint{Timestamp: ts, CPU: 100})
+	}
+
+	for ts := now.Add(-time.Hour); !ts.After(now); ts = ts.Add(metricResolution) {
+		store.add(current, usagePoint{Timestamp: ts, CPU: 100})
+	}
+
+	// the replaced pod is gone for an hour, longer than the finest tier
+	evictDeadSeries(store, map[seriesKey]bool{current: true}, now)
+
+	histories := store.histories(allSeries, &MetricsQuery{Start: now.Add(-3 * time.Hour), End: now})
+	assert.Contains(t, histories, replaced)
+	assert.NotEmpty(t, histories[replaced].CPU)
+
+	// and evicted after the retention
+	evictDeadSeries(store, map[seriesKey]bool{current: true}, now.Add(DefaultMetricsRetention))
+	histories = store.histories(allSeries, allTime())
+	assert.NotContains(t, histories, replaced)
+	assert.Contains(t, histories, current)
+}
+
+func TestMetricsStoreConcurrency(t *testing.T) {
+	store := newMetricsStore(testMetricsTiers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 200; i++ {
+				store.add(seriesKey{Name: fmt.Sprintf("node-%d", i%8)}, usagePoint{Timestamp: time.Unix(int64(w*200+i), 0)})
+
+				if i%50 == 0 {
+					store.evict(nil, time.Unix(int64(w*200+i-20), 0))
+				}
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 200; i++ {
+				store.histories(allSeries, allTime())
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,268 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the prometheus installed by the kube-prometheus dependency
+const (
+	prometheusNamespace   = "kapp-monitoring"
+	prometheusServiceName = "prometheus-k8s"
+	prometheusServicePort = "web"
+)
+
+// queries of prometheus give up after this long, instead of holding the request which is waiting for them
+const prometheusQueryTimeout = 5 * time.Second
+
+// targets and alerts of all applications are the same for every request in this long,
+// the polling interval of the details of an application in the dashboard
+const prometheusResponseTTL = 5 * time.Second
+
+// PrometheusTarget is a pod of a component scraped by prometheus, through the ServiceMonitor of the component
+type PrometheusTarget struct {
+	Component string `json:"component"`
+	Pod       string `json:"pod"`
+	ScrapeURL string `json:"scrapeUrl"`
+	// up, down or unknown
+	Health     string    `json:"health"`
+	LastError  string    `json:"lastError,omitempty"`
+	LastScrape time.Time `json:"lastScrape"`
+}
+
+// PrometheusAlert is a firing alert of the alerts of an application
+type PrometheusAlert struct {
+	Name string `json:"name"`
+	// empty for custom alerts
+	Component string    `json:"component,omitempty"`
+	Severity  string    `json:"severity"`
+	Summary   string    `json:"summary,omitempty"`
+	ActiveAt  time.Time `json:"activeAt"`
+	Value     string    `json:"value"`
+}
+
+// ApplicationMonitoring is how the components of an application are monitored by prometheus
+type ApplicationMonitoring struct {
+	// the targets page of prometheus, if it's exposed by prometheusHost of kube-prometheus
+	TargetsURL string `json:"targetsUrl,omitempty"`
+	// the dashboard of the application in grafana, if it's exposed by grafanaHost of kube-prometheus
+	DashboardURL string             `json:"dashboardUrl,omitempty"`
+	Targets      []PrometheusTarget `json:"targets"`
+	Alerts       []PrometheusAlert  `json:"alerts"`
+}
+
+// queryPrometheus gets the path of the prometheus api through the service proxy of the api server, within prometheusQueryTimeout
+func queryPrometheus(k8sClient *kubernetes.Clientset, path string, params map[string]string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), prometheusQueryTimeout)
+	defer cancel()
+
+	request := k8sClient.CoreV1().RESTClient().Get().
+		Context(ctx).
+		Namespace(prometheusNamespace).
+		Resource("services").
+		SubResource("proxy").
+		Name(net.JoinSchemeNamePort("http", prometheusServiceName, prometheusServicePort)).
+		Suffix(path)
+
+	for k, v := range params {
+		request = request.Param(k, v)
+	}
+
+	res, err := request.DoRaw()
+	if err != nil {
+		return nil, fmt.Errorf("fail to query prometheus: %s", err)
+	}
+
+	return res, nil
+}
+
+// prometheusResponseCache keeps the responses of prometheus which don't depend on the caller, by path
+type prometheusResponseCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]prometheusResponse
+}
+
+type prometheusResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+var prometheusResponses = newPrometheusResponseCache(prometheusResponseTTL)
+
+func newPrometheusResponseCache(ttl time.Duration) *prometheusResponseCache {
+	return &prometheusResponseCache{
+		ttl:     ttl,
+		entries: make(map[string]prometheusResponse),
+	}
+}
+
+// get returns the cached response of the key, or the one of query which is cached if it succeeds
+func (c *prometheusResponseCache) get(key string, query func() ([]byte, error)) ([]byte, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, exist := c.entries[key]
+	c.mu.Unlock()
+
+	if exist && now.Before(entry.expires) {
+		return entry.body, nil
+	}
+
+	// concurrent misses of a key may query it more than once
+	body, err := query()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = prometheusResponse{body: body, expires: now.Add(c.ttl)}
+
+	return body, nil
+}
+
+// GetPrometheusTargetsOfApplication queries the active targets of prometheus through the service proxy of the api server
+func GetPrometheusTargetsOfApplication(k8sClient *kubernetes.Clientset, namespace, name string) ([]PrometheusTarget, error) {
+	res, err := prometheusResponses.get("/api/v1/targets", func() ([]byte, error) {
+		return queryPrometheus(k8sClient, "/api/v1/targets", map[string]string{"state": "active"})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePrometheusTargets(res, namespace, name)
+}
+
+type prometheusTargetsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ActiveTargets []struct {
+			DiscoveredLabels map[string]string `json:"discoveredLabels"`
+			Labels           map[string]string `json:"labels"`
+			ScrapeURL        string            `json:"scrapeUrl"`
+			LastError        string            `json:"lastError"`
+			LastScrape       time.Time         `json:"lastScrape"`
+			Health           string            `json:"health"`
+		} `json:"activeTargets"`
+	} `json:"data"`
+}
+
+// targets discovered from the services of the application, sorted by component and pod
+func parsePrometheusTargets(res []byte, namespace, name string) ([]PrometheusTarget, error) {
+	var resp prometheusTargetsResponse
+	if err := json.Unmarshal(res, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("prometheus query status: %s", resp.Status)
+	}
+
+	targets := []PrometheusTarget{}
+	for _, target := range resp.Data.ActiveTargets {
+		discovered := target.DiscoveredLabels
+		if discovered["__meta_kubernetes_namespace"] != namespace ||
+			discovered["__meta_kubernetes_service_label_kapp_application"] != name {
+			continue
+		}
+
+		targets = append(targets, PrometheusTarget{
+			Component:  discovered["__meta_kubernetes_service_label_kapp_component"],
+			Pod:        target.Labels["pod"],
+			ScrapeURL:  target.ScrapeURL,
+			Health:     target.Health,
+			LastError:  target.LastError,
+			LastScrape: target.LastScrape,
+		})
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Component != targets[j].Component {
+			return targets[i].Component < targets[j].Component
+		}
+
+		return targets[i].Pod < targets[j].Pod
+	})
+
+	return targets, nil
+}
+
+// GetFiringAlertsOfApplication queries the alerts of prometheus through the service proxy of the api server
+func GetFiringAlertsOfApplication(k8sClient *kubernetes.Clientset, namespace, name string) ([]PrometheusAlert, error) {
+	res, err := prometheusResponses.get("/api/v1/alerts", func() ([]byte, error) {
+		return queryPrometheus(k8sClient, "/api/v1/alerts", nil)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePrometheusAlerts(res, namespace, name)
+}
+
+type prometheusAlertsResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Alerts []struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+			// pending or firing
+			State    string    `json:"state"`
+			ActiveAt time.Time `json:"activeAt"`
+			// a number in older versions of prometheus, a string in newer ones
+			Value interface{} `json:"value"`
+		} `json:"alerts"`
+	} `json:"data"`
+}
+
+// firing alerts rendered from the alerts of the application, sorted by name and component
+func parsePrometheusAlerts(res []byte, namespace, name string) ([]PrometheusAlert, error) {
+	var resp prometheusAlertsResponse
+	if err := json.Unmarshal(res, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("prometheus query status: %s", resp.Status)
+	}
+
+	alerts := []PrometheusAlert{}
+	for _, alert := range resp.Data.Alerts {
+		if alert.State != "firing" ||
+			alert.Labels["namespace"] != namespace ||
+			alert.Labels[v1alpha1.AlertLabelApplication] != name {
+			continue
+		}
+
+		alerts = append(alerts, PrometheusAlert{
+			Name:      alert.Labels["alertname"],
+			Component: alert.Labels[v1alpha1.AlertLabelComponent],
+			Severity:  alert.Labels["severity"],
+			Summary:   alert.Annotations["summary"],
+			ActiveAt:  alert.ActiveAt,
+			Value:     fmt.Sprint(alert.Value),
+		})
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Name != alerts[j].Name {
+			return alerts[i].Name < alerts[j].Name
+		}
+
+		return alerts[i].Component < alerts[j].Component
+	})
+
+	return alerts, nil
+}
@@ -0,0 +1,165 @@
+package resources
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrometheusTargets(t *testing.T) {
+	res := []byte(`{
+  "status": "success",
+  "data": {
+    "activeTargets": [
+      {
+        "discoveredLabels": {
+          "__meta_kubernetes_namespace": "kapp-ns",
+          "__meta_kubernetes_service_label_kapp_application": "shop",
+          "__meta_kubernetes_service_label_kapp_component": "web"
+        },
+        "labels": {"pod": "web-1"},
+        "scrapeUrl": "http://10.0.0.2:8080/metrics",
+        "lastError": "connection refused",
+        "lastScrape": "2020-01-01T00:00:01Z",
+        "health": "down"
+      },
+      {
+        "discoveredLabels": {
+          "__meta_kubernetes_namespace": "kapp-ns",
+          "__meta_kubernetes_service_label_kapp_application": "shop",
+          "__meta_kubernetes_service_label_kapp_component": "web"
+        },
+        "labels": {"pod": "web-0"},
+        "scrapeUrl": "http://10.0.0.1:8080/metrics",
+        "lastError": "",
+        "lastScrape": "2020-01-01T00:00:00Z",
+        "health": "up"
+      },
+      {
+        "discoveredLabels": {
+          "__meta_kubernetes_namespace": "kapp-other",
+          "__meta_kubernetes_service_label_kapp_application": "shop"
+        },
+        "labels": {"pod": "web-0"},
+        "health": "up"
+      }
+    ]
+  }
+}`)
+
+	targets, err := parsePrometheusTargets(res, "kapp-ns", "shop")
+	assert.Nil(t, err)
+	assert.Equal(t, []PrometheusTarget{
+		{
+			Component:  "web",
+			Pod:        "web-0",
+			ScrapeURL:  "http://10.0.0.1:8080/metrics",
+			Health:     "up",
+			LastScrape: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Component:  "web",
+			Pod:        "web-1",
+			ScrapeURL:  "http://10.0.0.2:8080/metrics",
+			Health:     "down",
+			LastError:  "connection refused",
+			LastScrape: time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC),
+		},
+	}, targets)
+
+	_, err = parsePrometheusTargets([]byte(`{"status": "error"}`), "kapp-ns", "shop")
+	assert.NotNil(t, err)
+}
+
+func TestParsePrometheusAlerts(t *testing.T) {
+	res := []byte(`{
+  "status": "success",
+  "data": {
+    "alerts": [
+      {
+        "labels": {"alertname": "Restarting", "namespace": "kapp-ns", "kapp_application": "shop", "kapp_component": "worker", "severity": "warning"},
+        "annotations": {"summary": "worker restarted"},
+        "state": "firing",
+        "activeAt": "2020-01-01T00:00:00Z",
+        "value": "4e+00"
+      },
+      {
+        "labels": {"alertname": "Errors", "namespace": "kapp-ns", "kapp_application": "shop", "severity": "critical"},
+        "annotations": {},
+        "state": "firing",
+        "activeAt": "2020-01-01T00:00:01Z",
+        "value": 2
+      },
+      {
+        "labels": {"alertname": "Restarting", "namespace": "kapp-ns", "kapp_application": "shop", "kapp_component": "web"},
+        "state": "pending",
+        "activeAt": "2020-01-01T00:00:00Z",
+        "value": "1e+00"
+      },
+      {
+        "labels": {"alertname": "Restarting", "namespace": "kapp-other", "kapp_application": "shop"},
+        "state": "firing",
+        "activeAt": "2020-01-01T00:00:00Z",
+        "value": "1e+00"
+      }
+    ]
+  }
+}`)
+
+	alerts, err := parsePrometheusAlerts(res, "kapp-ns", "shop")
+	assert.Nil(t, err)
+	assert.Equal(t, []PrometheusAlert{
+		{
+			Name:     "Errors",
+			Severity: "critical",
+			ActiveAt: time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC),
+			Value:    "2",
+		},
+		{
+			Name:      "Restarting",
+			Component: "worker",
+			Severity:  "warning",
+			Summary:   "worker restarted",
+			ActiveAt:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			Value:     "4e+00",
+		},
+	}, alerts)
+}
+
+func TestPrometheusResponseCache(t *testing.T) {
+	cache := newPrometheusResponseCache(time.Minute)
+
+	queries := 0
+	query := func() ([]byte, error) {
+		queries++
+		return []byte("targets"), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		res, err := cache.get("/api/v1/targets", query)
+		assert.Nil(t, err)
+		assert.Equal(t, "targets", string(res))
+	}
+
+	assert.Equal(t, 1, queries)
+
+	// errors are not cached
+	_, err := cache.get("/api/v1/alerts", func() ([]byte, error) {
+		return nil, fmt.Errorf("timeout")
+	})
+	assert.NotNil(t, err)
+
+	res, err := cache.get("/api/v1/alerts", func() ([]byte, error) {
+		return []byte("alerts"), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "alerts", string(res))
+
+	// expired
+	cache = newPrometheusResponseCache(0)
+	_, _ = cache.get("/api/v1/targets", query)
+	_, _ = cache.get("/api/v1/targets", query)
+	assert.Equal(t, 3, queries)
+}
@@ -0,0 +1,273 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kapp-staging/kapp/api/errors"
+	"github.com/kapp-staging/kapp/controller/api/v1alpha1"
+	coreV1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the quota and the limit range of a kapp namespace managed by the api
+const (
+	namespaceResourceQuotaName = "kapp-quota"
+	namespaceLimitRangeName    = "kapp-limits"
+)
+
+// NamespaceQuota is what the pods of a kapp namespace can consume in total,
+// and the requests and limits of containers which don't set them
+type NamespaceQuota struct {
+	// by the resource names of ResourceQuota, e.g. requests.cpu, limits.memory, requests.storage, pods, services
+	Hard            coreV1.ResourceList `json:"hard"`
+	DefaultRequests coreV1.ResourceList `json:"defaultRequests,omitempty"`
+	DefaultLimits   coreV1.ResourceList `json:"defaultLimits,omitempty"`
+}
+
+// NamespaceQuotaStatus has the current usage of the resources of the quota
+type NamespaceQuotaStatus struct {
+	Namespace string `json:"namespace"`
+	NamespaceQuota
+	Used coreV1.ResourceList `json:"used"`
+}
+
+var quotaResourceNames = map[coreV1.ResourceName]bool{
+	coreV1.ResourceCPU:                    true,
+	coreV1.ResourceMemory:                 true,
+	coreV1.ResourceRequestsCPU:            true,
+	coreV1.ResourceRequestsMemory:         true,
+	coreV1.ResourceLimitsCPU:              true,
+	coreV1.ResourceLimitsMemory:           true,
+	coreV1.ResourceRequestsStorage:        true,
+	coreV1.ResourcePods:                   true,
+	coreV1.ResourceServices:               true,
+	coreV1.ResourceServicesLoadBalancers:  true,
+	coreV1.ResourceServicesNodePorts:      true,
+	coreV1.ResourcePersistentVolumeClaims: true,
+	coreV1.ResourceConfigMaps:             true,
+	coreV1.ResourceSecrets:                true,
+}
+
+func (q *NamespaceQuota) Validate() error {
+	for name, quantity := range q.Hard {
+		// object counts of any resource, e.g. count/deployments.apps
+		if !quotaResourceNames[name] && !strings.HasPrefix(string(name), "count/") {
+			return fmt.Errorf("unknown resource of quota: %s", name)
+		}
+
+		if quantity.Sign() < 0 {
+			return fmt.Errorf("quota of %s should not be negative: %s", name, quantity.String())
+		}
+	}
+
+	for _, defaults := range []coreV1.ResourceList{q.DefaultRequests, q.DefaultLimits} {
+		for name, quantity := range defaults {
+			if name != coreV1.ResourceCPU && name != coreV1.ResourceMemory {
+				return fmt.Errorf("only defaults of cpu and memory can be set: %s", name)
+			}
+
+			if quantity.Sign() <= 0 {
+				return fmt.Errorf("default of %s should be positive: %s", name, quantity.String())
+			}
+		}
+	}
+
+	for name, request := range q.DefaultRequests {
+		if limit, exist := q.DefaultLimits[name]; exist && request.Cmp(limit) > 0 {
+			return fmt.Errorf("default request of %s should not be more than its default limit: %s > %s", name, request.String(), limit.String())
+		}
+	}
+
+	return nil
+}
+
+func GetNamespaceQuota(k8sClient *kubernetes.Clientset, name string) (*NamespaceQuotaStatus, error) {
+	namespace := formatNamespaceName(name)
+
+	status := &NamespaceQuotaStatus{
+		Namespace: namespace,
+		NamespaceQuota: NamespaceQuota{
+			Hard: coreV1.ResourceList{},
+		},
+		Used: coreV1.ResourceList{},
+	}
+
+	quota, err := k8sClient.CoreV1().ResourceQuotas(namespace).Get(namespaceResourceQuotaName, metaV1.GetOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		status.Hard = quota.Spec.Hard
+
+		if quota.Status.Used != nil {
+			status.Used = quota.Status.Used
+		}
+	}
+
+	limitRange, err := k8sClient.CoreV1().LimitRanges(namespace).Get(namespaceLimitRangeName, metaV1.GetOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type == coreV1.LimitTypeContainer {
+				status.DefaultRequests = item.DefaultRequest
+				status.DefaultLimits = item.Default
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// SetNamespaceQuota creates or updates the quota and the limit range of the namespace,
+// they are deleted if nothing is set in them
+func SetNamespaceQuota(k8sClient *kubernetes.Clientset, name string, quota *NamespaceQuota) (*NamespaceQuotaStatus, error) {
+	if err := quota.Validate(); err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+
+	namespace := formatNamespaceName(name)
+
+	if err := setNamespaceResourceQuota(k8sClient, namespace, quota.Hard); err != nil {
+		return nil, err
+	}
+
+	if err := setNamespaceLimitRange(k8sClient, namespace, quota.DefaultRequests, quota.DefaultLimits); err != nil {
+		return nil, err
+	}
+
+	return GetNamespaceQuota(k8sClient, name)
+}
+
+func DeleteNamespaceQuota(k8sClient *kubernetes.Clientset, name string) error {
+	namespace := formatNamespaceName(name)
+
+	if err := setNamespaceResourceQuota(k8sClient, namespace, nil); err != nil {
+		return err
+	}
+
+	return setNamespaceLimitRange(k8sClient, namespace, nil, nil)
+}
+
+func setNamespaceResourceQuota(k8sClient *kubernetes.Clientset, namespace string, hard coreV1.ResourceList) error {
+	client := k8sClient.CoreV1().ResourceQuotas(namespace)
+
+	quota, err := client.Get(namespaceResourceQuotaName, metaV1.GetOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+
+	exist := err == nil
+
+	if len(hard) == 0 {
+		if !exist {
+			return nil
+		}
+
+		return client.Delete(namespaceResourceQuotaName, nil)
+	}
+
+	if !exist {
+		_, err = client.Create(&coreV1.ResourceQuota{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      namespaceResourceQuotaName,
+				Namespace: namespace,
+			},
+			Spec: coreV1.ResourceQuotaSpec{Hard: hard},
+		})
+
+		return err
+	}
+
+	quota.Spec.Hard = hard
+	_, err = client.Update(quota)
+
+	return err
+}
+
+func setNamespaceLimitRange(k8sClient *kubernetes.Clientset, namespace string, defaultRequests, defaultLimits coreV1.ResourceList) error {
+	client := k8sClient.CoreV1().LimitRanges(namespace)
+
+	limitRange, err := client.Get(namespaceLimitRangeName, metaV1.GetOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+
+	exist := err == nil
+
+	if len(defaultRequests) == 0 && len(defaultLimits) == 0 {
+		if !exist {
+			return nil
+		}
+
+		return client.Delete(namespaceLimitRangeName, nil)
+	}
+
+	limits := []coreV1.LimitRangeItem{
+		{
+			Type:           coreV1.LimitTypeContainer,
+			Default:        defaultLimits,
+			DefaultRequest: defaultRequests,
+		},
+	}
+
+	if !exist {
+		_, err = client.Create(&coreV1.LimitRange{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      namespaceLimitRangeName,
+				Namespace: namespace,
+			},
+			Spec: coreV1.LimitRangeSpec{Limits: limits},
+		})
+
+		return err
+	}
+
+	limitRange.Spec.Limits = limits
+	_, err = client.Update(limitRange)
+
+	return err
+}
+
+// CheckApplicationQuota rejects the application, replacing the old one if it's not nil,
+// if it would exceed the quotas of its namespace, instead of failing to create its pods later.
+// Users who can't read the quotas are not checked here, the quotas are still enforced by kubernetes.
+func CheckApplicationQuota(k8sClient *kubernetes.Clientset, application, old *v1alpha1.Application) error {
+	quotas, err := k8sClient.CoreV1().ResourceQuotas(application.Namespace).List(ListAll)
+	if k8sErrors.IsForbidden(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+
+	limitRanges, err := k8sClient.CoreV1().LimitRanges(application.Namespace).List(ListAll)
+	if k8sErrors.IsForbidden(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := v1alpha1.CheckApplicationQuota(application, old, quotas.Items, limitRanges.Items); err != nil {
+		if v1alpha1.IsQuotaExceeded(err) {
+			return errors.NewForbidden(err.Error())
+		}
+
+		return errors.NewBadRequest(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestNamespaceQuotaValidate(t *testing.T) {
+	quota := NamespaceQuota{
+		Hard: coreV1.ResourceList{
+			coreV1.ResourceLimitsCPU:       resource.MustParse("4"),
+			coreV1.ResourceRequestsStorage: resource.MustParse("100Gi"),
+			coreV1.ResourcePods:            resource.MustParse("20"),
+			"count/deployments.apps":       resource.MustParse("10"),
+		},
+		DefaultRequests: coreV1.ResourceList{coreV1.ResourceCPU: resource.MustParse("100m")},
+		DefaultLimits:   coreV1.ResourceList{coreV1.ResourceCPU: resource.MustParse("500m")},
+	}
+	assert.Nil(t, quota.Validate())
+
+	invalids := []NamespaceQuota{
+		{Hard: coreV1.ResourceList{"gpus": resource.MustParse("1")}},
+		{Hard: coreV1.ResourceList{coreV1.ResourcePods: resource.MustParse("-1")}},
+		{DefaultLimits: coreV1.ResourceList{coreV1.ResourceStorage: resource.MustParse("1Gi")}},
+		{DefaultLimits: coreV1.ResourceList{coreV1.ResourceCPU: resource.MustParse("0")}},
+		{
+			DefaultRequests: coreV1.ResourceList{coreV1.ResourceMemory: resource.MustParse("1Gi")},
+			DefaultLimits:   coreV1.ResourceList{coreV1.ResourceMemory: resource.MustParse("512Mi")},
+		},
+	}
+
+	for _, invalid := range invalids {
+		assert.NotNil(t, invalid.Validate())
+	}
+}
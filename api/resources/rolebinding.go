@@ -6,6 +6,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"reflect"
 	"strings"
 )
 
@@ -184,6 +185,11 @@ func fixDefaultClusterRole(k8sClient *kubernetes.Clientset) (err error) {
 				Resources: []string{"namespaces", "nodes"},
 				Verbs:     []string{"get", "list"},
 			},
+			{
+				APIGroups: []string{"core.kapp.dev"},
+				Resources: []string{"componenttemplates"},
+				Verbs:     []string{"get", "list"},
+			},
 		},
 	})
 
@@ -194,37 +200,52 @@ func fixDefaultClusterRole(k8sClient *kubernetes.Clientset) (err error) {
 	return err
 }
 
+// fixNamespaceDefaultRole creates the default role of a kapp namespace, or updates its rules,
+// roles created by older versions miss the rules of newer features
 func fixNamespaceDefaultRole(k8sClient *kubernetes.Clientset, namespace string, roleName string) error {
+	var desired *rbacV1.Role
 
 	switch roleName {
 	case "reader":
-		_, err := k8sClient.RbacV1().Roles(namespace).Get(roleName, metaV1.GetOptions{})
+		desired = readerRole(namespace)
+	case "writer":
+		desired = writerRole(namespace)
+	default:
+		return nil
+	}
 
-		if err != nil {
-			if errors.IsNotFound(err) {
-				return createReaderRole(k8sClient, namespace)
-			} else {
-				return err
-			}
+	role, err := k8sClient.RbacV1().Roles(namespace).Get(roleName, metaV1.GetOptions{})
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			_, err = k8sClient.RbacV1().Roles(namespace).Create(desired)
 		}
 
-	case "writer":
-		_, err := k8sClient.RbacV1().Roles(namespace).Get(roleName, metaV1.GetOptions{})
+		return err
+	}
 
-		if err != nil {
-			if errors.IsNotFound(err) {
-				return createWriterRole(k8sClient, namespace)
-			} else {
-				return err
-			}
-		}
+	if reflect.DeepEqual(role.Rules, desired.Rules) {
+		return nil
 	}
 
-	return nil
+	role.Rules = desired.Rules
+	_, err = k8sClient.RbacV1().Roles(namespace).Update(role)
+
+	return err
 }
 
 func createReaderRole(k8sClient *kubernetes.Clientset, namespace string) (err error) {
-	_, err = k8sClient.RbacV1().Roles(namespace).Create(&rbacV1.Role{
+	_, err = k8sClient.RbacV1().Roles(namespace).Create(readerRole(namespace))
+	return err
+}
+
+func createWriterRole(k8sClient *kubernetes.Clientset, namespace string) (err error) {
+	_, err = k8sClient.RbacV1().Roles(namespace).Create(writerRole(namespace))
+	return err
+}
+
+func readerRole(namespace string) *rbacV1.Role {
+	return &rbacV1.Role{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name:      "reader",
 			Namespace: namespace,
@@ -235,7 +256,7 @@ func createReaderRole(k8sClient *kubernetes.Clientset, namespace string) (err er
 					"list", "get", "watch",
 				},
 				Resources: []string{
-					"pods", "events", "configmaps", "services",
+					"pods", "events", "configmaps", "services", "resourcequotas", "limitranges",
 				},
 				APIGroups: []string{
 					"",
@@ -252,6 +273,17 @@ func createReaderRole(k8sClient *kubernetes.Clientset, namespace string) (err er
 					"",
 				},
 			},
+			{
+				Verbs: []string{
+					"list", "get",
+				},
+				Resources: []string{
+					"pods",
+				},
+				APIGroups: []string{
+					"metrics.k8s.io",
+				},
+			},
 			{
 				Verbs: []string{
 					"list", "get", "watch",
@@ -268,23 +300,18 @@ func createReaderRole(k8sClient *kubernetes.Clientset, namespace string) (err er
 					"list", "get", "watch",
 				},
 				Resources: []string{
-					"applications",
+					"applications", "namespacedcomponenttemplates",
 				},
 				APIGroups: []string{
 					"core.kapp.dev",
 				},
 			},
 		},
-	})
-
-	if err != nil {
-		return err
 	}
-	return nil
 }
 
-func createWriterRole(k8sClient *kubernetes.Clientset, namespace string) (err error) {
-	_, err = k8sClient.RbacV1().Roles(namespace).Create(&rbacV1.Role{
+func writerRole(namespace string) *rbacV1.Role {
+	return &rbacV1.Role{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name:      "writer",
 			Namespace: namespace,
@@ -301,6 +328,17 @@ func createWriterRole(k8sClient *kubernetes.Clientset, namespace string) (err er
 					"",
 				},
 			},
+			{
+				Verbs: []string{
+					"list", "get", "watch",
+				},
+				Resources: []string{
+					"resourcequotas", "limitranges",
+				},
+				APIGroups: []string{
+					"",
+				},
+			},
 			{
 				Verbs: []string{
 					"get",
@@ -323,6 +361,17 @@ func createWriterRole(k8sClient *kubernetes.Clientset, namespace string) (err er
 					"",
 				},
 			},
+			{
+				Verbs: []string{
+					"list", "get",
+				},
+				Resources: []string{
+					"pods",
+				},
+				APIGroups: []string{
+					"metrics.k8s.io",
+				},
+			},
 			{
 				Verbs: []string{
 					"list", "get", "watch",
@@ -339,19 +388,14 @@ func createWriterRole(k8sClient *kubernetes.Clientset, namespace string) (err er
 					"list", "get", "watch", "update", "create", "patch", "delete",
 				},
 				Resources: []string{
-					"applications",
+					"applications", "namespacedcomponenttemplates",
 				},
 				APIGroups: []string{
 					"core.kapp.dev",
 				},
 			},
 		},
-	})
-
-	if err != nil {
-		return err
 	}
-	return nil
 }
 
 func createDefaultKappRoles(k8sClient *kubernetes.Clientset, namespace string) (err error) {
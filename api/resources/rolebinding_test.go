@@ -0,0 +1,40 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacV1 "k8s.io/api/rbac/v1"
+)
+
+func roleAllows(role *rbacV1.Role, group, resource, verb string) bool {
+	contains := func(list []string, value string) bool {
+		for _, item := range list {
+			if item == value || item == rbacV1.ResourceAll {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, rule := range role.Rules {
+		if contains(rule.APIGroups, group) && contains(rule.Resources, resource) && contains(rule.Verbs, verb) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestDefaultRolesAllowPodMetrics(t *testing.T) {
+	for _, role := range []*rbacV1.Role{readerRole("kapp-team"), writerRole("kapp-team")} {
+		assert.Equal(t, "kapp-team", role.Namespace)
+
+		for _, verb := range []string{"get", "list"} {
+			assert.True(t, roleAllows(role, "metrics.k8s.io", "pods", verb), "%s should %s metrics of pods", role.Name, verb)
+		}
+
+		assert.False(t, roleAllows(role, "metrics.k8s.io", "nodes", "list"), "%s should not list metrics of nodes", role.Name)
+	}
+}
@@ -0,0 +1,417 @@
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	metricv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	mclientv1beta1 "k8s.io/metrics/pkg/client/clientset/versioned/typed/metrics/v1beta1"
+)
+
+// a component is over-provisioned if the peak usage of its pods is below this ratio of their requests
+const overProvisionedRatio = 0.5
+
+// components are only flagged once the scraper has this much history of them
+const minOverProvisionedHistory = time.Hour
+
+// ResourceAmount is an amount of cpu in millicores and memory in bytes
+type ResourceAmount struct {
+	CPU    uint64 `json:"cpu"`
+	Memory uint64 `json:"memory"`
+}
+
+func (a *ResourceAmount) add(b ResourceAmount) {
+	a.CPU += b.CPU
+	a.Memory += b.Memory
+}
+
+// ResourceUsage is the sum of requests, limits and current usage of some pods
+type ResourceUsage struct {
+	Requests ResourceAmount `json:"requests"`
+	Limits   ResourceAmount `json:"limits"`
+	Usage    ResourceAmount `json:"usage"`
+	Pods     int            `json:"pods"`
+}
+
+func (u *ResourceUsage) add(b ResourceUsage) {
+	u.Requests.add(b.Requests)
+	u.Limits.add(b.Limits)
+	u.Usage.add(b.Usage)
+	u.Pods += b.Pods
+}
+
+type NamespaceUsage struct {
+	Name string `json:"name"`
+	ResourceUsage
+}
+
+type ApplicationUsage struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	ResourceUsage
+	Components []ComponentUsage `json:"components"`
+}
+
+// ComponentUsage has the peak and average usage of a pod of the component in the history of the scraper.
+// It's over-provisioned if the requests of a pod are far above its peak usage.
+type ComponentUsage struct {
+	Name string `json:"name"`
+	ResourceUsage
+	PodRequests     ResourceAmount `json:"podRequests"`
+	PeakPodUsage    ResourceAmount `json:"peakPodUsage"`
+	AveragePodUsage ResourceAmount `json:"averagePodUsage"`
+	// seconds of the history the peak and average come from
+	HistorySeconds  int64    `json:"historySeconds"`
+	OverProvisioned []string `json:"overProvisioned,omitempty"`
+}
+
+// NodeGroupUsage is the usage of the nodes with the same value of a label, e.g. a node pool,
+// capacity is the allocatable resources of the nodes
+type NodeGroupUsage struct {
+	Name     string         `json:"name"`
+	Nodes    []string       `json:"nodes"`
+	Capacity ResourceAmount `json:"capacity"`
+	ResourceUsage
+}
+
+type NodesUsage struct {
+	Label   string           `json:"label"`
+	Cluster NodeGroupUsage   `json:"cluster"`
+	Groups  []NodeGroupUsage `json:"groups"`
+}
+
+func resourceListAmount(list coreV1.ResourceList) ResourceAmount {
+	return ResourceAmount{
+		CPU:    uint64(list.Cpu().MilliValue()),
+		Memory: uint64(list.Memory().Value()),
+	}
+}
+
+// isPodConsuming is false for completed pods, their resources are free again
+func isPodConsuming(pod *coreV1.Pod) bool {
+	return pod.Status.Phase != coreV1.PodSucceeded && pod.Status.Phase != coreV1.PodFailed
+}
+
+// podResourceUsage of a pod, with the usage of its metrics if there are any
+func podResourceUsage(pod *coreV1.Pod, usages map[string]ResourceAmount) ResourceUsage {
+	usage := ResourceUsage{
+		Usage: usages[pod.Namespace+"/"+pod.Name],
+		Pods:  1,
+	}
+
+	for _, container := range pod.Spec.Containers {
+		usage.Requests.add(resourceListAmount(container.Resources.Requests))
+		usage.Limits.add(resourceListAmount(container.Resources.Limits))
+	}
+
+	return usage
+}
+
+// podUsagesOf keys the usage of the pods by namespace/name
+func podUsagesOf(list *metricv1beta1.PodMetricsList) map[string]ResourceAmount {
+	usages := make(map[string]ResourceAmount, len(list.Items))
+
+	for _, podMetrics := range list.Items {
+		point := podUsage(podMetrics)
+		usages[podMetrics.Namespace+"/"+podMetrics.Name] = ResourceAmount{CPU: point.CPU, Memory: point.Memory}
+	}
+
+	return usages
+}
+
+func isKappNamespace(name string) bool {
+	return strings.HasPrefix(name, KAPP_NAMESPACE_PREFIX) && name != KAPP_SYSTEM_NAMESPACE
+}
+
+func aggregateNamespacesUsage(pods []coreV1.Pod, usages map[string]ResourceAmount) []NamespaceUsage {
+	namespaces := make(map[string]*NamespaceUsage)
+
+	for i := range pods {
+		pod := &pods[i]
+		if !isKappNamespace(pod.Namespace) || !isPodConsuming(pod) {
+			continue
+		}
+
+		ns, exist := namespaces[pod.Namespace]
+		if !exist {
+			ns = &NamespaceUsage{Name: pod.Namespace}
+			namespaces[pod.Namespace] = ns
+		}
+
+		ns.add(podResourceUsage(pod, usages))
+	}
+
+	rst := make([]NamespaceUsage, 0, len(namespaces))
+	for _, ns := range namespaces {
+		rst = append(rst, *ns)
+	}
+
+	sort.Slice(rst, func(i, j int) bool { return rst[i].Name < rst[j].Name })
+
+	return rst
+}
+
+// aggregateApplicationsUsage groups the pods by their application and component labels,
+// the components are checked with their histories in the store
+func aggregateApplicationsUsage(pods []coreV1.Pod, usages map[string]ResourceAmount, store *metricsStore, now time.Time) []ApplicationUsage {
+	apps := make(map[string]*ApplicationUsage)
+	components := make(map[string]map[string]*ComponentUsage)
+
+	for i := range pods {
+		pod := &pods[i]
+		appName := pod.Labels["kapp-application"]
+		componentName := pod.Labels["kapp-component"]
+
+		if appName == "" || componentName == "" || !isPodConsuming(pod) {
+			continue
+		}
+
+		appKey := pod.Namespace + "/" + appName
+		app, exist := apps[appKey]
+		if !exist {
+			app = &ApplicationUsage{Namespace: pod.Namespace, Name: appName}
+			apps[appKey] = app
+			components[appKey] = make(map[string]*ComponentUsage)
+		}
+
+		component, exist := components[appKey][componentName]
+		if !exist {
+			component = &ComponentUsage{Name: componentName}
+			components[appKey][componentName] = component
+		}
+
+		podUsage := podResourceUsage(pod, usages)
+		app.add(podUsage)
+		component.add(podUsage)
+
+		// pods of a component have the same requests, but during a rolling update
+		if podUsage.Requests.CPU > component.PodRequests.CPU {
+			component.PodRequests.CPU = podUsage.Requests.CPU
+		}
+
+		if podUsage.Requests.Memory > component.PodRequests.Memory {
+			component.PodRequests.Memory = podUsage.Requests.Memory
+		}
+	}
+
+	rst := make([]ApplicationUsage, 0, len(apps))
+	for appKey, app := range apps {
+		for _, component := range components[appKey] {
+			checkComponentHistory(component, app.Namespace, store, now)
+			app.Components = append(app.Components, *component)
+		}
+
+		sort.Slice(app.Components, func(i, j int) bool { return app.Components[i].Name < app.Components[j].Name })
+		rst = append(rst, *app)
+	}
+
+	sort.Slice(rst, func(i, j int) bool {
+		if rst[i].Namespace != rst[j].Namespace {
+			return rst[i].Namespace < rst[j].Namespace
+		}
+
+		return rst[i].Name < rst[j].Name
+	})
+
+	return rst
+}
+
+// checkComponentHistory sets the peak and average usage of a pod of the component in the whole history of the store,
+// and flags the resources of which the requests are far above the peak usage
+func checkComponentHistory(component *ComponentUsage, namespace string, store *metricsStore, now time.Time) {
+	// components are keyed by namespace and name in the scraper
+	group := fmt.Sprintf("%s-%s", namespace, component.Name)
+
+	isComponent := func(key seriesKey) bool {
+		return key.Group == group
+	}
+
+	histories := store.histories(isComponent, &MetricsQuery{Start: now.Add(-store.retention()), End: now})
+
+	var since time.Time
+	var cpuSum, memorySum, count uint64
+
+	for _, podHistories := range histories {
+		for i, point := range podHistories.CPU {
+			memory := podHistories.Memory[i].Value
+
+			if since.IsZero() || point.Timestamp.Before(since) {
+				since = point.Timestamp
+			}
+
+			cpuSum += point.Value
+			memorySum += memory
+			count++
+		}
+	}
+
+	if count == 0 {
+		return
+	}
+
+	// the points of histories are averages of coarser tiers, the peaks keep the spikes
+	for _, peak := range store.peaks(isComponent, now.Add(-store.retention())) {
+		if peak.CPU > component.PeakPodUsage.CPU {
+			component.PeakPodUsage.CPU = peak.CPU
+		}
+
+		if peak.Memory > component.PeakPodUsage.Memory {
+			component.PeakPodUsage.Memory = peak.Memory
+		}
+	}
+
+	component.AveragePodUsage = ResourceAmount{CPU: cpuSum / count, Memory: memorySum / count}
+	component.HistorySeconds = int64(now.Sub(since).Seconds())
+
+	if now.Sub(since) < minOverProvisionedHistory {
+		return
+	}
+
+	if isOverProvisioned(component.PodRequests.CPU, component.PeakPodUsage.CPU) {
+		component.OverProvisioned = append(component.OverProvisioned, "cpu")
+	}
+
+	if isOverProvisioned(component.PodRequests.Memory, component.PeakPodUsage.Memory) {
+		component.OverProvisioned = append(component.OverProvisioned, "memory")
+	}
+}
+
+func isOverProvisioned(request, peak uint64) bool {
+	return request > 0 && float64(peak) < float64(request)*overProvisionedRatio
+}
+
+// aggregateNodesUsage groups the nodes by the value of the label, nodes without the label are in a group without name
+func aggregateNodesUsage(label string, nodes []coreV1.Node, nodeUsages map[string]ResourceAmount, pods []coreV1.Pod) NodesUsage {
+	rst := NodesUsage{
+		Label:   label,
+		Cluster: NodeGroupUsage{Nodes: []string{}},
+	}
+
+	groups := make(map[string]*NodeGroupUsage)
+	groupOfNode := make(map[string]*NodeGroupUsage, len(nodes))
+
+	for _, node := range nodes {
+		name := node.Labels[label]
+
+		group, exist := groups[name]
+		if !exist {
+			group = &NodeGroupUsage{Name: name}
+			groups[name] = group
+		}
+
+		group.Nodes = append(group.Nodes, node.Name)
+		group.Capacity.add(resourceListAmount(node.Status.Allocatable))
+		group.Usage.add(nodeUsages[node.Name])
+		groupOfNode[node.Name] = group
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		if !isPodConsuming(pod) {
+			continue
+		}
+
+		group, exist := groupOfNode[pod.Spec.NodeName]
+		if !exist {
+			continue
+		}
+
+		// usage of the nodes is measured on the nodes, not the sum of their pods
+		podUsage := podResourceUsage(pod, nil)
+		group.Requests.add(podUsage.Requests)
+		group.Limits.add(podUsage.Limits)
+		group.Pods++
+	}
+
+	for _, group := range groups {
+		sort.Strings(group.Nodes)
+		rst.Groups = append(rst.Groups, *group)
+
+		rst.Cluster.Nodes = append(rst.Cluster.Nodes, group.Nodes...)
+		rst.Cluster.Capacity.add(group.Capacity)
+		rst.Cluster.add(group.ResourceUsage)
+	}
+
+	sort.Strings(rst.Cluster.Nodes)
+	sort.Slice(rst.Groups, func(i, j int) bool { return rst.Groups[i].Name < rst.Groups[j].Name })
+
+	return rst
+}
+
+// GetNamespacesUsage returns the requests, limits and usage of the pods in each of the kapp namespaces
+func GetNamespacesUsage(k8sClient *kubernetes.Clientset, metricClient mclientv1beta1.MetricsV1beta1Interface, namespaces []string) ([]NamespaceUsage, error) {
+	pods, usages, err := listPodUsages(k8sClient, metricClient, namespaces, ListAll)
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregateNamespacesUsage(pods, usages), nil
+}
+
+// GetApplicationsUsage returns the requests, limits and usage of the applications in the namespaces.
+// Over-provisioned components are flagged by the history of the scraper.
+func GetApplicationsUsage(k8sClient *kubernetes.Clientset, metricClient mclientv1beta1.MetricsV1beta1Interface, namespaces []string) ([]ApplicationUsage, error) {
+	pods, usages, err := listPodUsages(k8sClient, metricClient, namespaces, metaV1.ListOptions{LabelSelector: "kapp-application,kapp-component"})
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregateApplicationsUsage(pods, usages, podMetricsStore, time.Now()), nil
+}
+
+// listPodUsages lists the pods and their metrics namespace by namespace,
+// so callers who are only bound to roles in some namespaces can read them
+func listPodUsages(k8sClient *kubernetes.Clientset, metricClient mclientv1beta1.MetricsV1beta1Interface, namespaces []string, podOptions metaV1.ListOptions) ([]coreV1.Pod, map[string]ResourceAmount, error) {
+	var pods []coreV1.Pod
+	usages := make(map[string]ResourceAmount)
+
+	for _, namespace := range namespaces {
+		list, err := k8sClient.CoreV1().Pods(namespace).List(podOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pods = append(pods, list.Items...)
+
+		podMetrics, err := metricClient.PodMetricses(namespace).List(ListAll)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for key, usage := range podUsagesOf(podMetrics) {
+			usages[key] = usage
+		}
+	}
+
+	return pods, usages, nil
+}
+
+// GetNodesUsage returns the capacity, requests, limits and usage of the nodes, grouped by the value of the label
+func GetNodesUsage(k8sClient *kubernetes.Clientset, metricClient mclientv1beta1.MetricsV1beta1Interface, label string) (NodesUsage, error) {
+	nodes, err := k8sClient.CoreV1().Nodes().List(ListAll)
+	if err != nil {
+		return NodesUsage{}, err
+	}
+
+	pods, err := k8sClient.CoreV1().Pods("").List(ListAll)
+	if err != nil {
+		return NodesUsage{}, err
+	}
+
+	nodeMetrics, err := metricClient.NodeMetricses().List(ListAll)
+	if err != nil {
+		return NodesUsage{}, err
+	}
+
+	nodeUsages := make(map[string]ResourceAmount, len(nodeMetrics.Items))
+	for _, item := range nodeMetrics.Items {
+		nodeUsages[item.Name] = resourceListAmount(item.Usage)
+	}
+
+	return aggregateNodesUsage(label, nodes.Items, nodeUsages, pods.Items), nil
+}
@@ -0,0 +1,164 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPod(namespace, name, app, component, node string, cpu, memory string) coreV1.Pod {
+	return coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{"kapp-application": app, "kapp-component": component},
+		},
+		Spec: coreV1.PodSpec{
+			NodeName: node,
+			Containers: []coreV1.Container{
+				{
+					Name: component,
+					Resources: coreV1.ResourceRequirements{
+						Requests: coreV1.ResourceList{
+							coreV1.ResourceCPU:    resource.MustParse(cpu),
+							coreV1.ResourceMemory: resource.MustParse(memory),
+						},
+						Limits: coreV1.ResourceList{
+							coreV1.ResourceMemory: resource.MustParse(memory),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestAggregateNamespacesUsage(t *testing.T) {
+	completed := testPod("kapp-a", "job-0", "app", "job", "node-1", "1", "1Gi")
+	completed.Status.Phase = coreV1.PodSucceeded
+
+	pods := []coreV1.Pod{
+		testPod("kapp-a", "web-0", "app", "web", "node-1", "100m", "128Mi"),
+		testPod("kapp-a", "web-1", "app", "web", "node-1", "100m", "128Mi"),
+		testPod("kapp-b", "api-0", "app", "api", "node-2", "500m", "1Gi"),
+		testPod("kube-system", "dns-0", "", "", "node-1", "100m", "64Mi"),
+		completed,
+	}
+
+	usages := map[string]ResourceAmount{"kapp-a/web-0": {CPU: 10, Memory: 1 << 20}}
+
+	namespaces := aggregateNamespacesUsage(pods, usages)
+	assert.Len(t, namespaces, 2)
+	assert.Equal(t, "kapp-a", namespaces[0].Name)
+	assert.Equal(t, 2, namespaces[0].Pods)
+	assert.Equal(t, ResourceAmount{CPU: 200, Memory: 256 << 20}, namespaces[0].Requests)
+	assert.Equal(t, ResourceAmount{CPU: 0, Memory: 256 << 20}, namespaces[0].Limits)
+	assert.Equal(t, ResourceAmount{CPU: 10, Memory: 1 << 20}, namespaces[0].Usage)
+	assert.Equal(t, uint64(500), namespaces[1].Requests.CPU)
+}
+
+func TestAggregateApplicationsUsage(t *testing.T) {
+	store := newMetricsStore(metricsTiersOf(3 * time.Hour))
+	now := time.Now()
+
+	// web uses far less than its requests for 2 hours, api is close to its requests
+	for i := 0; i < 120; i++ {
+		ts := now.Add(-2 * time.Hour).Add(time.Duration(i) * time.Minute)
+		store.add(seriesKey{Group: "kapp-a-web", Name: "web-0"}, usagePoint{Timestamp: ts, CPU: 10, Memory: 100 << 20})
+		store.add(seriesKey{Group: "kapp-a-api", Name: "api-0"}, usagePoint{Timestamp: ts, CPU: 400, Memory: 100 << 20})
+	}
+
+	// worker has no long enough history to be flagged
+	store.add(seriesKey{Group: "kapp-a-worker", Name: "worker-0"}, usagePoint{Timestamp: now.Add(-time.Minute), CPU: 1, Memory: 1})
+
+	pods := []coreV1.Pod{
+		testPod("kapp-a", "web-0", "app", "web", "node-1", "1", "128Mi"),
+		testPod("kapp-a", "api-0", "app", "api", "node-1", "500m", "1Gi"),
+		testPod("kapp-a", "worker-0", "app", "worker", "node-1", "1", "1Gi"),
+		testPod("kapp-a", "other-0", "other", "web", "node-1", "1", "1Gi"),
+	}
+
+	apps := aggregateApplicationsUsage(pods, nil, store, now)
+	assert.Len(t, apps, 2)
+	assert.Equal(t, "app", apps[0].Name)
+	assert.Equal(t, 3, apps[0].Pods)
+	assert.Equal(t, uint64(2500), apps[0].Requests.CPU)
+
+	components := apps[0].Components
+	assert.Equal(t, "api", components[0].Name)
+	assert.Equal(t, []string{"memory"}, components[0].OverProvisioned)
+	assert.Equal(t, uint64(400), components[0].PeakPodUsage.CPU)
+
+	assert.Equal(t, "web", components[1].Name)
+	assert.Equal(t, []string{"cpu"}, components[1].OverProvisioned)
+	assert.True(t, components[1].HistorySeconds >= int64(time.Hour.Seconds()))
+
+	assert.Equal(t, "worker", components[2].Name)
+	assert.Empty(t, components[2].OverProvisioned)
+	assert.Equal(t, uint64(1), components[2].AveragePodUsage.CPU)
+}
+
+func TestAggregateApplicationsUsagePeak(t *testing.T) {
+	store := newMetricsStore(metricsTiersOf(DefaultMetricsRetention))
+	now := time.Now().Truncate(time.Hour)
+	key := seriesKey{Group: "kapp-a-web", Name: "web-0"}
+
+	// a spike of a minute 4 hours ago, in the tier of 5 minutes, where it's averaged to 208m
+	for ts := now.Add(-5 * time.Hour); ts.Before(now); ts = ts.Add(time.Minute) {
+		cpu := uint64(10)
+		if ts.Equal(now.Add(-4 * time.Hour)) {
+			cpu = 1000
+		}
+
+		store.add(key, usagePoint{Timestamp: ts, CPU: cpu, Memory: 100 << 20})
+	}
+
+	pods := []coreV1.Pod{testPod("kapp-a", "web-0", "app", "web", "node-1", "1", "128Mi")}
+
+	apps := aggregateApplicationsUsage(pods, nil, store, now)
+	component := apps[0].Components[0]
+	assert.Equal(t, uint64(1000), component.PeakPodUsage.CPU)
+	assert.Empty(t, component.OverProvisioned)
+}
+
+func TestAggregateNodesUsage(t *testing.T) {
+	node := func(name, pool string) coreV1.Node {
+		return coreV1.Node{
+			ObjectMeta: metaV1.ObjectMeta{Name: name, Labels: map[string]string{"pool": pool}},
+			Status: coreV1.NodeStatus{
+				Allocatable: coreV1.ResourceList{
+					coreV1.ResourceCPU:    resource.MustParse("2"),
+					coreV1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+			},
+		}
+	}
+
+	nodes := []coreV1.Node{node("node-1", "default"), node("node-2", "default"), node("node-3", "gpu")}
+	nodeUsages := map[string]ResourceAmount{"node-1": {CPU: 100}, "node-3": {CPU: 300}}
+	pods := []coreV1.Pod{
+		testPod("kapp-a", "web-0", "app", "web", "node-1", "100m", "128Mi"),
+		testPod("kapp-a", "api-0", "app", "api", "node-3", "1", "1Gi"),
+		testPod("kapp-a", "pending-0", "app", "api", "", "1", "1Gi"),
+	}
+
+	usage := aggregateNodesUsage("pool", nodes, nodeUsages, pods)
+	assert.Len(t, usage.Groups, 2)
+
+	assert.Equal(t, "default", usage.Groups[0].Name)
+	assert.Equal(t, []string{"node-1", "node-2"}, usage.Groups[0].Nodes)
+	assert.Equal(t, ResourceAmount{CPU: 4000, Memory: 8 << 30}, usage.Groups[0].Capacity)
+	assert.Equal(t, uint64(100), usage.Groups[0].Requests.CPU)
+	assert.Equal(t, uint64(100), usage.Groups[0].Usage.CPU)
+	assert.Equal(t, 1, usage.Groups[0].Pods)
+
+	assert.Equal(t, []string{"node-1", "node-2", "node-3"}, usage.Cluster.Nodes)
+	assert.Equal(t, uint64(6000), usage.Cluster.Capacity.CPU)
+	assert.Equal(t, uint64(1100), usage.Cluster.Requests.CPU)
+	assert.Equal(t, uint64(400), usage.Cluster.Usage.CPU)
+	assert.Equal(t, 2, usage.Cluster.Pods)
+}
@@ -0,0 +1,159 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type ApplicationAlertType string
+
+const (
+	// restarts of containers of a component in the window are more than the threshold
+	AlertTypeRestarts ApplicationAlertType = "restarts"
+	// available replicas of a component are less than the threshold percent of the desired replicas
+	AlertTypeAvailability ApplicationAlertType = "availability"
+	// memory of a container of a component is more than the threshold percent of its limit
+	AlertTypeMemoryNearLimit ApplicationAlertType = "memoryNearLimit"
+	// the expr is a PromQL expression, the alert fires when it returns any series
+	AlertTypeCustom ApplicationAlertType = "custom"
+)
+
+const (
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// labels of the alerts rendered from an application, the alerts of an application are found by them
+const (
+	AlertLabelApplication = "kapp_application"
+	AlertLabelComponent   = "kapp_component"
+)
+
+// window of restarts by default
+const DefaultAlertWindow = "1h"
+
+// ApplicationAlert is rendered into a rule of the PrometheusRule of the application,
+// when the kube-prometheus dependency is running
+type ApplicationAlert struct {
+	// name of the alert in prometheus, e.g. WebRestarting
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Enum=restarts;availability;memoryNearLimit;custom
+	// +kubebuilder:validation:Required
+	Type ApplicationAlertType `json:"type"`
+
+	// name of the component watched by the alert, all components if it's empty. Not used by custom alerts.
+	// +optional
+	Component string `json:"component,omitempty"`
+
+	// restarts for restarts alerts, percent for availability and memoryNearLimit alerts
+	// +optional
+	Threshold int32 `json:"threshold,omitempty"`
+
+	// window to count restarts in, e.g. 1h, 1h by default
+	// +optional
+	Window string `json:"window,omitempty"`
+
+	// PromQL expression of custom alerts, its selectors only select series of the namespace of the application
+	// +optional
+	Expr string `json:"expr,omitempty"`
+
+	// how long the condition lasts before the alert fires, e.g. 5m
+	// +optional
+	For string `json:"for,omitempty"`
+
+	// +kubebuilder:validation:Enum=warning;critical
+	// +optional
+	Severity string `json:"severity,omitempty"`
+
+	// +optional
+	Summary string `json:"summary,omitempty"`
+}
+
+// GetSeverity returns the severity, or warning if it's not set
+func (a *ApplicationAlert) GetSeverity() string {
+	if a.Severity == "" {
+		return AlertSeverityWarning
+	}
+
+	return a.Severity
+}
+
+// GetWindow returns the window, or the default one if it's not set
+func (a *ApplicationAlert) GetWindow() string {
+	if a.Window == "" {
+		return DefaultAlertWindow
+	}
+
+	return a.Window
+}
+
+// alert names of prometheus have the format of metric names
+var alertNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func isValidAlerts(spec ApplicationSpec) error {
+	names := make(map[string]bool, len(spec.Alerts))
+
+	for _, alert := range spec.Alerts {
+		if !alertNameRegex.MatchString(alert.Name) {
+			return fmt.Errorf("name of alert should match %s: %s", alertNameRegex.String(), alert.Name)
+		}
+
+		if names[alert.Name] {
+			return fmt.Errorf("duplicated alert: %s", alert.Name)
+		}
+		names[alert.Name] = true
+
+		if err := isValidAlert(spec, alert); err != nil {
+			return fmt.Errorf("invalid alert %s: %s", alert.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func isValidAlert(spec ApplicationSpec, alert ApplicationAlert) error {
+	switch alert.Type {
+	case AlertTypeRestarts:
+		if alert.Threshold < 0 {
+			return fmt.Errorf("threshold of restarts should not be negative: %d", alert.Threshold)
+		}
+
+		if !isPositivePrometheusDuration(alert.GetWindow()) {
+			return fmt.Errorf("window should be a positive duration of prometheus, e.g. 90m: %s", alert.Window)
+		}
+	case AlertTypeAvailability, AlertTypeMemoryNearLimit:
+		if alert.Threshold <= 0 || alert.Threshold > 100 {
+			return fmt.Errorf("threshold should be a percent between 1 and 100: %d", alert.Threshold)
+		}
+	case AlertTypeCustom:
+		if alert.Expr == "" {
+			return fmt.Errorf("expr is required by custom alerts")
+		}
+
+		if _, err := NamespacedAlertExpr(alert.Expr, ""); err != nil {
+			return fmt.Errorf("invalid expr: %s", err)
+		}
+	default:
+		return fmt.Errorf("unknown type: %s", alert.Type)
+	}
+
+	if alert.Component != "" && findComponent(&spec, alert.Component) == nil {
+		return fmt.Errorf("component %s does not exist", alert.Component)
+	}
+
+	// annotations are templates of prometheus, which can query any series
+	if strings.Contains(alert.Summary, "{{") {
+		return fmt.Errorf("summary should not be a template")
+	}
+
+	if alert.For != "" {
+		if !prometheusDurationRegex.MatchString(alert.For) {
+			return fmt.Errorf("for should be a duration of prometheus, e.g. 90m: %s", alert.For)
+		}
+	}
+
+	return nil
+}
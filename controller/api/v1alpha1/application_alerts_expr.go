@@ -0,0 +1,270 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the PromQL operators which are written like metric names, they never select series
+var promQLKeywords = map[string]bool{
+	"and": true, "or": true, "unless": true, "bool": true, "offset": true, "atan2": true,
+	"sum": true, "min": true, "max": true, "avg": true, "group": true, "stddev": true, "stdvar": true,
+	"count": true, "count_values": true, "bottomk": true, "topk": true, "quantile": true,
+	"inf": true, "nan": true,
+}
+
+// modifiers followed by a list of label names
+var promQLLabelListKeywords = map[string]bool{
+	"by": true, "without": true, "on": true, "ignoring": true, "group_left": true, "group_right": true,
+}
+
+// NamespacedAlertExpr adds a namespace="<namespace>" matcher to every selector of a PromQL expression,
+// so a custom alert of an application only reads series of its own namespace, as prometheus loads
+// the rules of all namespaces. Expressions selecting a namespace themselves are rejected.
+func NamespacedAlertExpr(expr, namespace string) (string, error) {
+	tokens, err := lexPromQL(expr)
+	if err != nil {
+		return "", err
+	}
+
+	namespaceMatcher := "namespace=" + strconv.Quote(namespace)
+
+	var b strings.Builder
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		next := nextPromQLToken(tokens, i)
+
+		switch {
+		case token.kind == promQLIdentifier && promQLLabelListKeywords[strings.ToLower(token.text)]:
+			b.WriteString(token.text)
+
+			// the label list is copied as is, it has no selectors
+			if next >= 0 && tokens[next].text == "(" {
+				end := closingPromQLToken(tokens, next, "(", ")")
+				if end < 0 {
+					return "", fmt.Errorf("unclosed label list after %s", token.text)
+				}
+
+				for _, t := range tokens[i+1 : end+1] {
+					b.WriteString(t.text)
+				}
+				i = end
+			}
+		case token.kind == promQLIdentifier && (promQLKeywords[strings.ToLower(token.text)] || (next >= 0 && tokens[next].text == "(")):
+			// operators, aggregations and functions
+			b.WriteString(token.text)
+		case token.kind == promQLIdentifier || token.text == "{":
+			// a selector, with or without a metric name
+			if token.kind == promQLIdentifier {
+				b.WriteString(token.text)
+			}
+
+			start := i
+			if token.kind == promQLIdentifier {
+				if next < 0 || tokens[next].text != "{" {
+					b.WriteString("{" + namespaceMatcher + "}")
+					continue
+				}
+				start = next
+			}
+
+			end := closingPromQLToken(tokens, start, "{", "}")
+			if end < 0 {
+				return "", fmt.Errorf("unclosed selector")
+			}
+
+			matchers, err := namespacedMatchers(tokens[start+1:end], namespaceMatcher)
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString("{" + matchers + "}")
+			i = end
+		default:
+			b.WriteString(token.text)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// namespacedMatchers puts the namespace matcher before the matchers of a selector
+func namespacedMatchers(tokens []promQLToken, namespaceMatcher string) (string, error) {
+	matchers := []string{namespaceMatcher}
+
+	var current []promQLToken
+	flush := func() error {
+		var significant []promQLToken
+		for _, t := range current {
+			if t.kind != promQLSpace {
+				significant = append(significant, t)
+			}
+		}
+		current = nil
+
+		// trailing comma
+		if len(significant) == 0 {
+			return nil
+		}
+
+		if len(significant) != 3 || significant[0].kind != promQLIdentifier || significant[2].kind != promQLString {
+			return fmt.Errorf("invalid label matcher")
+		}
+
+		switch significant[1].text {
+		case "=", "!=", "=~", "!~":
+		default:
+			return fmt.Errorf("invalid operator of label matcher: %s", significant[1].text)
+		}
+
+		if significant[0].text == "namespace" {
+			return fmt.Errorf("namespace can't be selected, it's always the namespace of the application")
+		}
+
+		matchers = append(matchers, significant[0].text+significant[1].text+significant[2].text)
+		return nil
+	}
+
+	for _, t := range tokens {
+		if t.text == "," {
+			if err := flush(); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		current = append(current, t)
+	}
+
+	if err := flush(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(matchers, ","), nil
+}
+
+type promQLTokenKind int
+
+const (
+	promQLSpace promQLTokenKind = iota
+	promQLIdentifier
+	promQLNumber
+	promQLString
+	promQLPunctuation
+)
+
+type promQLToken struct {
+	kind promQLTokenKind
+	text string
+}
+
+func nextPromQLToken(tokens []promQLToken, i int) int {
+	for j := i + 1; j < len(tokens); j++ {
+		if tokens[j].kind != promQLSpace {
+			return j
+		}
+	}
+
+	return -1
+}
+
+// closingPromQLToken returns the index of the token closing the one at start, -1 if it's not closed
+func closingPromQLToken(tokens []promQLToken, start int, open, close string) int {
+	depth := 0
+
+	for j := start; j < len(tokens); j++ {
+		switch tokens[j].text {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+
+	return -1
+}
+
+func isPromQLIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isPromQLIdentifierPart(c byte) bool {
+	return isPromQLIdentifierStart(c) || c == ':' || (c >= '0' && c <= '9')
+}
+
+// lexPromQL splits the expression into tokens, comments are dropped
+func lexPromQL(expr string) ([]promQLToken, error) {
+	var tokens []promQLToken
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			for i < len(expr) && strings.IndexByte(" \t\n\r", expr[i]) >= 0 {
+				i++
+			}
+			tokens = append(tokens, promQLToken{promQLSpace, expr[start:i]})
+		case c == '#':
+			for i < len(expr) && expr[i] != '\n' {
+				i++
+			}
+		case c == '"' || c == '\'' || c == '`':
+			i++
+			for i < len(expr) && expr[i] != c {
+				if expr[i] == '\\' && c != '`' {
+					i++
+				}
+				i++
+			}
+
+			if i >= len(expr) {
+				return nil, fmt.Errorf("unclosed string")
+			}
+			i++
+			tokens = append(tokens, promQLToken{promQLString, expr[start:i]})
+		case isPromQLIdentifierStart(c):
+			for i < len(expr) && isPromQLIdentifierPart(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, promQLToken{promQLIdentifier, expr[start:i]})
+		case (c >= '0' && c <= '9') || c == '.':
+			// numbers and durations, e.g. 1.5, 1e-3, 0x1f, 5m
+			for i < len(expr) {
+				d := expr[i]
+				if (d >= '0' && d <= '9') || d == '.' || isPromQLIdentifierStart(d) {
+					i++
+				} else if (d == '+' || d == '-') && (expr[i-1] == 'e' || expr[i-1] == 'E') && !strings.HasPrefix(strings.ToLower(expr[start:i]), "0x") {
+					i++
+				} else {
+					break
+				}
+			}
+			tokens = append(tokens, promQLToken{promQLNumber, expr[start:i]})
+		default:
+			i++
+
+			// operators of two characters
+			if i < len(expr) {
+				switch expr[start : i+1] {
+				case "==", "!=", ">=", "<=", "=~", "!~":
+					i++
+				}
+			}
+
+			text := expr[start:i]
+			if strings.IndexAny(text, "(){}[],:+-*/%^=!<>@") != 0 {
+				return nil, fmt.Errorf("unexpected character %q", text)
+			}
+
+			tokens = append(tokens, promQLToken{promQLPunctuation, text})
+		}
+	}
+
+	return tokens, nil
+}
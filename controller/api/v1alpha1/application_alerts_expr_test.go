@@ -0,0 +1,41 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespacedAlertExpr(t *testing.T) {
+	valids := map[string]string{
+		`up == 0`: `up{namespace="kapp-a"} == 0`,
+		`rate(http_requests_total{code="500", method=~"GET|POST",}[5m]) > 1`:                              `rate(http_requests_total{namespace="kapp-a",code="500",method=~"GET|POST"}[5m]) > 1`,
+		`sum by (pod) (rate(errors_total[5m] offset 1h)) / ignoring(code) group_left sum(requests_total)`: `sum by (pod) (rate(errors_total{namespace="kapp-a"}[5m] offset 1h)) / ignoring(code) group_left sum(requests_total{namespace="kapp-a"})`,
+		`{__name__=~"job:.*"} > 1e-3`:                              `{namespace="kapp-a",__name__=~"job:.*"} > 1e-3`,
+		`max_over_time(queue_size[1h:5m]) > bool 10 and vector(1)`: `max_over_time(queue_size{namespace="kapp-a"}[1h:5m]) > bool 10 and vector(1)`,
+		`label_replace(up, "a", "$1", "b", "(.*)") # comment`:      `label_replace(up{namespace="kapp-a"}, "a", "$1", "b", "(.*)") `,
+		`count_values("version", build_info) > 1`:                  `count_values("version", build_info{namespace="kapp-a"}) > 1`,
+	}
+
+	for expr, expected := range valids {
+		namespaced, err := NamespacedAlertExpr(expr, "kapp-a")
+		assert.Nil(t, err, expr)
+		assert.Equal(t, expected, namespaced, expr)
+	}
+
+	invalids := []string{
+		// series of other namespaces
+		`up{namespace="kapp-b"} == 0`,
+		`sum(rate(http_requests_total{code="500",namespace!="kapp-a"}[5m]))`,
+		`{namespace=~".+"}`,
+		`up{job="a" == 0`,
+		`up{job=a}`,
+		`up{job="a}`,
+		`up; drop`,
+	}
+
+	for _, expr := range invalids {
+		_, err := NamespacedAlertExpr(expr, "kapp-a")
+		assert.NotNil(t, err, expr)
+	}
+}
@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsValidAlerts(t *testing.T) {
+	spec := ApplicationSpec{
+		Components: []ComponentSpec{{Name: "web"}},
+		Alerts: []ApplicationAlert{
+			{Name: "WebRestarting", Type: AlertTypeRestarts, Component: "web", Threshold: 3},
+			{Name: "Unavailable", Type: AlertTypeAvailability, Threshold: 50, For: "5m", Severity: AlertSeverityCritical},
+			{Name: "MemoryNearLimit", Type: AlertTypeMemoryNearLimit, Threshold: 90},
+			{Name: "Errors", Type: AlertTypeCustom, Expr: `rate(http_requests_total{code="500"}[5m]) > 1`},
+		},
+	}
+	assert.Nil(t, TryValidateApplication(spec))
+	assert.Equal(t, DefaultAlertWindow, spec.Alerts[0].GetWindow())
+	assert.Equal(t, AlertSeverityWarning, spec.Alerts[0].GetSeverity())
+
+	invalids := []ApplicationAlert{
+		{Name: "web-restarting", Type: AlertTypeRestarts},
+		{Name: "WebRestarting", Type: AlertTypeRestarts},
+		{Name: "Restarting", Type: AlertTypeRestarts, Window: "1"},
+		{Name: "Restarting", Type: AlertTypeRestarts, Component: "worker"},
+		{Name: "Unavailable2", Type: AlertTypeAvailability, Threshold: 120},
+		{Name: "Errors2", Type: AlertTypeCustom},
+		{Name: "Errors2", Type: AlertTypeCustom, Expr: "up == 0", For: "5"},
+		{Name: "Errors2", Type: AlertTypeCustom, Expr: "up == 0", For: "1h30m"},
+		{Name: "Restarting", Type: AlertTypeRestarts, Window: "1h30m"},
+		{Name: "Restarting", Type: AlertTypeRestarts, Window: "1.5h"},
+		{Name: "Restarting", Type: AlertTypeRestarts, Window: "0m"},
+		{Name: "Errors2", Type: AlertTypeCustom, Expr: `sum(rate(http_requests_total{namespace="kapp-other"}[5m])) > 1`},
+		{Name: "Errors2", Type: AlertTypeCustom, Expr: "up == 0", Summary: `{{ query "up" }}`},
+		{Name: "Unknown", Type: "unknown"},
+	}
+
+	for _, invalid := range invalids {
+		s := spec
+		s.Alerts = append(append([]ApplicationAlert{}, spec.Alerts...), invalid)
+		assert.NotNil(t, TryValidateApplication(s), invalid.Name)
+	}
+}
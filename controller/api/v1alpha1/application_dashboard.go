@@ -0,0 +1,15 @@
+package v1alpha1
+
+import (
+	"crypto/sha1"
+	"fmt"
+)
+
+// ConfigMaps with this label are loaded as dashboards by the grafana of kube-prometheus
+const GrafanaDashboardLabel = "kapp-grafana-dashboard"
+
+// GrafanaDashboardUID is the uid of the dashboard of an application, the url of the dashboard is /d/<uid>.
+// uid of grafana is at most 40 characters, so it's hashed from the namespace and the name.
+func GrafanaDashboardUID(namespace, name string) string {
+	return fmt.Sprintf("kapp-%x", sha1.Sum([]byte(namespace+"/"+name)))[:21]
+}
@@ -0,0 +1,140 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResolveApplicationSpec returns a copy of spec with the named overlay applied.
+// An empty overlayName returns a copy of spec unchanged.
+// The returned spec has no overlays, it is what the controller actually reconciles.
+func ResolveApplicationSpec(spec ApplicationSpec, overlayName string) (*ApplicationSpec, error) {
+	resolved := spec.DeepCopy()
+	resolved.Overlays = nil
+	resolved.Overlay = overlayName
+
+	if overlayName == "" {
+		return resolved, nil
+	}
+
+	overlay := findOverlay(spec, overlayName)
+
+	if overlay == nil {
+		return nil, fmt.Errorf("overlay %s not found", overlayName)
+	}
+
+	resolved.SharedEnv = mergeEnv(resolved.SharedEnv, overlay.SharedEnv)
+
+	for _, componentOverlay := range overlay.Components {
+		component := findComponent(resolved, componentOverlay.Name)
+
+		if component == nil {
+			return nil, fmt.Errorf("component %s in overlay %s not found", componentOverlay.Name, overlayName)
+		}
+
+		if componentOverlay.Replicas != nil {
+			replicas := *componentOverlay.Replicas
+			component.Replicas = &replicas
+		}
+
+		if componentOverlay.ImageTag != "" {
+			component.Image = replaceImageTag(component.Image, componentOverlay.ImageTag)
+		}
+
+		if componentOverlay.CPU != nil {
+			cpu := componentOverlay.CPU.DeepCopy()
+			component.CPU = &cpu
+		}
+
+		if componentOverlay.Memory != nil {
+			memory := componentOverlay.Memory.DeepCopy()
+			component.Memory = &memory
+		}
+
+		component.Env = mergeEnv(component.Env, componentOverlay.Env)
+	}
+
+	return resolved, nil
+}
+
+// check overlay names are unique, every overlay only refers to existing components,
+// and the selected overlay exists
+func isValidOverlays(spec ApplicationSpec) error {
+	names := make(map[string]bool)
+
+	for _, overlay := range spec.Overlays {
+		if overlay.Name == "" {
+			return fmt.Errorf("overlay name is required")
+		}
+
+		if names[overlay.Name] {
+			return fmt.Errorf("duplicate overlay: %s", overlay.Name)
+		}
+
+		names[overlay.Name] = true
+
+		if _, err := ResolveApplicationSpec(spec, overlay.Name); err != nil {
+			return err
+		}
+	}
+
+	if spec.Overlay != "" && !names[spec.Overlay] {
+		return fmt.Errorf("overlay %s not found", spec.Overlay)
+	}
+
+	return nil
+}
+
+func findOverlay(spec ApplicationSpec, name string) *ApplicationOverlay {
+	for i := range spec.Overlays {
+		if spec.Overlays[i].Name == name {
+			return &spec.Overlays[i]
+		}
+	}
+
+	return nil
+}
+
+func findComponent(spec *ApplicationSpec, name string) *ComponentSpec {
+	for i := range spec.Components {
+		if spec.Components[i].Name == name {
+			return &spec.Components[i]
+		}
+	}
+
+	return nil
+}
+
+func mergeEnv(envs []EnvVar, overrides []EnvVar) []EnvVar {
+	for _, override := range overrides {
+		replaced := false
+
+		for i := range envs {
+			if envs[i].Name == override.Name {
+				envs[i] = override
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			envs = append(envs, override)
+		}
+	}
+
+	return envs
+}
+
+// replaceImageTag("registry:5000/nginx:1.17", "1.18") => "registry:5000/nginx:1.18"
+// a digest in image is dropped, since it would pin the old tag.
+func replaceImageTag(image, tag string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+
+	return image + ":" + tag
+}
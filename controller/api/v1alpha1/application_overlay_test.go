@@ -0,0 +1,95 @@
+package v1alpha1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"testing"
+)
+
+func overlayAppSpec() ApplicationSpec {
+	replicas := int32(3)
+	memory := resource.MustParse("1Gi")
+
+	return ApplicationSpec{
+		Components: []ComponentSpec{
+			{
+				Name:  "web",
+				Image: "nginx:1.17",
+				Env: []EnvVar{
+					{Name: "LOG_LEVEL", Value: "debug"},
+				},
+			},
+		},
+		SharedEnv: []EnvVar{
+			{Name: "DOMAIN", Value: "staging.example.com"},
+		},
+		Overlays: []ApplicationOverlay{
+			{
+				Name: "production",
+				SharedEnv: []EnvVar{
+					{Name: "DOMAIN", Value: "example.com"},
+				},
+				Components: []ComponentOverlay{
+					{
+						Name:     "web",
+						Replicas: &replicas,
+						ImageTag: "1.18",
+						Memory:   &memory,
+						Env: []EnvVar{
+							{Name: "LOG_LEVEL", Value: "info"},
+							{Name: "CACHE", Value: "on"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestResolveApplicationSpec(t *testing.T) {
+	spec := overlayAppSpec()
+
+	resolved, err := ResolveApplicationSpec(spec, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "nginx:1.17", resolved.Components[0].Image)
+	assert.Nil(t, resolved.Overlays)
+
+	resolved, err = ResolveApplicationSpec(spec, "production")
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com", resolved.SharedEnv[0].Value)
+	assert.Equal(t, int32(3), *resolved.Components[0].Replicas)
+	assert.Equal(t, "nginx:1.18", resolved.Components[0].Image)
+	assert.Equal(t, "1Gi", resolved.Components[0].Memory.String())
+	assert.Equal(t, []EnvVar{{Name: "LOG_LEVEL", Value: "info"}, {Name: "CACHE", Value: "on"}}, resolved.Components[0].Env)
+
+	// the original spec is not changed
+	assert.Equal(t, "debug", spec.Components[0].Env[0].Value)
+	assert.Equal(t, "staging.example.com", spec.SharedEnv[0].Value)
+
+	_, err = ResolveApplicationSpec(spec, "unknown")
+	assert.NotNil(t, err)
+}
+
+func TestIsValidOverlays(t *testing.T) {
+	spec := overlayAppSpec()
+	spec.Overlay = "production"
+	assert.Nil(t, TryValidateApplication(spec))
+
+	spec.Overlay = "staging"
+	assert.NotNil(t, TryValidateApplication(spec))
+
+	spec = overlayAppSpec()
+	spec.Overlays[0].Components[0].Name = "api"
+	assert.NotNil(t, TryValidateApplication(spec))
+
+	spec = overlayAppSpec()
+	spec.Overlays = append(spec.Overlays, spec.Overlays[0])
+	assert.NotNil(t, TryValidateApplication(spec))
+}
+
+func TestReplaceImageTag(t *testing.T) {
+	assert.Equal(t, "nginx:1.18", replaceImageTag("nginx", "1.18"))
+	assert.Equal(t, "nginx:1.18", replaceImageTag("nginx:1.17", "1.18"))
+	assert.Equal(t, "registry:5000/nginx:1.18", replaceImageTag("registry:5000/nginx", "1.18"))
+	assert.Equal(t, "registry:5000/nginx:1.18", replaceImageTag("registry:5000/nginx:1.17@sha256:abc", "1.18"))
+}
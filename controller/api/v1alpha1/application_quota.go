@@ -0,0 +1,212 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ApplicationQuotaUsage returns what the application takes from the quotas of its namespace when it's running,
+// keyed by the resource names of ResourceQuota, e.g. requests.cpu, pods, requests.storage.
+// Containers without cpu or memory get the defaults of the LimitRanges of the namespace.
+func ApplicationQuotaUsage(spec ApplicationSpec, limitRanges []v1.LimitRange) (v1.ResourceList, error) {
+	resolved, err := ResolveApplicationSpec(spec, spec.Overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := v1.ResourceList{}
+
+	// resources of inactive applications are deleted
+	if !resolved.IsActive {
+		return usage, nil
+	}
+
+	defaultRequests, defaultLimits := containerDefaults(limitRanges)
+
+	for _, component := range resolved.Components {
+		// pods of cronjobs only exist while they run
+		if component.WorkLoadType == WorkLoadTypeCronjob {
+			continue
+		}
+
+		replicas := int64(1)
+		if component.Replicas != nil {
+			replicas = int64(*component.Replicas)
+		}
+
+		requests, limits := componentContainerResources(component, defaultRequests, defaultLimits)
+
+		for name, quantity := range requests {
+			addQuota(usage, v1.ResourceName("requests."+name), quantity, replicas)
+		}
+
+		for name, quantity := range limits {
+			addQuota(usage, v1.ResourceName("limits."+name), quantity, replicas)
+		}
+
+		addQuota(usage, v1.ResourcePods, *resource.NewQuantity(1, resource.DecimalSI), replicas)
+
+		if len(component.Ports) > 0 {
+			addQuota(usage, v1.ResourceServices, *resource.NewQuantity(1, resource.DecimalSI), 1)
+		}
+
+		// claims are shared by the replicas
+		for _, volume := range component.Volumes {
+			if volume.Type != VolumeTypePersistentVolumeClaim {
+				continue
+			}
+
+			addQuota(usage, v1.ResourceRequestsStorage, volume.Size, 1)
+			addQuota(usage, v1.ResourcePersistentVolumeClaims, *resource.NewQuantity(1, resource.DecimalSI), 1)
+		}
+	}
+
+	return usage, nil
+}
+
+// QuotaExceededError is returned by CheckApplicationQuota when the application takes more than a quota has left
+type QuotaExceededError struct {
+	Message string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return e.Message
+}
+
+// IsQuotaExceeded tells if the error is a QuotaExceededError
+func IsQuotaExceeded(err error) bool {
+	_, ok := err.(*QuotaExceededError)
+	return ok
+}
+
+// CheckApplicationQuota returns a QuotaExceededError if the application, replacing the old one if it's not nil,
+// makes the usage of a quota exceed its hard limits, or other errors if the spec of the applications can't be resolved
+func CheckApplicationQuota(app *Application, old *Application, quotas []v1.ResourceQuota, limitRanges []v1.LimitRange) error {
+	if len(quotas) == 0 {
+		return nil
+	}
+
+	usage, err := ApplicationQuotaUsage(app.Spec, limitRanges)
+	if err != nil {
+		return err
+	}
+
+	oldUsage := v1.ResourceList{}
+	if old != nil {
+		if oldUsage, err = ApplicationQuotaUsage(old.Spec, limitRanges); err != nil {
+			return err
+		}
+	}
+
+	for _, quota := range quotas {
+		hard := quota.Status.Hard
+		if hard == nil {
+			hard = quota.Spec.Hard
+		}
+
+		names := make([]string, 0, len(hard))
+		for name := range hard {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			limit := hard[v1.ResourceName(name)]
+
+			// cpu and memory of quotas are requests
+			usageName := v1.ResourceName(name)
+			if usageName == v1.ResourceCPU || usageName == v1.ResourceMemory {
+				usageName = v1.ResourceName("requests." + name)
+			}
+
+			increase := usage[usageName].DeepCopy()
+			increase.Sub(oldUsage[usageName])
+			if increase.Sign() <= 0 {
+				continue
+			}
+
+			used := quota.Status.Used[v1.ResourceName(name)]
+			total := used.DeepCopy()
+			total.Add(increase)
+
+			if total.Cmp(limit) > 0 {
+				return &QuotaExceededError{Message: fmt.Sprintf("application %s would exceed quota %s of namespace %s: %s %s more is requested, %s of %s is used",
+					app.Name, quota.Name, app.Namespace, increase.String(), name, used.String(), limit.String())}
+			}
+		}
+	}
+
+	return nil
+}
+
+// containerDefaults are the default requests and limits of containers in the LimitRanges
+func containerDefaults(limitRanges []v1.LimitRange) (requests, limits v1.ResourceList) {
+	requests = v1.ResourceList{}
+	limits = v1.ResourceList{}
+
+	for _, limitRange := range limitRanges {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != v1.LimitTypeContainer {
+				continue
+			}
+
+			for name, quantity := range item.DefaultRequest {
+				requests[name] = quantity
+			}
+
+			for name, quantity := range item.Default {
+				limits[name] = quantity
+			}
+		}
+	}
+
+	return requests, limits
+}
+
+// componentContainerResources are the resources of the container of the component,
+// as set by the controller and then defaulted by the LimitRanger and the api server
+func componentContainerResources(component ComponentSpec, defaultRequests, defaultLimits v1.ResourceList) (requests, limits v1.ResourceList) {
+	requests = v1.ResourceList{}
+	limits = v1.ResourceList{}
+
+	if component.CPU != nil && !component.CPU.IsZero() {
+		requests[v1.ResourceCPU] = *component.CPU
+		limits[v1.ResourceCPU] = *component.CPU
+	}
+
+	// only the limit of memory is set by the controller
+	if component.Memory != nil && !component.Memory.IsZero() {
+		limits[v1.ResourceMemory] = *component.Memory
+	}
+
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		if _, exist := limits[name]; !exist {
+			if quantity, exist := defaultLimits[name]; exist {
+				limits[name] = quantity
+			}
+		}
+
+		if _, exist := requests[name]; !exist {
+			if quantity, exist := defaultRequests[name]; exist {
+				requests[name] = quantity
+			} else if quantity, exist := limits[name]; exist {
+				requests[name] = quantity
+			}
+		}
+	}
+
+	return requests, limits
+}
+
+func addQuota(list v1.ResourceList, name v1.ResourceName, quantity resource.Quantity, times int64) {
+	total := list[name].DeepCopy()
+
+	for i := int64(0); i < times; i++ {
+		total.Add(quantity)
+	}
+
+	list[name] = total
+}
@@ -0,0 +1,118 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func quantity(s string) *resource.Quantity {
+	q := resource.MustParse(s)
+	return &q
+}
+
+func TestApplicationQuotaUsage(t *testing.T) {
+	replicas := int32(2)
+
+	spec := ApplicationSpec{
+		IsActive: true,
+		Components: []ComponentSpec{
+			{
+				Name:     "web",
+				Replicas: &replicas,
+				CPU:      quantity("500m"),
+				Memory:   quantity("256Mi"),
+				Ports:    []Port{{Name: "http", ContainerPort: 80}},
+				Volumes:  []Volume{{Path: "/data", Size: resource.MustParse("1Gi"), Type: VolumeTypePersistentVolumeClaim}},
+			},
+			{Name: "worker"},
+			{Name: "backup", WorkLoadType: WorkLoadTypeCronjob, CPU: quantity("4")},
+		},
+	}
+
+	limitRanges := []v1.LimitRange{
+		{
+			Spec: v1.LimitRangeSpec{
+				Limits: []v1.LimitRangeItem{
+					{
+						Type:           v1.LimitTypeContainer,
+						Default:        v1.ResourceList{v1.ResourceCPU: resource.MustParse("200m"), v1.ResourceMemory: resource.MustParse("128Mi")},
+						DefaultRequest: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+					},
+				},
+			},
+		},
+	}
+
+	usage, err := ApplicationQuotaUsage(spec, limitRanges)
+	assert.Nil(t, err)
+
+	expected := map[v1.ResourceName]string{
+		v1.ResourceRequestsCPU:            "1100m",
+		v1.ResourceLimitsCPU:              "1200m",
+		v1.ResourceRequestsMemory:         "640Mi",
+		v1.ResourceLimitsMemory:           "640Mi",
+		v1.ResourcePods:                   "3",
+		v1.ResourceServices:               "1",
+		v1.ResourceRequestsStorage:        "1Gi",
+		v1.ResourcePersistentVolumeClaims: "1",
+	}
+
+	assert.Len(t, usage, len(expected))
+	for name, value := range expected {
+		q := usage[name]
+		assert.Zero(t, q.Cmp(resource.MustParse(value)), "%s: %s", name, q.String())
+	}
+
+	spec.IsActive = false
+	usage, err = ApplicationQuotaUsage(spec, limitRanges)
+	assert.Nil(t, err)
+	assert.Empty(t, usage)
+}
+
+func TestCheckApplicationQuota(t *testing.T) {
+	app := func(cpu string, replicas int32) *Application {
+		return &Application{
+			ObjectMeta: metaV1.ObjectMeta{Namespace: "kapp-a", Name: "app"},
+			Spec: ApplicationSpec{
+				IsActive:   true,
+				Components: []ComponentSpec{{Name: "web", Replicas: &replicas, CPU: quantity(cpu)}},
+			},
+		}
+	}
+
+	quotas := []v1.ResourceQuota{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "kapp-quota"},
+			Status: v1.ResourceQuotaStatus{
+				Hard: v1.ResourceList{v1.ResourceLimitsCPU: resource.MustParse("2"), v1.ResourcePods: resource.MustParse("10")},
+				Used: v1.ResourceList{v1.ResourceLimitsCPU: resource.MustParse("1"), v1.ResourcePods: resource.MustParse("2")},
+			},
+		},
+	}
+
+	assert.Nil(t, CheckApplicationQuota(app("500m", 2), nil, quotas, nil))
+	assert.Nil(t, CheckApplicationQuota(app("1", 1), nil, nil, nil))
+
+	err := CheckApplicationQuota(app("1", 2), nil, quotas, nil)
+	assert.True(t, IsQuotaExceeded(err))
+	assert.Contains(t, err.Error(), "exceed quota kapp-quota of namespace kapp-a")
+	assert.Contains(t, err.Error(), "limits.cpu")
+
+	// the usage of the old application is already in the used of the quota
+	assert.Nil(t, CheckApplicationQuota(app("1", 2), app("500m", 2), quotas, nil))
+	assert.NotNil(t, CheckApplicationQuota(app("1", 3), app("500m", 2), quotas, nil))
+
+	// scaling down is always allowed
+	assert.Nil(t, CheckApplicationQuota(app("1", 1), app("2", 2), quotas, nil))
+
+	// invalid specs are not over quota
+	invalid := app("500m", 1)
+	invalid.Spec.Overlay = "missing"
+	err = CheckApplicationQuota(invalid, nil, quotas, nil)
+	assert.NotNil(t, err)
+	assert.False(t, IsQuotaExceeded(err))
+}
@@ -95,6 +95,39 @@ type ComponentSpec struct {
 
 	// +optional
 	Volumes []Volume `json:"volumes,omitempty"`
+
+	// +optional
+	Metrics *ComponentMetrics `json:"metrics,omitempty"`
+}
+
+// ComponentOverlay overrides fields of the component with the same name
+type ComponentOverlay struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// replace the tag of the component image
+	ImageTag string `json:"imageTag,omitempty"`
+
+	// env with the same name is replaced, others are appended
+	Env []EnvVar `json:"env,omitempty"`
+
+	CPU *resource.Quantity `json:"cpu,omitempty"`
+
+	Memory *resource.Quantity `json:"memory,omitempty"`
+}
+
+// ApplicationOverlay is a named set of differences, e.g. staging or production,
+// applied on top of the application spec.
+type ApplicationOverlay struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// shared env with the same name is replaced, others are appended
+	SharedEnv []EnvVar `json:"sharedEnv,omitempty"`
+
+	Components []ComponentOverlay `json:"components,omitempty"`
 }
 
 // ApplicationSpec defines the desired state of Application
@@ -103,6 +136,16 @@ type ApplicationSpec struct {
 	Components          []ComponentSpec `json:"components"`
 	SharedEnv           []EnvVar        `json:"sharedEnv,omitempty"`
 	ImagePullSecretName string          `json:"imagePullSecretName,omitempty"`
+
+	// +optional
+	Overlays []ApplicationOverlay `json:"overlays,omitempty"`
+
+	// name of the overlay in use, empty means no overlay
+	// +optional
+	Overlay string `json:"overlay,omitempty"`
+
+	// +optional
+	Alerts []ApplicationAlert `json:"alerts,omitempty"`
 }
 
 // ApplicationStatus defines the observed state of Application
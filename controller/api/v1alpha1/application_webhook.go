@@ -16,8 +16,12 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -25,7 +29,12 @@ import (
 // log is for logging in this package.
 var applicationlog = logf.Log.WithName("application-resource")
 
+// reads the quotas of the namespace of an application when it's validated
+var applicationQuotaReader client.Reader
+
 func (r *Application) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	applicationQuotaReader = mgr.GetAPIReader()
+
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -53,14 +62,24 @@ var _ webhook.Validator = &Application{}
 func (r *Application) ValidateCreate() error {
 	applicationlog.Info("validate create", "name", r.Name)
 
-	return r.validateApplication()
+	if err := r.validateApplication(); err != nil {
+		return err
+	}
+
+	return r.validateQuota(nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *Application) ValidateUpdate(old runtime.Object) error {
 	applicationlog.Info("validate update", "name", r.Name)
 
-	return r.validateApplication()
+	if err := r.validateApplication(); err != nil {
+		return err
+	}
+
+	oldApp, _ := old.(*Application)
+
+	return r.validateQuota(oldApp)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -79,3 +98,29 @@ func (r *Application) validateApplication() error {
 
 	return nil
 }
+
+// validateQuota rejects the application if it would exceed the quotas of its namespace,
+// instead of failing to create its pods later
+func (r *Application) validateQuota(old *Application) error {
+	if applicationQuotaReader == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	var quotas v1.ResourceQuotaList
+	if err := applicationQuotaReader.List(ctx, &quotas, client.InNamespace(r.Namespace)); err != nil {
+		return err
+	}
+
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+
+	var limitRanges v1.LimitRangeList
+	if err := applicationQuotaReader.List(ctx, &limitRanges, client.InNamespace(r.Namespace)); err != nil {
+		return err
+	}
+
+	return CheckApplicationQuota(r, old, quotas.Items, limitRanges.Items)
+}
@@ -26,8 +26,26 @@ type EnvVar struct {
 	Prefix string `json:"prefix,omitempty"`
 
 	Suffix string `json:"suffix,omitempty"`
+
+	// format of linked env value, default is hostPort
+	// +kubebuilder:validation:Enum=hostPort;host;port;fqdn;fqdnPort
+	Format EnvVarFormat `json:"format,omitempty"`
+
+	// go template for linked and external env value, takes precedence over format.
+	// See EnvTemplateVariables for available variables, e.g. postgres://{{.Host}}:{{.Port}}/db
+	Template string `json:"template,omitempty"`
 }
 
+type EnvVarFormat string
+
+const (
+	EnvVarFormatHostPort     EnvVarFormat = "hostPort"
+	EnvVarFormatHost         EnvVarFormat = "host"
+	EnvVarFormatPort         EnvVarFormat = "port"
+	EnvVarFormatFQDN         EnvVarFormat = "fqdn"
+	EnvVarFormatFQDNWithPort EnvVarFormat = "fqdnPort"
+)
+
 type Port struct {
 	Name string `json:"name"`
 
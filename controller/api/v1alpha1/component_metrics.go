@@ -0,0 +1,68 @@
+package v1alpha1
+
+import (
+	"fmt"
+)
+
+// ComponentMetrics is where prometheus scrapes the metrics of a component,
+// a ServiceMonitor is created for it when the kube-prometheus dependency is running
+type ComponentMetrics struct {
+	// name of the port in ports which serves the metrics
+	// +kubebuilder:validation:Required
+	Port string `json:"port"`
+
+	// /metrics by default
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// scrape interval, e.g. 30s, the interval of prometheus by default
+	// +optional
+	Interval string `json:"interval,omitempty"`
+}
+
+const DefaultMetricsPath = "/metrics"
+
+// GetPath returns the path, or the default one if it's not set
+func (m *ComponentMetrics) GetPath() string {
+	if m.Path == "" {
+		return DefaultMetricsPath
+	}
+
+	return m.Path
+}
+
+// the port of metrics should be a named port of the component, as the port of the service is referred by name
+func isValidComponentMetrics(spec ApplicationSpec) error {
+	for _, component := range spec.Components {
+		metrics := component.Metrics
+		if metrics == nil {
+			continue
+		}
+
+		if !hasPortNamed(component, metrics.Port) {
+			return fmt.Errorf("port %s of metrics is not a port of component %s", metrics.Port, component.Name)
+		}
+
+		if metrics.Interval != "" {
+			if !isPositivePrometheusDuration(metrics.Interval) {
+				return fmt.Errorf("interval of metrics of component %s should be a positive duration of prometheus, e.g. 90s: %s", component.Name, metrics.Interval)
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasPortNamed(component ComponentSpec, name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, port := range component.Ports {
+		if port.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,30 @@
+package v1alpha1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsValidComponentMetrics(t *testing.T) {
+	spec := ApplicationSpec{
+		Components: []ComponentSpec{
+			{
+				Name:    "web",
+				Ports:   []Port{{Name: "http", ContainerPort: 8080}},
+				Metrics: &ComponentMetrics{Port: "http", Interval: "15s"},
+			},
+		},
+	}
+	assert.Nil(t, TryValidateApplication(spec))
+	assert.Equal(t, DefaultMetricsPath, spec.Components[0].Metrics.GetPath())
+
+	spec.Components[0].Metrics.Port = "metrics"
+	assert.NotNil(t, TryValidateApplication(spec))
+
+	spec.Components[0].Metrics = &ComponentMetrics{Port: "http", Interval: "15"}
+	assert.NotNil(t, TryValidateApplication(spec))
+
+	// prometheus can't parse durations of more than one unit
+	spec.Components[0].Metrics = &ComponentMetrics{Port: "http", Interval: "1m30s"}
+	assert.NotNil(t, TryValidateApplication(spec))
+}
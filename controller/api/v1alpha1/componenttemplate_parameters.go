@@ -0,0 +1,261 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var parameterRefRegexp = regexp.MustCompile(`\$\{([^}]*)\}`)
+var parameterNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// TryValidateComponentTemplate checks the parameter definitions of a template,
+// and makes sure every ${name} used in the template is defined.
+func TryValidateComponentTemplate(spec ComponentTemplateSpec) error {
+	defined := make(map[string]bool)
+
+	for _, param := range spec.Parameters {
+		if !parameterNameRegexp.MatchString(param.Name) {
+			return fmt.Errorf("invalid parameter name: %s", param.Name)
+		}
+
+		if defined[param.Name] {
+			return fmt.Errorf("duplicate parameter: %s", param.Name)
+		}
+
+		if param.Default != "" {
+			if err := validateParameterValue(param, param.Default); err != nil {
+				return fmt.Errorf("invalid default value of parameter %s, %s", param.Name, err)
+			}
+		}
+
+		defined[param.Name] = true
+	}
+
+	for _, str := range templateStrings(spec) {
+		for _, match := range parameterRefRegexp.FindAllStringSubmatch(str, -1) {
+			if !defined[match[1]] {
+				return fmt.Errorf("parameter %s is used but not defined", match[1])
+			}
+		}
+	}
+
+	return nil
+}
+
+// ResolveComponentTemplateParameters validates user supplied values against the template parameters.
+// Missing values are filled with defaults. The returned map contains a value for each parameter.
+func ResolveComponentTemplateParameters(spec ComponentTemplateSpec, values map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(spec.Parameters))
+
+	for name := range values {
+		if !hasParameter(spec, name) {
+			return nil, fmt.Errorf("unknown parameter: %s", name)
+		}
+	}
+
+	for _, param := range spec.Parameters {
+		value := values[param.Name]
+
+		if value == "" {
+			value = param.Default
+		}
+
+		if value == "" {
+			if param.Required {
+				return nil, fmt.Errorf("parameter %s is required", param.Name)
+			}
+		} else if err := validateParameterValue(param, value); err != nil {
+			return nil, fmt.Errorf("invalid value of parameter %s, %s", param.Name, err)
+		}
+
+		resolved[param.Name] = value
+	}
+
+	return resolved, nil
+}
+
+// RenderComponentTemplate substitutes the parameters of a template with the given values,
+// the result can be used as a component of an Application
+func RenderComponentTemplate(spec ComponentTemplateSpec, values map[string]string) (*ComponentSpec, error) {
+	if err := TryValidateComponentTemplate(spec); err != nil {
+		return nil, err
+	}
+
+	resolved, err := ResolveComponentTemplateParameters(spec, values)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := func(str string) string {
+		return substituteParameters(str, resolved)
+	}
+
+	component := &ComponentSpec{
+		Name:          spec.Name,
+		Image:         sub(spec.Image),
+		Command:       substituteParametersInSlice(spec.Command, resolved),
+		Args:          substituteParametersInSlice(spec.Args, resolved),
+		WorkLoadType:  spec.WorkLoadType,
+		Schedule:      sub(spec.Schedule),
+		BeforeStart:   substituteParametersInSlice(spec.BeforeStart, resolved),
+		AfterStart:    substituteParametersInSlice(spec.AfterStart, resolved),
+		BeforeDestroy: substituteParametersInSlice(spec.BeforeDestroy, resolved),
+	}
+
+	for _, env := range spec.Env {
+		env.Value = sub(env.Value)
+		env.Prefix = sub(env.Prefix)
+		env.Suffix = sub(env.Suffix)
+		component.Env = append(component.Env, env)
+	}
+
+	for _, port := range spec.Ports {
+		containerPort, err := renderPortNumber(port.ContainerPort, resolved)
+		if err != nil {
+			return nil, fmt.Errorf("invalid containerPort of port %s, %s", port.Name, err)
+		}
+
+		if containerPort == 0 {
+			return nil, fmt.Errorf("containerPort of port %s is required", port.Name)
+		}
+
+		servicePort, err := renderPortNumber(port.ServicePort, resolved)
+		if err != nil {
+			return nil, fmt.Errorf("invalid servicePort of port %s, %s", port.Name, err)
+		}
+
+		component.Ports = append(component.Ports, Port{
+			Name:          port.Name,
+			ContainerPort: containerPort,
+			ServicePort:   servicePort,
+			Protocol:      port.Protocol,
+		})
+	}
+
+	for _, volume := range spec.Volumes {
+		size, err := resource.ParseQuantity(sub(volume.Size))
+		if err != nil {
+			return nil, fmt.Errorf("invalid size of volume %s, %s", volume.Path, err)
+		}
+
+		component.Volumes = append(component.Volumes, Volume{
+			Path:             sub(volume.Path),
+			Size:             size,
+			Type:             volume.Type,
+			StorageClassName: volume.StorageClassName,
+		})
+	}
+
+	if !spec.CPU.IsZero() {
+		cpu := spec.CPU.DeepCopy()
+		component.CPU = &cpu
+	}
+
+	if !spec.Memory.IsZero() {
+		memory := spec.Memory.DeepCopy()
+		component.Memory = &memory
+	}
+
+	return component, nil
+}
+
+func validateParameterValue(param ComponentTemplateParameter, value string) error {
+	switch param.Type {
+	case "", ComponentTemplateParameterTypeString:
+		return nil
+	case ComponentTemplateParameterTypeInteger:
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err
+	case ComponentTemplateParameterTypeBoolean:
+		_, err := strconv.ParseBool(value)
+		return err
+	case ComponentTemplateParameterTypeQuantity:
+		_, err := resource.ParseQuantity(value)
+		return err
+	default:
+		return fmt.Errorf("unknown parameter type: %s", param.Type)
+	}
+}
+
+func hasParameter(spec ComponentTemplateSpec, name string) bool {
+	for _, param := range spec.Parameters {
+		if param.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func substituteParameters(str string, values map[string]string) string {
+	return parameterRefRegexp.ReplaceAllStringFunc(str, func(ref string) string {
+		return values[parameterRefRegexp.FindStringSubmatch(ref)[1]]
+	})
+}
+
+func substituteParametersInSlice(strs []string, values map[string]string) []string {
+	if strs == nil {
+		return nil
+	}
+
+	rst := make([]string, 0, len(strs))
+	for _, str := range strs {
+		rst = append(rst, substituteParameters(str, values))
+	}
+
+	return rst
+}
+
+func renderPortNumber(port intstr.IntOrString, values map[string]string) (uint32, error) {
+	if port.Type == intstr.Int {
+		if port.IntVal < 0 || port.IntVal > 65535 {
+			return 0, fmt.Errorf("port out of range: %d", port.IntVal)
+		}
+
+		return uint32(port.IntVal), nil
+	}
+
+	str := substituteParameters(port.StrVal, values)
+	if str == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseUint(str, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > 65535 {
+		return 0, fmt.Errorf("port out of range: %d", n)
+	}
+
+	return uint32(n), nil
+}
+
+// all fields where a ${name} reference is allowed
+func templateStrings(spec ComponentTemplateSpec) []string {
+	strs := []string{spec.Image, spec.Schedule}
+	strs = append(strs, spec.Command...)
+	strs = append(strs, spec.Args...)
+	strs = append(strs, spec.BeforeStart...)
+	strs = append(strs, spec.AfterStart...)
+	strs = append(strs, spec.BeforeDestroy...)
+
+	for _, env := range spec.Env {
+		strs = append(strs, env.Value, env.Prefix, env.Suffix)
+	}
+
+	for _, port := range spec.Ports {
+		strs = append(strs, port.ContainerPort.StrVal, port.ServicePort.StrVal)
+	}
+
+	for _, volume := range spec.Volumes {
+		strs = append(strs, volume.Path, volume.Size)
+	}
+
+	return strs
+}
@@ -0,0 +1,77 @@
+package v1alpha1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"testing"
+)
+
+func postgresTemplate() ComponentTemplateSpec {
+	return ComponentTemplateSpec{
+		Name:  "postgres",
+		Image: "postgres:${version}",
+		Parameters: []ComponentTemplateParameter{
+			{Name: "version", Type: ComponentTemplateParameterTypeString, Default: "12"},
+			{Name: "password", Type: ComponentTemplateParameterTypeString, Required: true},
+			{Name: "port", Type: ComponentTemplateParameterTypeInteger, Default: "5432"},
+			{Name: "size", Type: ComponentTemplateParameterTypeQuantity, Default: "1Gi"},
+		},
+		Env: []EnvVar{
+			{Name: "POSTGRES_PASSWORD", Value: "${password}"},
+		},
+		Ports: []TemplatePort{
+			{Name: "pg", ContainerPort: intstr.FromString("${port}")},
+		},
+		Volumes: []TemplateVolume{
+			{Path: "/var/lib/postgresql/data", Size: "${size}", Type: VolumeTypePersistentVolumeClaim},
+		},
+	}
+}
+
+func TestValidateComponentTemplate(t *testing.T) {
+	assert.Nil(t, TryValidateComponentTemplate(postgresTemplate()))
+
+	spec := postgresTemplate()
+	spec.Image = "postgres:${tag}"
+	assert.NotNil(t, TryValidateComponentTemplate(spec))
+
+	spec = postgresTemplate()
+	spec.Parameters = append(spec.Parameters, ComponentTemplateParameter{Name: "port"})
+	assert.NotNil(t, TryValidateComponentTemplate(spec))
+
+	spec = postgresTemplate()
+	spec.Parameters[2].Default = "not-a-number"
+	assert.NotNil(t, TryValidateComponentTemplate(spec))
+}
+
+func TestResolveComponentTemplateParameters(t *testing.T) {
+	spec := postgresTemplate()
+
+	_, err := ResolveComponentTemplateParameters(spec, map[string]string{})
+	assert.NotNil(t, err, "password is required")
+
+	_, err = ResolveComponentTemplateParameters(spec, map[string]string{"password": "pwd", "unknown": "x"})
+	assert.NotNil(t, err)
+
+	_, err = ResolveComponentTemplateParameters(spec, map[string]string{"password": "pwd", "port": "abc"})
+	assert.NotNil(t, err)
+
+	values, err := ResolveComponentTemplateParameters(spec, map[string]string{"password": "pwd"})
+	assert.Nil(t, err)
+	assert.Equal(t, "12", values["version"])
+	assert.Equal(t, "5432", values["port"])
+}
+
+func TestRenderComponentTemplate(t *testing.T) {
+	component, err := RenderComponentTemplate(postgresTemplate(), map[string]string{
+		"password": "pwd",
+		"version":  "11.7",
+		"size":     "2Gi",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "postgres:11.7", component.Image)
+	assert.Equal(t, "pwd", component.Env[0].Value)
+	assert.Equal(t, uint32(5432), component.Ports[0].ContainerPort)
+	assert.Equal(t, "2Gi", component.Volumes[0].Size.String())
+}
@@ -19,6 +19,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -31,10 +32,69 @@ const (
 	WorkLoadTypeCronjob WorkLoadType = "cronjob"
 )
 
+type ComponentTemplateParameterType string
+
+const (
+	ComponentTemplateParameterTypeString   ComponentTemplateParameterType = "string"
+	ComponentTemplateParameterTypeInteger  ComponentTemplateParameterType = "integer"
+	ComponentTemplateParameterTypeBoolean  ComponentTemplateParameterType = "boolean"
+	ComponentTemplateParameterTypeQuantity ComponentTemplateParameterType = "quantity"
+)
+
+// ComponentTemplateParameter declares an input of a template.
+// It can be referenced as ${name} in image, env values, command, args, ports and volume sizes.
+type ComponentTemplateParameter struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Enum=string;integer;boolean;quantity
+	Type ComponentTemplateParameterType `json:"type,omitempty"`
+
+	Default string `json:"default,omitempty"`
+
+	Required bool `json:"required,omitempty"`
+
+	Description string `json:"description,omitempty"`
+}
+
+// TemplatePort is the same as Port, but the port numbers can be a ${name} parameter reference
+type TemplatePort struct {
+	Name string `json:"name"`
+
+	ContainerPort intstr.IntOrString `json:"containerPort"`
+
+	// port for service
+	ServicePort intstr.IntOrString `json:"servicePort,omitempty"`
+
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	Protocol v1.Protocol `json:"protocol,omitempty"`
+}
+
+// TemplateVolume is the same as Volume, but the size can be a ${name} parameter reference
+type TemplateVolume struct {
+	Path string `json:"path"`
+
+	Size string `json:"size"`
+
+	Type VolumeType `json:"type,omitempty"`
+
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
 // ComponentTemplateSpec defines the desired state of ComponentTemplate
 type ComponentTemplateSpec struct {
 	Name string `json:"name"`
 
+	// version of the template itself, not the image
+	Version string `json:"version,omitempty"`
+
+	// deprecated templates are still usable, but should not be chosen for new components
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	Description string `json:"description,omitempty"`
+
+	Parameters []ComponentTemplateParameter `json:"parameters,omitempty"`
+
 	Env []EnvVar `json:"env,omitempty"`
 
 	Image string `json:"image"`
@@ -43,7 +103,7 @@ type ComponentTemplateSpec struct {
 
 	Args []string `json:"args,omitempty"`
 
-	Ports []Port `json:"ports,omitempty"`
+	Ports []TemplatePort `json:"ports,omitempty"`
 
 	// +optional
 	// LivenessProbe *v1.Probe `json:"livenessProbe,omitempty"`
@@ -67,12 +127,15 @@ type ComponentTemplateSpec struct {
 	Memory resource.Quantity `json:"memory,omitempty"`
 
 	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	Volumes []TemplateVolume `json:"volumes,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:scope=Cluster
 
 // ComponentTemplate is the Schema for the componenttemplates API
+// It is shared by all namespaces.
 type ComponentTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -90,6 +153,27 @@ type ComponentTemplateList struct {
 	Items           []ComponentTemplate `json:"items"`
 }
 
+// +kubebuilder:object:root=true
+
+// NamespacedComponentTemplate is the Schema for the namespacedcomponenttemplates API
+// It is only visible in its own namespace.
+type NamespacedComponentTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ComponentTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespacedComponentTemplateList contains a list of NamespacedComponentTemplate
+type NamespacedComponentTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacedComponentTemplate `json:"items"`
+}
+
 func init() {
 	SchemeBuilder.Register(&ComponentTemplate{}, &ComponentTemplateList{})
+	SchemeBuilder.Register(&NamespacedComponentTemplate{}, &NamespacedComponentTemplateList{})
 }
@@ -0,0 +1,211 @@
+package v1alpha1
+
+import (
+	"fmt"
+)
+
+// ACME servers of let's encrypt
+const (
+	ACMEServerProduction = "https://acme-v02.api.letsencrypt.org/directory"
+	ACMEServerStaging    = "https://acme-staging-v02.api.letsencrypt.org/directory"
+)
+
+const (
+	ACMEChallengeHTTP01 = "http01"
+	ACMEChallengeDNS01  = "dns01"
+)
+
+// dns01 providers
+const (
+	ACMEDNS01ProviderCloudflare   = "cloudflare"
+	ACMEDNS01ProviderRoute53      = "route53"
+	ACMEDNS01ProviderCloudDNS     = "clouddns"
+	ACMEDNS01ProviderDigitalOcean = "digitalocean"
+	ACMEDNS01ProviderRFC2136      = "rfc2136"
+)
+
+// keys of the credentials in the Secret of a cert-manager Dependency
+const (
+	CloudflareAPIKeySecretKey       = "sec-content"
+	Route53SecretAccessKeySecretKey = "secret-access-key"
+	CloudDNSServiceAccountSecretKey = "service-account.json"
+	DigitalOceanTokenSecretKey      = "token"
+	RFC2136TSIGSecretSecretKey      = "tsig-secret"
+)
+
+// CertManagerACMEConfig is the typed form of the config of a cert-manager Dependency with tlsType acme
+// +kubebuilder:object:generate=false
+type CertManagerACMEConfig struct {
+	Email string
+	// url of the acme server
+	Server string
+	// http01 or dns01
+	Challenge string
+
+	// ingress class which serves the http01 challenge,
+	// the class of the ingress dependency of the cluster if it's empty
+	IngressClass string
+
+	// dns01 provider, only one of them is set
+	Cloudflare   *CloudflareDNS01Config
+	Route53      *Route53DNS01Config
+	CloudDNS     *CloudDNSDNS01Config
+	DigitalOcean *DigitalOceanDNS01Config
+	RFC2136      *RFC2136DNS01Config
+}
+
+// +kubebuilder:object:generate=false
+type CloudflareDNS01Config struct {
+	Email  string
+	APIKey string
+}
+
+// +kubebuilder:object:generate=false
+type Route53DNS01Config struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	HostedZoneID    string
+	Role            string
+}
+
+// +kubebuilder:object:generate=false
+type CloudDNSDNS01Config struct {
+	Project string
+	// json key of a google service account
+	ServiceAccount string
+}
+
+// +kubebuilder:object:generate=false
+type DigitalOceanDNS01Config struct {
+	Token string
+}
+
+// +kubebuilder:object:generate=false
+type RFC2136DNS01Config struct {
+	Nameserver    string
+	TSIGKeyName   string
+	TSIGAlgorithm string
+	TSIGSecret    string
+}
+
+// ParseCertManagerACMEConfig reads and checks the acme config of a cert-manager Dependency
+func ParseCertManagerACMEConfig(config map[string]string) (*CertManagerACMEConfig, error) {
+	required := func(keys ...string) error {
+		for _, key := range keys {
+			if config[key] == "" {
+				return fmt.Errorf("%s is required", key)
+			}
+		}
+
+		return nil
+	}
+
+	if err := required("challengeEmail"); err != nil {
+		return nil, err
+	}
+
+	acme := &CertManagerACMEConfig{
+		Email: config["challengeEmail"],
+	}
+
+	switch config["acmeServer"] {
+	case "", "production":
+		acme.Server = ACMEServerProduction
+	case "staging":
+		acme.Server = ACMEServerStaging
+	default:
+		return nil, fmt.Errorf("unknown acmeServer: %s, should be production or staging", config["acmeServer"])
+	}
+
+	switch config["challengeType"] {
+	case ACMEChallengeHTTP01:
+		acme.Challenge = ACMEChallengeHTTP01
+		acme.IngressClass = config["challengeIngressClass"]
+
+		return acme, nil
+	case "", ACMEChallengeDNS01:
+		acme.Challenge = ACMEChallengeDNS01
+	default:
+		return nil, fmt.Errorf("unknown challengeType: %s, should be http01 or dns01", config["challengeType"])
+	}
+
+	switch config["challengeProvider"] {
+	case ACMEDNS01ProviderCloudflare:
+		if err := required("challengeSecret"); err != nil {
+			return nil, err
+		}
+
+		acme.Cloudflare = &CloudflareDNS01Config{
+			Email:  acme.Email,
+			APIKey: config["challengeSecret"],
+		}
+	case ACMEDNS01ProviderRoute53:
+		if err := required("route53Region", "route53AccessKeyID", "route53SecretAccessKey"); err != nil {
+			return nil, err
+		}
+
+		acme.Route53 = &Route53DNS01Config{
+			Region:          config["route53Region"],
+			AccessKeyID:     config["route53AccessKeyID"],
+			SecretAccessKey: config["route53SecretAccessKey"],
+			HostedZoneID:    config["route53HostedZoneID"],
+			Role:            config["route53Role"],
+		}
+	case ACMEDNS01ProviderCloudDNS:
+		if err := required("clouddnsProject", "clouddnsServiceAccount"); err != nil {
+			return nil, err
+		}
+
+		acme.CloudDNS = &CloudDNSDNS01Config{
+			Project:        config["clouddnsProject"],
+			ServiceAccount: config["clouddnsServiceAccount"],
+		}
+	case ACMEDNS01ProviderDigitalOcean:
+		if err := required("digitaloceanToken"); err != nil {
+			return nil, err
+		}
+
+		acme.DigitalOcean = &DigitalOceanDNS01Config{
+			Token: config["digitaloceanToken"],
+		}
+	case ACMEDNS01ProviderRFC2136:
+		if err := required("rfc2136Nameserver"); err != nil {
+			return nil, err
+		}
+
+		acme.RFC2136 = &RFC2136DNS01Config{
+			Nameserver:    config["rfc2136Nameserver"],
+			TSIGKeyName:   config["rfc2136TSIGKeyName"],
+			TSIGAlgorithm: config["rfc2136TSIGAlgorithm"],
+			TSIGSecret:    config["rfc2136TSIGSecret"],
+		}
+
+		if (acme.RFC2136.TSIGKeyName == "") != (acme.RFC2136.TSIGSecret == "") {
+			return nil, fmt.Errorf("rfc2136TSIGKeyName and rfc2136TSIGSecret should be set together")
+		}
+	default:
+		return nil, fmt.Errorf("unknown challengeProvider: %s", config["challengeProvider"])
+	}
+
+	return acme, nil
+}
+
+// Credentials returns the secret values of the dns01 provider,
+// they are kept in a Secret, keyed by the *SecretKey consts
+func (c *CertManagerACMEConfig) Credentials() map[string]string {
+	switch {
+	case c.Cloudflare != nil:
+		return map[string]string{CloudflareAPIKeySecretKey: c.Cloudflare.APIKey}
+	case c.Route53 != nil:
+		return map[string]string{Route53SecretAccessKeySecretKey: c.Route53.SecretAccessKey}
+	case c.CloudDNS != nil:
+		return map[string]string{CloudDNSServiceAccountSecretKey: c.CloudDNS.ServiceAccount}
+	case c.DigitalOcean != nil:
+		return map[string]string{DigitalOceanTokenSecretKey: c.DigitalOcean.Token}
+	case c.RFC2136 != nil && c.RFC2136.TSIGSecret != "":
+		return map[string]string{RFC2136TSIGSecretSecretKey: c.RFC2136.TSIGSecret}
+	}
+
+	return nil
+}
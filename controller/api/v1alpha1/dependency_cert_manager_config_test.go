@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseCertManagerACMEConfig(t *testing.T) {
+	_, err := ParseCertManagerACMEConfig(map[string]string{"challengeProvider": "cloudflare", "challengeSecret": "key"})
+	assert.NotNil(t, err, "email is required")
+
+	config, err := ParseCertManagerACMEConfig(map[string]string{
+		"challengeEmail":    "a@b.c",
+		"challengeProvider": "cloudflare",
+		"challengeSecret":   "key",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, ACMEServerProduction, config.Server)
+	assert.Equal(t, ACMEChallengeDNS01, config.Challenge)
+	assert.Equal(t, map[string]string{CloudflareAPIKeySecretKey: "key"}, config.Credentials())
+
+	config, err = ParseCertManagerACMEConfig(map[string]string{
+		"challengeEmail": "a@b.c",
+		"challengeType":  "http01",
+		"acmeServer":     "staging",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, ACMEServerStaging, config.Server)
+	assert.Equal(t, "", config.IngressClass)
+	assert.Nil(t, config.Credentials())
+
+	_, err = ParseCertManagerACMEConfig(map[string]string{
+		"challengeEmail":     "a@b.c",
+		"challengeProvider":  "route53",
+		"route53Region":      "us-east-1",
+		"route53AccessKeyID": "id",
+	})
+	assert.NotNil(t, err, "secret access key is required")
+
+	_, err = ParseCertManagerACMEConfig(map[string]string{
+		"challengeEmail":     "a@b.c",
+		"challengeProvider":  "rfc2136",
+		"rfc2136Nameserver":  "1.2.3.4:53",
+		"rfc2136TSIGKeyName": "key",
+	})
+	assert.NotNil(t, err, "tsig secret is required with key name")
+
+	config, err = ParseCertManagerACMEConfig(map[string]string{
+		"challengeEmail":    "a@b.c",
+		"challengeProvider": "digitalocean",
+		"digitaloceanToken": "token",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "token", config.DigitalOcean.Token)
+
+	_, err = ParseCertManagerACMEConfig(map[string]string{"challengeEmail": "a@b.c", "challengeProvider": "unknown"})
+	assert.NotNil(t, err)
+
+	_, err = ParseCertManagerACMEConfig(map[string]string{"challengeEmail": "a@b.c", "acmeServer": "dev"})
+	assert.NotNil(t, err)
+}
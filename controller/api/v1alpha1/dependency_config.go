@@ -0,0 +1,450 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// IngressConfig is the typed config of an ingress Dependency, kong or ingress-nginx
+// +kubebuilder:object:generate=false
+type IngressConfig struct {
+	// name of the cert-manager Dependency which issues certificates of the ingresses
+	CertManager string
+}
+
+// CertManagerConfig is the typed config of a cert-manager Dependency
+// +kubebuilder:object:generate=false
+type CertManagerConfig struct {
+	// acme, selfSigned or ca
+	TLSType      string
+	CACommonName string
+	// only set for tlsType acme
+	ACME *CertManagerACMEConfig
+}
+
+// KubePrometheusConfig is the typed config of a kube-prometheus Dependency
+// +kubebuilder:object:generate=false
+type KubePrometheusConfig struct {
+	PersistentVolumeSize resource.Quantity
+	GrafanaHost          string
+	PrometheusHost       string
+	CertManager          string
+
+	// storage class of the volumes of prometheus and alertmanager, the default storage class of the cluster if empty
+	StorageClass string
+
+	// e.g. 30d, in the duration format of prometheus
+	Retention           string
+	PrometheusReplicas  int32
+	PrometheusResources corev1.ResourceList
+
+	AlertmanagerReplicas int32
+	// alertmanager uses emptyDir volumes if it's zero
+	AlertmanagerStorage   resource.Quantity
+	AlertmanagerRetention string
+	AlertmanagerResources corev1.ResourceList
+}
+
+// LogConfig is the typed config of a log Dependency
+// +kubebuilder:object:generate=false
+type LogConfig struct {
+	Storage     resource.Quantity
+	KibanaHost  string
+	CertManager string
+
+	// storage class of the elasticsearch volumes, the default storage class of the cluster if empty
+	StorageClass string
+
+	// version of elasticsearch and kibana
+	Version string
+
+	ElasticsearchReplicas int32
+	// the jvm heap is half of the memory limit
+	ElasticsearchResources corev1.ResourceRequirements
+
+	KibanaReplicas  int32
+	KibanaResources corev1.ResourceList
+}
+
+// LokiConfig is the typed config of a loki Dependency
+// +kubebuilder:object:generate=false
+type LokiConfig struct {
+	Storage resource.Quantity
+	// storage class of the volume, the default storage class of the cluster if empty
+	StorageClass string
+}
+
+// elasticsearch doesn't start with a smaller volume
+var minLogStorage = resource.MustParse("512Mi")
+
+// elasticsearch doesn't start with a smaller heap
+var minElasticsearchMemoryLimit = resource.MustParse("512Mi")
+
+// durations of prometheus, e.g. 15d or 12h
+var prometheusDurationRegex = regexp.MustCompile(`^[0-9]+(ms|s|m|h|d|w|y)$`)
+
+// isPositivePrometheusDuration tells if prometheus can parse the duration, which is not zero.
+// Prometheus only takes an integer with one unit, e.g. 90m but not 1h30m or 1.5h.
+func isPositivePrometheusDuration(d string) bool {
+	return prometheusDurationRegex.MatchString(d) && strings.Trim(strings.TrimRight(d, "smhdwy"), "0") != ""
+}
+
+var elasticStackVersionRegex = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+
+func ParseIngressConfig(config map[string]string) (*IngressConfig, error) {
+	return &IngressConfig{CertManager: config["cert-manager"]}, nil
+}
+
+func ParseCertManagerConfig(config map[string]string) (*CertManagerConfig, error) {
+	cm := &CertManagerConfig{
+		TLSType:      config["tlsType"],
+		CACommonName: config["caCommonName"],
+	}
+
+	switch cm.TLSType {
+	case "selfSigned", "ca":
+	case "acme":
+		acme, err := ParseCertManagerACMEConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		cm.ACME = acme
+	default:
+		return nil, fmt.Errorf("unknown tlsType: %s", cm.TLSType)
+	}
+
+	return cm, nil
+}
+
+func ParseKubePrometheusConfig(config map[string]string) (*KubePrometheusConfig, error) {
+	config = withConfigDefaults("kube-prometheus", config)
+
+	size, err := resource.ParseQuantity(config["persistenVolumeSize"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid persistenVolumeSize: %s", err)
+	}
+
+	if !prometheusDurationRegex.MatchString(config["retention"]) {
+		return nil, fmt.Errorf("retention should be a duration of prometheus, e.g. 30d: %s", config["retention"])
+	}
+
+	if _, err := time.ParseDuration(config["alertmanagerRetention"]); err != nil {
+		return nil, fmt.Errorf("invalid alertmanagerRetention: %s", err)
+	}
+
+	prometheusReplicas, err := parseReplicas(config, "prometheusReplicas")
+	if err != nil {
+		return nil, err
+	}
+
+	prometheusResources, err := parseResourceList(config, "prometheusCPURequest", "prometheusMemoryRequest")
+	if err != nil {
+		return nil, err
+	}
+
+	alertmanagerReplicas, err := parseReplicas(config, "alertmanagerReplicas")
+	if err != nil {
+		return nil, err
+	}
+
+	var alertmanagerStorage resource.Quantity
+	if v := config["alertmanagerStorage"]; v != "" {
+		if alertmanagerStorage, err = resource.ParseQuantity(v); err != nil {
+			return nil, fmt.Errorf("invalid alertmanagerStorage: %s", err)
+		}
+	}
+
+	alertmanagerResources, err := parseResourceList(config, "alertmanagerCPURequest", "alertmanagerMemoryRequest")
+	if err != nil {
+		return nil, err
+	}
+
+	return &KubePrometheusConfig{
+		PersistentVolumeSize:  size,
+		GrafanaHost:           config["grafanaHost"],
+		PrometheusHost:        config["prometheusHost"],
+		CertManager:           config["cert-manager"],
+		StorageClass:          config["storageClass"],
+		Retention:             config["retention"],
+		PrometheusReplicas:    prometheusReplicas,
+		PrometheusResources:   prometheusResources,
+		AlertmanagerReplicas:  alertmanagerReplicas,
+		AlertmanagerStorage:   alertmanagerStorage,
+		AlertmanagerRetention: config["alertmanagerRetention"],
+		AlertmanagerResources: alertmanagerResources,
+	}, nil
+}
+
+func ParseLogConfig(config map[string]string) (*LogConfig, error) {
+	config = withConfigDefaults("log", config)
+
+	storage, err := resource.ParseQuantity(config["storage"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage: %s", err)
+	}
+
+	if storage.Cmp(minLogStorage) < 0 {
+		return nil, fmt.Errorf("storage should be at least %s", minLogStorage.String())
+	}
+
+	if !elasticStackVersionRegex.MatchString(config["version"]) {
+		return nil, fmt.Errorf("version should be a version of elasticsearch, e.g. 7.6.1: %s", config["version"])
+	}
+
+	esReplicas, err := parseReplicas(config, "elasticsearchReplicas")
+	if err != nil {
+		return nil, err
+	}
+
+	esRequests, err := parseResourceList(config, "elasticsearchCPURequest", "elasticsearchMemoryRequest")
+	if err != nil {
+		return nil, err
+	}
+
+	esLimits, err := parseResourceList(config, "elasticsearchCPULimit", "elasticsearchMemoryLimit")
+	if err != nil {
+		return nil, err
+	}
+
+	if memory := esLimits[corev1.ResourceMemory]; memory.Cmp(minElasticsearchMemoryLimit) < 0 {
+		return nil, fmt.Errorf("elasticsearchMemoryLimit should be at least %s", minElasticsearchMemoryLimit.String())
+	}
+
+	for name, request := range esRequests {
+		if limit, exist := esLimits[name]; exist && request.Cmp(limit) > 0 {
+			return nil, fmt.Errorf("%s request of elasticsearch should not be more than its limit", name)
+		}
+	}
+
+	kibanaReplicas, err := parseReplicas(config, "kibanaReplicas")
+	if err != nil {
+		return nil, err
+	}
+
+	kibanaResources, err := parseResourceList(config, "kibanaCPURequest", "kibanaMemoryRequest")
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogConfig{
+		Storage:               storage,
+		KibanaHost:            config["kibanaHost"],
+		CertManager:           config["cert-manager"],
+		StorageClass:          config["storageClass"],
+		Version:               config["version"],
+		ElasticsearchReplicas: esReplicas,
+		ElasticsearchResources: corev1.ResourceRequirements{
+			Requests: esRequests,
+			Limits:   esLimits,
+		},
+		KibanaReplicas:  kibanaReplicas,
+		KibanaResources: kibanaResources,
+	}, nil
+}
+
+// ElasticsearchJavaOpts sets the jvm heap to half of the memory limit, as recommended by elasticsearch
+func (c *LogConfig) ElasticsearchJavaOpts() string {
+	memory := c.ElasticsearchResources.Limits[corev1.ResourceMemory]
+	heap := memory.Value() / 2 / 1024 / 1024
+
+	return fmt.Sprintf("-Xms%dm -Xmx%dm", heap, heap)
+}
+
+func ParseLokiConfig(config map[string]string) (*LokiConfig, error) {
+	config = withConfigDefaults("loki", config)
+
+	storage, err := resource.ParseQuantity(config["storage"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage: %s", err)
+	}
+
+	return &LokiConfig{
+		Storage:      storage,
+		StorageClass: config["storageClass"],
+	}, nil
+}
+
+func parseReplicas(config map[string]string, key string) (int32, error) {
+	replicas, err := strconv.ParseInt(config[key], 10, 32)
+	if err != nil || replicas < 1 {
+		return 0, fmt.Errorf("%s should be a positive integer: %s", key, config[key])
+	}
+
+	return int32(replicas), nil
+}
+
+// parseResourceList of the cpu and memory keys, keys not set are left out, nil if none is set
+func parseResourceList(config map[string]string, cpuKey, memoryKey string) (corev1.ResourceList, error) {
+	rst := corev1.ResourceList{}
+
+	for name, key := range map[corev1.ResourceName]string{corev1.ResourceCPU: cpuKey, corev1.ResourceMemory: memoryKey} {
+		if config[key] == "" {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(config[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", key, err)
+		}
+
+		rst[name] = quantity
+	}
+
+	if len(rst) == 0 {
+		return nil, nil
+	}
+
+	return rst, nil
+}
+
+// withConfigDefaults returns a copy of config, with the defaults in the schema of the type for missing keys
+func withConfigDefaults(depType string, config map[string]string) map[string]string {
+	rst := make(map[string]string, len(config))
+	for k, v := range config {
+		rst[k] = v
+	}
+
+	info, _ := GetDependencyTypeInfo(depType)
+	for _, field := range info.ConfigSchema {
+		if rst[field.Name] == "" && field.Default != "" {
+			rst[field.Name] = field.Default
+		}
+	}
+
+	return rst
+}
+
+// values of secretRefs are not read at admission,
+// the keys are given this value when the config is validated as a whole
+const secretRefPlaceholder = "secret-ref"
+
+// ValidateDependencySpec checks the type, the version and the config of a Dependency against the registered schema
+func ValidateDependencySpec(spec DependencySpec) error {
+	return validateDependencySpec(spec, nil)
+}
+
+// ValidateDependencySpecUpdate is ValidateDependencySpec for an update of old.
+// Sensitive values which are unchanged in Config are accepted, dependencies created before they had to be
+// referenced from Secrets can still be updated, until the controller moves the values into a Secret.
+func ValidateDependencySpecUpdate(spec, old DependencySpec) error {
+	return validateDependencySpec(spec, &old)
+}
+
+func validateDependencySpec(spec DependencySpec, old *DependencySpec) error {
+	info, exist := GetDependencyTypeInfo(spec.Type)
+	if !exist {
+		return fmt.Errorf("unknown dependency type: %s", spec.Type)
+	}
+
+	if spec.Version != "" && info.VersionIndex(spec.Version) < 0 {
+		return fmt.Errorf("version %s of %s is not available, available versions: %s",
+			spec.Version, spec.Type, strings.Join(info.Versions, ", "))
+	}
+
+	for _, key := range sortedKeys(spec.Config) {
+		field, exist := info.ConfigField(key)
+		if !exist {
+			return fmt.Errorf("unknown config of %s: %s", spec.Type, key)
+		}
+
+		if field.Secret && (old == nil || old.Config[key] != spec.Config[key]) {
+			return fmt.Errorf("config %s is sensitive, it should be referenced from a Secret in secretRefs", key)
+		}
+
+		if err := field.validateValue(spec.Config[key]); err != nil {
+			return err
+		}
+	}
+
+	secretKeys := make([]string, 0, len(spec.SecretRefs))
+	for key := range spec.SecretRefs {
+		secretKeys = append(secretKeys, key)
+	}
+	sort.Strings(secretKeys)
+
+	for _, key := range secretKeys {
+		field, exist := info.ConfigField(key)
+		if !exist {
+			return fmt.Errorf("unknown config of %s in secretRefs: %s", spec.Type, key)
+		}
+
+		if !field.Secret {
+			return fmt.Errorf("config %s is not sensitive, it should be set in config", key)
+		}
+
+		ref := spec.SecretRefs[key]
+		if ref.Namespace == "" || ref.Name == "" || ref.Key == "" {
+			return fmt.Errorf("namespace, name and key of secretRefs %s are required", key)
+		}
+	}
+
+	for _, field := range info.ConfigSchema {
+		_, inSecret := spec.SecretRefs[field.Name]
+		if field.Required && spec.Config[field.Name] == "" && !inSecret {
+			return fmt.Errorf("config %s is required", field.Name)
+		}
+	}
+
+	if info.Validate == nil {
+		return nil
+	}
+
+	config := make(map[string]string, len(spec.Config)+len(spec.SecretRefs))
+	for k, v := range spec.Config {
+		config[k] = v
+	}
+
+	for k := range spec.SecretRefs {
+		config[k] = secretRefPlaceholder
+	}
+
+	return info.Validate(config)
+}
+
+func (field DependencyConfigField) validateValue(value string) error {
+	var err error
+
+	switch field.Type {
+	case "boolean":
+		_, err = strconv.ParseBool(value)
+	case "integer":
+		_, err = strconv.Atoi(value)
+	case "quantity":
+		_, err = resource.ParseQuantity(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("config %s should be a %s: %s", field.Name, field.Type, value)
+	}
+
+	if len(field.Enum) == 0 {
+		return nil
+	}
+
+	for _, v := range field.Enum {
+		if v == value {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("config %s should be one of %s: %s", field.Name, strings.Join(field.Enum, ", "), value)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
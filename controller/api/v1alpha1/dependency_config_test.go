@@ -0,0 +1,162 @@
+package v1alpha1
+
+import (
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func TestValidateDependencySpec(t *testing.T) {
+	valid := func(spec DependencySpec) {
+		assert.Nil(t, ValidateDependencySpec(spec), spec)
+	}
+
+	invalid := func(spec DependencySpec) {
+		assert.NotNil(t, ValidateDependencySpec(spec), spec)
+	}
+
+	invalid(DependencySpec{Type: "unknown"})
+	invalid(DependencySpec{Type: "kong", Version: "0.0.1"})
+	invalid(DependencySpec{Type: "kong", Config: map[string]string{"unknown": "1"}})
+	valid(DependencySpec{Type: "kong", Version: "1.0.0", Config: map[string]string{"cert-manager": "cm"}})
+
+	// required and enum
+	invalid(DependencySpec{Type: "cert-manager"})
+	invalid(DependencySpec{Type: "cert-manager", Config: map[string]string{"tlsType": "unknown"}})
+	valid(DependencySpec{Type: "cert-manager", Config: map[string]string{"tlsType": "ca"}})
+
+	// quantity
+	invalid(DependencySpec{Type: "log", Config: map[string]string{"storage": "1xx"}})
+	invalid(DependencySpec{Type: "log", Config: map[string]string{"storage": "128Mi"}})
+	valid(DependencySpec{Type: "log", Config: map[string]string{"storage": "1Gi"}})
+	valid(DependencySpec{Type: "log"})
+
+	// sensitive config is referenced from secrets
+	acme := map[string]string{
+		"tlsType":           "acme",
+		"challengeEmail":    "a@b.c",
+		"challengeProvider": "cloudflare",
+	}
+	ref := DependencySecretKeyRef{Namespace: "default", Name: "cloudflare", Key: "api-key"}
+
+	invalid(DependencySpec{Type: "cert-manager", Config: acme})
+	invalid(DependencySpec{Type: "cert-manager", Config: map[string]string{
+		"tlsType":           "acme",
+		"challengeEmail":    "a@b.c",
+		"challengeProvider": "cloudflare",
+		"challengeSecret":   "api-key",
+	}})
+	invalid(DependencySpec{Type: "cert-manager", Config: acme, SecretRefs: map[string]DependencySecretKeyRef{
+		"challengeSecret": {Name: "cloudflare"},
+	}})
+	invalid(DependencySpec{Type: "cert-manager", Config: map[string]string{"tlsType": "acme"}, SecretRefs: map[string]DependencySecretKeyRef{
+		"challengeEmail": ref,
+	}})
+	valid(DependencySpec{Type: "cert-manager", Config: acme, SecretRefs: map[string]DependencySecretKeyRef{
+		"challengeSecret": ref,
+	}})
+}
+
+func TestValidateDependencySpecUpdate(t *testing.T) {
+	old := DependencySpec{Type: "cert-manager", Config: map[string]string{
+		"tlsType":           "acme",
+		"challengeEmail":    "a@b.c",
+		"challengeProvider": "cloudflare",
+		"challengeSecret":   "api-key",
+	}}
+
+	// a sensitive value which is already there is kept
+	updated := *old.DeepCopy()
+	updated.Config["challengeEmail"] = "d@e.f"
+	assert.Nil(t, ValidateDependencySpecUpdate(updated, old))
+
+	// but it can't be changed
+	updated.Config["challengeSecret"] = "another-key"
+	assert.NotNil(t, ValidateDependencySpecUpdate(updated, old))
+
+	// or added
+	added := *old.DeepCopy()
+	added.Config["route53SecretAccessKey"] = "key"
+	assert.NotNil(t, ValidateDependencySpecUpdate(added, old))
+
+	// moved into a Secret
+	moved := *old.DeepCopy()
+	delete(moved.Config, "challengeSecret")
+	moved.SecretRefs = map[string]DependencySecretKeyRef{
+		"challengeSecret": {Namespace: "kapp-system", Name: "dependency-cm-config", Key: "challengeSecret"},
+	}
+	assert.Nil(t, ValidateDependencySpecUpdate(moved, old))
+}
+
+func TestParseLogConfig(t *testing.T) {
+	config, err := ParseLogConfig(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "512Mi", config.Storage.String())
+
+	config, err = ParseLogConfig(map[string]string{"storage": "2Gi", "kibanaHost": "kibana.local"})
+	assert.Nil(t, err)
+	assert.Equal(t, "2Gi", config.Storage.String())
+	assert.Equal(t, "kibana.local", config.KibanaHost)
+}
+
+func TestParseLogConfigResources(t *testing.T) {
+	config, err := ParseLogConfig(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "7.6.1", config.Version)
+	assert.Equal(t, int32(1), config.ElasticsearchReplicas)
+	assert.Equal(t, "-Xms512m -Xmx512m", config.ElasticsearchJavaOpts())
+	assert.Len(t, config.KibanaResources, 0)
+
+	config, err = ParseLogConfig(map[string]string{
+		"storageClass":             "ssd",
+		"elasticsearchReplicas":    "3",
+		"elasticsearchMemoryLimit": "4Gi",
+		"kibanaMemoryRequest":      "1Gi",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "ssd", config.StorageClass)
+	assert.Equal(t, int32(3), config.ElasticsearchReplicas)
+	assert.Equal(t, "-Xms2048m -Xmx2048m", config.ElasticsearchJavaOpts())
+	memory := config.KibanaResources[corev1.ResourceMemory]
+	assert.Equal(t, "1Gi", memory.String())
+
+	for _, invalid := range []map[string]string{
+		{"version": "latest"},
+		{"elasticsearchReplicas": "0"},
+		{"elasticsearchMemoryLimit": "256Mi"},
+		{"elasticsearchCPURequest": "4"},
+	} {
+		_, err := ParseLogConfig(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestParseKubePrometheusConfig(t *testing.T) {
+	config, err := ParseKubePrometheusConfig(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "30d", config.Retention)
+	assert.Equal(t, int32(2), config.PrometheusReplicas)
+	assert.Equal(t, int32(3), config.AlertmanagerReplicas)
+	assert.True(t, config.AlertmanagerStorage.IsZero())
+	memory := config.PrometheusResources[corev1.ResourceMemory]
+	assert.Equal(t, "400Mi", memory.String())
+
+	config, err = ParseKubePrometheusConfig(map[string]string{
+		"retention":           "7d",
+		"storageClass":        "ssd",
+		"alertmanagerStorage": "1Gi",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "7d", config.Retention)
+	assert.Equal(t, "ssd", config.StorageClass)
+	assert.Equal(t, "1Gi", config.AlertmanagerStorage.String())
+
+	for _, invalid := range []map[string]string{
+		{"retention": "7 days"},
+		{"alertmanagerRetention": "5d"},
+		{"prometheusReplicas": "-1"},
+	} {
+		_, err := ParseKubePrometheusConfig(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
@@ -0,0 +1,222 @@
+package v1alpha1
+
+import "sort"
+
+// DependencyConfigField describes one key of DependencySpec.Config
+// +kubebuilder:object:generate=false
+type DependencyConfigField struct {
+	Name string `json:"name"`
+	// string, boolean, integer or quantity
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"`
+	// allowed values, any value of the type is allowed if empty
+	Enum []string `json:"enum,omitempty"`
+	// the value is sensitive, it must be referenced from a Secret in DependencySpec.SecretRefs
+	Secret bool `json:"secret,omitempty"`
+}
+
+// DependencyTypeInfo describes a type of Dependency that kapp knows how to install.
+// Each type has a driver in the dependency controller.
+// +kubebuilder:object:generate=false
+type DependencyTypeInfo struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// versions of the bundled manifests, from oldest to newest
+	Versions     []string                `json:"versions"`
+	ConfigSchema []DependencyConfigField `json:"configSchema"`
+
+	// Validate checks the config as a whole, after each key is checked against the schema
+	Validate func(config map[string]string) error `json:"-"`
+}
+
+// DefaultVersion is the newest version, it's installed if DependencySpec.Version is empty
+func (info DependencyTypeInfo) DefaultVersion() string {
+	return info.Versions[len(info.Versions)-1]
+}
+
+// ConfigField returns the schema of the given config key
+func (info DependencyTypeInfo) ConfigField(name string) (DependencyConfigField, bool) {
+	for _, field := range info.ConfigSchema {
+		if field.Name == name {
+			return field, true
+		}
+	}
+
+	return DependencyConfigField{}, false
+}
+
+// VersionIndex returns the position of the version in Versions, -1 if it's not available
+func (info DependencyTypeInfo) VersionIndex(version string) int {
+	for i, v := range info.Versions {
+		if v == version {
+			return i
+		}
+	}
+
+	return -1
+}
+
+var dependencyTypes = make(map[string]DependencyTypeInfo)
+
+// RegisterDependencyType adds a dependency type to the list of available dependencies.
+func RegisterDependencyType(info DependencyTypeInfo) {
+	if len(info.Versions) == 0 {
+		panic("dependency type has no version: " + info.Type)
+	}
+
+	if _, exist := dependencyTypes[info.Type]; exist {
+		panic("dependency type registered twice: " + info.Type)
+	}
+
+	dependencyTypes[info.Type] = info
+}
+
+// GetDependencyTypeInfo returns the registered info of the given dependency type
+func GetDependencyTypeInfo(depType string) (DependencyTypeInfo, bool) {
+	info, exist := dependencyTypes[depType]
+	return info, exist
+}
+
+// ListDependencyTypes returns all registered dependency types, sorted by type
+func ListDependencyTypes() []DependencyTypeInfo {
+	rst := make([]DependencyTypeInfo, 0, len(dependencyTypes))
+	for _, info := range dependencyTypes {
+		rst = append(rst, info)
+	}
+
+	sort.Slice(rst, func(i, j int) bool {
+		return rst[i].Type < rst[j].Type
+	})
+
+	return rst
+}
+
+func init() {
+	RegisterDependencyType(DependencyTypeInfo{
+		Type:        "kong",
+		Description: "kong ingress controller, serves the ingress plugins of applications",
+		Versions:    []string{"1.0.0"},
+		ConfigSchema: []DependencyConfigField{
+			{Name: "cert-manager", Type: "string", Description: "name of the cert-manager dependency used to issue tls certificates"},
+		},
+		Validate: func(config map[string]string) error {
+			_, err := ParseIngressConfig(config)
+			return err
+		},
+	})
+
+	RegisterDependencyType(DependencyTypeInfo{
+		Type:        "ingress-nginx",
+		Description: "nginx ingress controller, serves the ingress plugins of applications, an alternative to kong",
+		Versions:    []string{"1.0.0"},
+		ConfigSchema: []DependencyConfigField{
+			{Name: "cert-manager", Type: "string", Description: "name of the cert-manager dependency used to issue tls certificates"},
+		},
+		Validate: func(config map[string]string) error {
+			_, err := ParseIngressConfig(config)
+			return err
+		},
+	})
+
+	RegisterDependencyType(DependencyTypeInfo{
+		Type:        "cert-manager",
+		Description: "cert-manager, issues tls certificates for ingresses",
+		Versions:    []string{"1.0.0"},
+		ConfigSchema: []DependencyConfigField{
+			{Name: "tlsType", Type: "string", Required: true, Enum: []string{"acme", "selfSigned", "ca"}, Description: "type of the ClusterIssuer"},
+			{Name: "caCommonName", Type: "string", Description: "common name of the generated CA certificate, for tlsType ca"},
+			{Name: "acmeServer", Type: "string", Default: "production", Enum: []string{"production", "staging"}, Description: "acme server of let's encrypt"},
+			{Name: "challengeEmail", Type: "string", Description: "email of the acme account, required for tlsType acme"},
+			{Name: "challengeType", Type: "string", Default: ACMEChallengeDNS01, Enum: []string{ACMEChallengeHTTP01, ACMEChallengeDNS01}, Description: "acme challenge"},
+			{Name: "challengeIngressClass", Type: "string", Description: "ingress class which serves the http01 challenge, kong or nginx, the class of the installed ingress dependency by default"},
+			{
+				Name: "challengeProvider", Type: "string", Description: "dns01 provider",
+				Enum: []string{ACMEDNS01ProviderCloudflare, ACMEDNS01ProviderRoute53, ACMEDNS01ProviderCloudDNS, ACMEDNS01ProviderDigitalOcean, ACMEDNS01ProviderRFC2136},
+			},
+			{Name: "challengeSecret", Type: "string", Secret: true, Description: "api key of cloudflare"},
+			{Name: "route53Region", Type: "string", Description: "aws region of route53"},
+			{Name: "route53AccessKeyID", Type: "string", Description: "aws access key id"},
+			{Name: "route53SecretAccessKey", Type: "string", Secret: true, Description: "aws secret access key"},
+			{Name: "route53HostedZoneID", Type: "string", Description: "hosted zone of route53, optional"},
+			{Name: "route53Role", Type: "string", Description: "aws role to assume, optional"},
+			{Name: "clouddnsProject", Type: "string", Description: "google cloud project of clouddns"},
+			{Name: "clouddnsServiceAccount", Type: "string", Secret: true, Description: "json key of a google service account"},
+			{Name: "digitaloceanToken", Type: "string", Secret: true, Description: "api token of digitalocean"},
+			{Name: "rfc2136Nameserver", Type: "string", Description: "address of the rfc2136 dns server, host:port"},
+			{Name: "rfc2136TSIGKeyName", Type: "string", Description: "name of the tsig key"},
+			{Name: "rfc2136TSIGAlgorithm", Type: "string", Description: "algorithm of the tsig key, HMACMD5 by default"},
+			{Name: "rfc2136TSIGSecret", Type: "string", Secret: true, Description: "secret of the tsig key"},
+		},
+		Validate: func(config map[string]string) error {
+			_, err := ParseCertManagerConfig(config)
+			return err
+		},
+	})
+
+	RegisterDependencyType(DependencyTypeInfo{
+		Type:        "kube-prometheus",
+		Description: "prometheus operator, prometheus, alertmanager and grafana",
+		Versions:    []string{"1.0.0"},
+		ConfigSchema: []DependencyConfigField{
+			{Name: "persistenVolumeSize", Type: "quantity", Default: "128Mi", Description: "size of the prometheus volumes, they can only grow"},
+			{Name: "storageClass", Type: "string", Description: "storage class of the prometheus and alertmanager volumes, the default storage class if empty, only used by new volumes"},
+			{Name: "retention", Type: "string", Default: "30d", Description: "how long prometheus keeps metrics, e.g. 15d"},
+			{Name: "prometheusReplicas", Type: "integer", Default: "2", Description: "replicas of prometheus"},
+			{Name: "prometheusCPURequest", Type: "quantity", Description: "cpu request of prometheus"},
+			{Name: "prometheusMemoryRequest", Type: "quantity", Default: "400Mi", Description: "memory request of prometheus"},
+			{Name: "alertmanagerReplicas", Type: "integer", Default: "3", Description: "replicas of alertmanager"},
+			{Name: "alertmanagerStorage", Type: "quantity", Description: "size of the alertmanager volumes, they can only grow, emptyDir if empty"},
+			{Name: "alertmanagerRetention", Type: "string", Default: "120h", Description: "how long alertmanager keeps silences and notifications, e.g. 120h"},
+			{Name: "alertmanagerCPURequest", Type: "quantity", Description: "cpu request of alertmanager"},
+			{Name: "alertmanagerMemoryRequest", Type: "quantity", Description: "memory request of alertmanager"},
+			{Name: "grafanaHost", Type: "string", Description: "host of the grafana ingress"},
+			{Name: "prometheusHost", Type: "string", Description: "host of the prometheus ingress"},
+			{Name: "cert-manager", Type: "string", Description: "name of the cert-manager dependency used to issue tls certificates of the ingresses"},
+		},
+		Validate: func(config map[string]string) error {
+			_, err := ParseKubePrometheusConfig(config)
+			return err
+		},
+	})
+
+	RegisterDependencyType(DependencyTypeInfo{
+		Type:        "log",
+		Description: "elasticsearch, kibana and filebeat, collects logs of all pods",
+		Versions:    []string{"1.0.0"},
+		ConfigSchema: []DependencyConfigField{
+			{Name: "storage", Type: "quantity", Default: "512Mi", Description: "size of the elasticsearch volumes, at least 512Mi"},
+			{Name: "storageClass", Type: "string", Description: "storage class of the elasticsearch volumes, the default storage class if empty"},
+			{Name: "version", Type: "string", Default: "7.6.1", Description: "version of elasticsearch and kibana"},
+			{Name: "elasticsearchReplicas", Type: "integer", Default: "1", Description: "nodes of elasticsearch"},
+			{Name: "elasticsearchCPURequest", Type: "quantity", Default: "500m", Description: "cpu request of elasticsearch"},
+			{Name: "elasticsearchMemoryRequest", Type: "quantity", Default: "512Mi", Description: "memory request of elasticsearch"},
+			{Name: "elasticsearchCPULimit", Type: "quantity", Default: "2", Description: "cpu limit of elasticsearch"},
+			{Name: "elasticsearchMemoryLimit", Type: "quantity", Default: "1Gi", Description: "memory limit of elasticsearch, the jvm heap is half of it, at least 512Mi"},
+			{Name: "kibanaReplicas", Type: "integer", Default: "1", Description: "replicas of kibana"},
+			{Name: "kibanaCPURequest", Type: "quantity", Description: "cpu request of kibana"},
+			{Name: "kibanaMemoryRequest", Type: "quantity", Description: "memory request of kibana"},
+			{Name: "kibanaHost", Type: "string", Description: "host of the kibana ingress"},
+			{Name: "cert-manager", Type: "string", Description: "name of the cert-manager dependency used to issue tls certificates of the ingress"},
+		},
+		Validate: func(config map[string]string) error {
+			_, err := ParseLogConfig(config)
+			return err
+		},
+	})
+
+	RegisterDependencyType(DependencyTypeInfo{
+		Type:        "loki",
+		Description: "loki and promtail, collects logs of all pods, lighter than the elasticsearch based log dependency",
+		Versions:    []string{"1.0.0"},
+		ConfigSchema: []DependencyConfigField{
+			{Name: "storage", Type: "quantity", Default: "1Gi", Description: "size of the loki volume, it can only grow"},
+			{Name: "storageClass", Type: "string", Description: "storage class of the loki volume, the default storage class if empty"},
+		},
+		Validate: func(config map[string]string) error {
+			_, err := ParseLokiConfig(config)
+			return err
+		},
+	})
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -32,6 +33,21 @@ type DependencySpec struct {
 	Type    string            `json:"type"`
 	Version string            `json:"version"`
 	Config  map[string]string `json:"config,omitempty"`
+
+	// SecretRefs provides the values of sensitive config keys, which are not allowed in Config.
+	// The key is the name of the config key.
+	SecretRefs map[string]DependencySecretKeyRef `json:"secretRefs,omitempty"`
+
+	// KeepData preserves the PersistentVolumeClaims, and the namespaces they are in,
+	// when the dependency is uninstalled
+	KeepData bool `json:"keepData,omitempty"`
+}
+
+// DependencySecretKeyRef selects a key of a Secret
+type DependencySecretKeyRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Key       string `json:"key"`
 }
 
 // DependencyStatus defines the observed state of Dependency
@@ -39,6 +55,75 @@ type DependencyStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Status string `json:"status"`
+
+	// version of the bundled manifests which are installed
+	InstalledVersion string `json:"installedVersion,omitempty"`
+
+	// generation of the Dependency this status is for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// last time Status changed
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	Conditions []DependencyCondition `json:"conditions,omitempty"`
+
+	// deployments and statefulsets installed by the dependency
+	Components []DependencyComponentStatus `json:"components,omitempty"`
+
+	// the bundled manifests applied last time
+	AppliedManifests *DependencyAppliedManifests `json:"appliedManifests,omitempty"`
+}
+
+// DependencyAppliedManifests records the bundled manifests which are applied,
+// they are applied again when they are changed, or periodically to correct drift
+type DependencyAppliedManifests struct {
+	Version string `json:"version"`
+	// hash of the objects in the manifests
+	Hash      string      `json:"hash"`
+	AppliedAt metav1.Time `json:"appliedAt"`
+	// objects in the manifests, those not in the manifests applied next time are deleted
+	Objects []DependencyAppliedObject `json:"objects,omitempty"`
+}
+
+type DependencyAppliedObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+type DependencyConditionType string
+
+const (
+	// all components are installed and ready
+	DependencyConditionInstalled DependencyConditionType = "Installed"
+	// installed version is the version in spec
+	DependencyConditionUpToDate DependencyConditionType = "UpToDate"
+	// resources derived from config are reconciled
+	DependencyConditionConfigured DependencyConditionType = "Configured"
+	// dependency is Running
+	DependencyConditionReady DependencyConditionType = "Ready"
+)
+
+type DependencyCondition struct {
+	Type   DependencyConditionType `json:"type"`
+	Status corev1.ConditionStatus  `json:"status"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// +optional
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+type DependencyComponentStatus struct {
+	// Deployment or StatefulSet
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Ready     int32  `json:"ready"`
+	Desired   int32  `json:"desired"`
+	// reason why the component is not ready, e.g. the waiting reason of a container
+	LastError string `json:"lastError,omitempty"`
 }
 
 const (
@@ -48,6 +133,8 @@ const (
 	DependencyStatusUninstalling  = "Uninstalling"
 	DependencyStatusInstalled     = "Installed"
 	DependencyStatusRunning       = "Running"
+	DependencyStatusUpgrading     = "Upgrading"
+	DependencyStatusUpgradeFailed = "Upgrade Failed"
 )
 
 // +kubebuilder:object:root=true
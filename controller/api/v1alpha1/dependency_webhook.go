@@ -0,0 +1,49 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var dependencylog = logf.Log.WithName("dependency-resource")
+
+func (r *Dependency) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-kapp-dev-v1alpha1-dependency,mutating=false,failurePolicy=fail,groups=core.kapp.dev,resources=dependencies,versions=v1alpha1,name=vdependency.kb.io
+
+var _ webhook.Validator = &Dependency{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Dependency) ValidateCreate() error {
+	dependencylog.Info("validate create", "name", r.Name)
+
+	return ValidateDependencySpec(r.Spec)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Dependency) ValidateUpdate(old runtime.Object) error {
+	dependencylog.Info("validate update", "name", r.Name)
+
+	oldDep, ok := old.(*Dependency)
+	if !ok {
+		return ValidateDependencySpec(r.Spec)
+	}
+
+	if oldDep.Spec.Type != r.Spec.Type {
+		return fmt.Errorf("type of a dependency can not be changed, from %s to %s", oldDep.Spec.Type, r.Spec.Type)
+	}
+
+	return ValidateDependencySpecUpdate(r.Spec, oldDep.Spec)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Dependency) ValidateDelete() error {
+	return nil
+}
@@ -0,0 +1,138 @@
+package v1alpha1
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"text/template"
+)
+
+const clusterDomain = "svc.cluster.local"
+
+// EnvTemplateVariables are the variables can be used in the template of an env
+// +kubebuilder:object:generate=false
+type EnvTemplateVariables struct {
+	// value of the shared env, external env only
+	Value string
+
+	// the following are for linked env only
+
+	// name of the service
+	ServiceName string
+	// namespace of the service
+	Namespace string
+	// svc.ns
+	Host string
+	// svc.ns.svc.cluster.local
+	FQDN string
+	// service port
+	Port int32
+}
+
+func NewLinkedEnvTemplateVariables(serviceName, namespace string, port int32) EnvTemplateVariables {
+	host := fmt.Sprintf("%s.%s", serviceName, namespace)
+
+	return EnvTemplateVariables{
+		ServiceName: serviceName,
+		Namespace:   namespace,
+		Host:        host,
+		FQDN:        fmt.Sprintf("%s.%s", host, clusterDomain),
+		Port:        port,
+	}
+}
+
+// FormatLinkedEnvValue returns <prefix>value<suffix>, value is rendered by the template or format of env
+func FormatLinkedEnvValue(env EnvVar, vars EnvTemplateVariables) (string, error) {
+	var value string
+
+	if env.Template != "" {
+		rendered, err := renderEnvTemplate(env, vars)
+		if err != nil {
+			return "", err
+		}
+
+		value = rendered
+	} else {
+		switch env.Format {
+		case "", EnvVarFormatHostPort:
+			value = fmt.Sprintf("%s:%d", vars.Host, vars.Port)
+		case EnvVarFormatHost:
+			value = vars.Host
+		case EnvVarFormatPort:
+			value = strconv.Itoa(int(vars.Port))
+		case EnvVarFormatFQDN:
+			value = vars.FQDN
+		case EnvVarFormatFQDNWithPort:
+			value = fmt.Sprintf("%s:%d", vars.FQDN, vars.Port)
+		default:
+			return "", fmt.Errorf("unknown format %s of env %s", env.Format, env.Name)
+		}
+	}
+
+	return fmt.Sprintf("%s%s%s", env.Prefix, value, env.Suffix), nil
+}
+
+// FormatExternalEnvValue applies the template of env to the value of shared env
+func FormatExternalEnvValue(env EnvVar, value string) (string, error) {
+	if env.Template == "" {
+		return value, nil
+	}
+
+	return renderEnvTemplate(env, EnvTemplateVariables{Value: value})
+}
+
+// templateVariablesOf are sample variables of the env type, without the ones the type doesn't get at runtime,
+// so templates using them fail to render
+func templateVariablesOf(envType EnvVarType) map[string]interface{} {
+	if envType == EnvVarTypeExternal {
+		return map[string]interface{}{"Value": "value"}
+	}
+
+	vars := NewLinkedEnvTemplateVariables("svc", "ns", 80)
+
+	return map[string]interface{}{
+		"ServiceName": vars.ServiceName,
+		"Namespace":   vars.Namespace,
+		"Host":        vars.Host,
+		"FQDN":        vars.FQDN,
+		"Port":        vars.Port,
+	}
+}
+
+func renderEnvTemplate(env EnvVar, vars interface{}) (string, error) {
+	tmpl, err := template.New(env.Name).Option("missingkey=error").Parse(env.Template)
+	if err != nil {
+		return "", fmt.Errorf("wrong template of env %s, %s", env.Name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("wrong template of env %s, %s", env.Name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// 1. format is only for linked env
+// 2. template is only for linked and external env, and can be rendered
+func isValidEnvFormats(spec ApplicationSpec) error {
+	for _, env := range allEnvs(spec) {
+		if env.Format != "" && env.Type != EnvVarTypeLinked {
+			return fmt.Errorf("format of env %s is only allowed for linked env", env.Name)
+		}
+
+		if env.Template == "" {
+			continue
+		}
+
+		if env.Type != EnvVarTypeLinked && env.Type != EnvVarTypeExternal {
+			return fmt.Errorf("template of env %s is only allowed for linked or external env", env.Name)
+		}
+
+		if _, err := renderEnvTemplate(env, templateVariablesOf(env.Type)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
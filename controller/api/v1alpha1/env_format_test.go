@@ -0,0 +1,72 @@
+package v1alpha1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFormatLinkedEnvValue(t *testing.T) {
+	vars := NewLinkedEnvTemplateVariables("svc-shop-db", "kapp-prod", 5432)
+
+	cases := []struct {
+		env      EnvVar
+		expected string
+	}{
+		{EnvVar{Name: "a"}, "svc-shop-db.kapp-prod:5432"},
+		{EnvVar{Name: "a", Prefix: "http://", Suffix: "/api"}, "http://svc-shop-db.kapp-prod:5432/api"},
+		{EnvVar{Name: "a", Format: EnvVarFormatHost}, "svc-shop-db.kapp-prod"},
+		{EnvVar{Name: "a", Format: EnvVarFormatPort}, "5432"},
+		{EnvVar{Name: "a", Format: EnvVarFormatFQDN}, "svc-shop-db.kapp-prod.svc.cluster.local"},
+		{EnvVar{Name: "a", Format: EnvVarFormatFQDNWithPort}, "svc-shop-db.kapp-prod.svc.cluster.local:5432"},
+		{EnvVar{Name: "a", Format: EnvVarFormatHost, Template: "postgres://{{.Host}}:{{.Port}}/db"}, "postgres://svc-shop-db.kapp-prod:5432/db"},
+	}
+
+	for _, c := range cases {
+		value, err := FormatLinkedEnvValue(c.env, vars)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, value)
+	}
+
+	_, err := FormatLinkedEnvValue(EnvVar{Name: "a", Template: "{{.Unknown}}"}, vars)
+	assert.NotNil(t, err)
+}
+
+func TestFormatExternalEnvValue(t *testing.T) {
+	value, err := FormatExternalEnvValue(EnvVar{Name: "a"}, "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com", value)
+
+	value, err = FormatExternalEnvValue(EnvVar{Name: "a", Template: "https://{{.Value}}/"}, "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com/", value)
+}
+
+func TestIsValidEnvFormats(t *testing.T) {
+	spec := ApplicationSpec{
+		Components: []ComponentSpec{
+			{
+				Name: "web",
+				Env: []EnvVar{
+					{Name: "DB", Type: EnvVarTypeLinked, Value: "kapp-prod/shop/db/pg", Template: "postgres://{{.FQDN}}:{{.Port}}/db"},
+				},
+			},
+		},
+	}
+	assert.Nil(t, TryValidateApplication(spec))
+
+	spec.Components[0].Env[0].Template = "{{.Host"
+	assert.NotNil(t, TryValidateApplication(spec))
+
+	// linked envs have no value, external envs have nothing but the value
+	spec.Components[0].Env[0].Template = "{{.Value}}"
+	assert.NotNil(t, TryValidateApplication(spec))
+
+	spec.Components[0].Env[0] = EnvVar{Name: "API", Type: EnvVarTypeExternal, Value: "api", Template: "https://{{.Value}}/"}
+	assert.Nil(t, TryValidateApplication(spec))
+
+	spec.Components[0].Env[0].Template = "https://{{.Host}}/"
+	assert.NotNil(t, TryValidateApplication(spec))
+
+	spec.Components[0].Env[0] = EnvVar{Name: "A", Type: EnvVarTypeStatic, Value: "a", Format: EnvVarFormatHost}
+	assert.NotNil(t, TryValidateApplication(spec))
+}
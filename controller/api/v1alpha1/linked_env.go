@@ -0,0 +1,124 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LinkedEnvTarget is the port a linked env refers to.
+// The value of a linked env is either "component/port" in the same application,
+// or "namespace/application/component/port" for a component of another application.
+// +kubebuilder:object:generate=false
+type LinkedEnvTarget struct {
+	// empty if the target is in the same application
+	Namespace   string
+	Application string
+
+	Component string
+	Port      string
+}
+
+func ParseLinkedEnvValue(value string) (*LinkedEnvTarget, error) {
+	parts := strings.Split(value, "/")
+
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("wrong linked env %s, empty part", value)
+		}
+	}
+
+	switch len(parts) {
+	case 2:
+		return &LinkedEnvTarget{
+			Component: parts[0],
+			Port:      parts[1],
+		}, nil
+	case 4:
+		return &LinkedEnvTarget{
+			Namespace:   parts[0],
+			Application: parts[1],
+			Component:   parts[2],
+			Port:        parts[3],
+		}, nil
+	default:
+		return nil, fmt.Errorf("wrong linked env %s, should be component/port or namespace/application/component/port", value)
+	}
+}
+
+func (t *LinkedEnvTarget) IsCrossApplication() bool {
+	return t.Application != ""
+}
+
+// GetCrossApplicationLinkedEnvTargets returns the targets of linked envs which refer to other applications,
+// invalid values are ignored.
+func GetCrossApplicationLinkedEnvTargets(spec ApplicationSpec) []*LinkedEnvTarget {
+	var targets []*LinkedEnvTarget
+
+	for _, env := range allLinkedEnvs(spec) {
+		target, err := ParseLinkedEnvValue(env.Value)
+
+		if err != nil || !target.IsCrossApplication() {
+			continue
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets
+}
+
+// 1. linked env value is well formed
+// 2. component referenced in the same application exists
+func isValidLinkedEnvs(spec ApplicationSpec) error {
+	components := make(map[string]bool)
+
+	for _, component := range spec.Components {
+		components[component.Name] = true
+	}
+
+	for _, env := range allLinkedEnvs(spec) {
+		target, err := ParseLinkedEnvValue(env.Value)
+
+		if err != nil {
+			return err
+		}
+
+		if !target.IsCrossApplication() && !components[target.Component] {
+			return fmt.Errorf("wrong linked env %s, component %s not exist", env.Value, target.Component)
+		}
+	}
+
+	return nil
+}
+
+// linked envs with value in shared env and all components, including overlays
+func allLinkedEnvs(spec ApplicationSpec) []EnvVar {
+	var envs []EnvVar
+
+	for _, env := range allEnvs(spec) {
+		if env.Type == EnvVarTypeLinked && env.Value != "" {
+			envs = append(envs, env)
+		}
+	}
+
+	return envs
+}
+
+// envs in shared env and all components, including overlays
+func allEnvs(spec ApplicationSpec) []EnvVar {
+	all := append([]EnvVar{}, spec.SharedEnv...)
+
+	for _, component := range spec.Components {
+		all = append(all, component.Env...)
+	}
+
+	for _, overlay := range spec.Overlays {
+		all = append(all, overlay.SharedEnv...)
+
+		for _, component := range overlay.Components {
+			all = append(all, component.Env...)
+		}
+	}
+
+	return all
+}
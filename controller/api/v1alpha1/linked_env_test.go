@@ -0,0 +1,46 @@
+package v1alpha1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseLinkedEnvValue(t *testing.T) {
+	target, err := ParseLinkedEnvValue("web/http")
+	assert.Nil(t, err)
+	assert.False(t, target.IsCrossApplication())
+	assert.Equal(t, "web", target.Component)
+	assert.Equal(t, "http", target.Port)
+
+	target, err = ParseLinkedEnvValue("kapp-prod/shop/api/http")
+	assert.Nil(t, err)
+	assert.True(t, target.IsCrossApplication())
+	assert.Equal(t, "kapp-prod", target.Namespace)
+	assert.Equal(t, "shop", target.Application)
+
+	for _, value := range []string{"web", "shop/api/http", "kapp-prod//api/http", "a/b/c/d/e"} {
+		_, err = ParseLinkedEnvValue(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestIsValidLinkedEnvs(t *testing.T) {
+	spec := ApplicationSpec{
+		Components: []ComponentSpec{
+			{
+				Name: "web",
+				Env: []EnvVar{
+					{Name: "API", Type: EnvVarTypeLinked, Value: "api/http"},
+					{Name: "SHOP", Type: EnvVarTypeLinked, Value: "kapp-prod/shop/api/http"},
+				},
+			},
+			{Name: "api"},
+		},
+	}
+
+	assert.Nil(t, TryValidateApplication(spec))
+	assert.Equal(t, 1, len(GetCrossApplicationLinkedEnvTargets(spec)))
+
+	spec.Components[0].Env[0].Value = "worker/http"
+	assert.NotNil(t, TryValidateApplication(spec))
+}
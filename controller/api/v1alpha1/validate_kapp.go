@@ -1,8 +1,7 @@
 package v1alpha1
 
 func TryValidateApplication(appSpec ApplicationSpec) error {
-	// for now only check dependency here
-	validateFuncs := []func(spec ApplicationSpec) error{isValidateDependency}
+	validateFuncs := []func(spec ApplicationSpec) error{isValidateDependency, isValidOverlays, isValidLinkedEnvs, isValidEnvFormats, isValidComponentMetrics, isValidAlerts}
 
 	for _, validateFunc := range validateFuncs {
 		if err := validateFunc(appSpec); err != nil {
@@ -24,8 +24,11 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // ApplicationReconciler reconciles a Application object
@@ -40,12 +43,18 @@ var ownerKey = ".metadata.controller"
 var apiGVStr = corev1alpha1.GroupVersion.String()
 var finalizerName = "storage.finalizers.kapp.dev"
 
+// index of Applications by the services of other applications they link to, value is "namespace/serviceName"
+var linkedServiceKey = ".spec.linkedServices"
+
 // +kubebuilder:rbac:groups=core.kapp.dev,resources=applications,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core.kapp.dev,resources=applications/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=extensions,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=extensions,resources=deployments/status,verbs=get
 // +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=batch,resources=cronjobs/status,verbs=get
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 
 func (r *ApplicationReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -125,10 +134,70 @@ func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	if err := mgr.GetFieldIndexer().IndexField(&corev1alpha1.Application{}, linkedServiceKey, func(rawObj runtime.Object) []string {
+		app := rawObj.(*corev1alpha1.Application)
+
+		var keys []string
+		for _, target := range corev1alpha1.GetCrossApplicationLinkedEnvTargets(app.Spec) {
+			keys = append(keys, target.Namespace+"/"+getServiceName(target.Application, target.Component))
+		}
+
+		return keys
+	}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1alpha1.Application{}).
 		Owns(&appv1.Deployment{}).
 		Owns(&v1beta1.CronJob{}).
 		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		// re-resolve linked envs of applications which refer to a service of another application
+		Watches(
+			&source.Kind{Type: &corev1.Service{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []ctrl.Request {
+					var list corev1alpha1.ApplicationList
+					if err := r.List(context.TODO(), &list, client.MatchingFields{linkedServiceKey: obj.Meta.GetNamespace() + "/" + obj.Meta.GetName()}); err != nil {
+						r.Log.Error(err, "unable to list applications linked to service", "service", obj.Meta.GetName())
+						return nil
+					}
+
+					res := make([]ctrl.Request, len(list.Items))
+					for i, app := range list.Items {
+						res[i].Name = app.Name
+						res[i].Namespace = app.Namespace
+					}
+
+					return res
+				}),
+			},
+		).
+		// service monitors, alerts and dashboards are created once kube-prometheus is running
+		Watches(
+			&source.Kind{Type: &corev1alpha1.Dependency{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []ctrl.Request {
+					dep, ok := obj.Object.(*corev1alpha1.Dependency)
+					if !ok || dep.Spec.Type != dependencyTypeKubePrometheus {
+						return nil
+					}
+
+					var list corev1alpha1.ApplicationList
+					if err := r.List(context.TODO(), &list); err != nil {
+						r.Log.Error(err, "unable to list applications for dependency", "dependency", dep.Name)
+						return nil
+					}
+
+					res := make([]ctrl.Request, len(list.Items))
+					for i, app := range list.Items {
+						res[i].NamespacedName = types.NamespacedName{Namespace: app.Namespace, Name: app.Name}
+					}
+
+					return res
+				}),
+			},
+		).
 		Complete(r)
 }
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	kappV1Alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func getPrometheusRuleName(appName string) string {
+	return fmt.Sprintf("alerts-%s", appName)
+}
+
+// reconcilePrometheusRule renders the alerts of the application into a PrometheusRule,
+// which is deleted if the application has no alerts
+func (act *applicationReconcilerTask) reconcilePrometheusRule() error {
+	ctx := act.ctx
+	log := act.log
+
+	var rule monitoringv1.PrometheusRule
+	err := act.reconciler.Get(ctx, types.NamespacedName{Namespace: act.app.Namespace, Name: getPrometheusRuleName(act.app.Name)}, &rule)
+
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	exist := err == nil
+
+	desired := desiredPrometheusRule(act.app, act.spec)
+	if desired == nil {
+		if !exist {
+			return nil
+		}
+
+		if err := act.reconciler.Delete(ctx, &rule); err != nil {
+			log.Error(err, "unable to delete PrometheusRule")
+			return err
+		}
+
+		return nil
+	}
+
+	if !exist {
+		if err := ctrl.SetControllerReference(act.app, desired, act.reconciler.Scheme); err != nil {
+			return err
+		}
+
+		if err := act.reconciler.Create(ctx, desired); err != nil {
+			log.Error(err, "unable to create PrometheusRule")
+			return err
+		}
+
+		return nil
+	}
+
+	rule.Labels = desired.Labels
+	rule.Spec = desired.Spec
+
+	if err := act.reconciler.Update(ctx, &rule); err != nil {
+		log.Error(err, "unable to update PrometheusRule")
+		return err
+	}
+
+	return nil
+}
+
+// desiredPrometheusRule has a group of rules for the alerts, nil if there are no rules.
+// The labels are selected by the ruleSelector of the prometheus of kube-prometheus.
+func desiredPrometheusRule(app *kappV1Alpha1.Application, spec *kappV1Alpha1.ApplicationSpec) *monitoringv1.PrometheusRule {
+	var rules []monitoringv1.Rule
+
+	for i := range spec.Alerts {
+		rules = append(rules, alertRules(app, spec, &spec.Alerts[i])...)
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	return &monitoringv1.PrometheusRule{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      getPrometheusRuleName(app.Name),
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				"kapp-application": app.Name,
+				"prometheus":       "k8s",
+				"role":             "alert-rules",
+			},
+		},
+		Spec: monitoringv1.PrometheusRuleSpec{
+			Groups: []monitoringv1.RuleGroup{
+				{
+					Name:  fmt.Sprintf("kapp-%s-%s", app.Namespace, app.Name),
+					Rules: rules,
+				},
+			},
+		},
+	}
+}
+
+// alertRules of built-in alerts have a rule for each component they watch, custom alerts have one rule
+func alertRules(app *kappV1Alpha1.Application, spec *kappV1Alpha1.ApplicationSpec, alert *kappV1Alpha1.ApplicationAlert) []monitoringv1.Rule {
+	newRule := func(component, expr, summary string) monitoringv1.Rule {
+		labels := map[string]string{
+			"severity":                         alert.GetSeverity(),
+			"namespace":                        app.Namespace,
+			kappV1Alpha1.AlertLabelApplication: app.Name,
+		}
+
+		if component != "" {
+			labels[kappV1Alpha1.AlertLabelComponent] = component
+		}
+
+		if alert.Summary != "" {
+			summary = alert.Summary
+		}
+
+		return monitoringv1.Rule{
+			Alert:       alert.Name,
+			Expr:        intstr.FromString(expr),
+			For:         alert.For,
+			Labels:      labels,
+			Annotations: map[string]string{"summary": summary},
+		}
+	}
+
+	if alert.Type == kappV1Alpha1.AlertTypeCustom {
+		// the expr is validated, an invalid one is not rendered rather than read other namespaces
+		expr, err := kappV1Alpha1.NamespacedAlertExpr(alert.Expr, app.Namespace)
+		if err != nil {
+			return nil
+		}
+
+		return []monitoringv1.Rule{newRule("", expr, fmt.Sprintf("%s of %s/%s", alert.Name, app.Namespace, app.Name))}
+	}
+
+	var rules []monitoringv1.Rule
+
+	for _, component := range spec.Components {
+		if alert.Component != "" && alert.Component != component.Name {
+			continue
+		}
+
+		switch alert.Type {
+		case kappV1Alpha1.AlertTypeRestarts:
+			rules = append(rules, newRule(component.Name, fmt.Sprintf(
+				`sum by (namespace, pod) (increase(kube_pod_container_status_restarts_total{namespace=%s}[%s]) %s) > %d`,
+				strconv.Quote(app.Namespace), alert.GetWindow(), joinComponentPods(app, component.Name), alert.Threshold),
+				fmt.Sprintf("pod {{ $labels.pod }} of component %s restarted more than %d times in %s", component.Name, alert.Threshold, alert.GetWindow()),
+			))
+		case kappV1Alpha1.AlertTypeAvailability:
+			deployment := fmt.Sprintf(`{namespace=%s,deployment=%s}`,
+				strconv.Quote(app.Namespace), strconv.Quote(getDeploymentName(app.Name, component.Name)))
+
+			rules = append(rules, newRule(component.Name, fmt.Sprintf(
+				`kube_deployment_status_replicas_available%s / on (namespace, deployment) kube_deployment_spec_replicas%s * 100 < %d`,
+				deployment, deployment, alert.Threshold),
+				fmt.Sprintf("less than %d%% replicas of component %s are available", alert.Threshold, component.Name),
+			))
+		case kappV1Alpha1.AlertTypeMemoryNearLimit:
+			// there is no limit to be near to
+			if component.Memory == nil || component.Memory.IsZero() {
+				continue
+			}
+
+			rules = append(rules, newRule(component.Name, fmt.Sprintf(
+				`max by (namespace, pod, container) (container_memory_working_set_bytes%s) / on (namespace, pod, container) `+
+					`max by (namespace, pod, container) (kube_pod_container_resource_limits_memory_bytes{namespace=%s}) * 100 %s > %d`,
+				containersSelector(app.Namespace), strconv.Quote(app.Namespace), joinComponentPods(app, component.Name), alert.Threshold),
+				fmt.Sprintf("memory of pod {{ $labels.pod }} of component %s is more than %d%% of its limit", component.Name, alert.Threshold),
+			))
+		}
+	}
+
+	return rules
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcilePrometheusRule(t *testing.T) {
+	ctx := context.Background()
+	memory := resource.MustParse("256Mi")
+
+	app := &corev1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kapp-test", Name: "shop", UID: "uid"},
+		Spec: corev1alpha1.ApplicationSpec{
+			Components: []corev1alpha1.ComponentSpec{
+				{Name: "web", Memory: &memory},
+				{Name: "worker"},
+			},
+			Alerts: []corev1alpha1.ApplicationAlert{
+				{Name: "Restarting", Type: corev1alpha1.AlertTypeRestarts, Threshold: 3},
+				{Name: "WorkerUnavailable", Type: corev1alpha1.AlertTypeAvailability, Component: "worker", Threshold: 50, For: "5m"},
+				{Name: "MemoryNearLimit", Type: corev1alpha1.AlertTypeMemoryNearLimit, Threshold: 90, Severity: corev1alpha1.AlertSeverityCritical},
+				{Name: "Errors", Type: corev1alpha1.AlertTypeCustom, Expr: `rate(http_requests_total{code="500"}[5m]) > 1`},
+			},
+		},
+	}
+
+	task := newFakeApplicationReconcilerTask(app)
+	assert.Nil(t, task.reconcilePrometheusRule())
+
+	ruleName := types.NamespacedName{Namespace: "kapp-test", Name: getPrometheusRuleName("shop")}
+	rule := monitoringv1.PrometheusRule{}
+	assert.Nil(t, task.reconciler.Get(ctx, ruleName, &rule))
+	assert.Equal(t, "k8s", rule.Labels["prometheus"])
+	assert.Equal(t, "shop", metav1.GetControllerOf(&rule).Name)
+
+	rules := rule.Spec.Groups[0].Rules
+	// restarts of web and worker, availability of worker, memory of web, and the custom one
+	assert.Len(t, rules, 5)
+
+	assert.Equal(t, "Restarting", rules[0].Alert)
+	assert.Equal(t, "web", rules[0].Labels[corev1alpha1.AlertLabelComponent])
+	assert.True(t, strings.HasSuffix(rules[0].Expr.String(), "> 3"))
+	assert.Equal(t, "worker", rules[1].Labels[corev1alpha1.AlertLabelComponent])
+
+	assert.Equal(t, "WorkerUnavailable", rules[2].Alert)
+	assert.Equal(t, "5m", rules[2].For)
+	assert.Contains(t, rules[2].Expr.String(), `deployment="shop-worker"`)
+
+	assert.Equal(t, "MemoryNearLimit", rules[3].Alert)
+	assert.Equal(t, "web", rules[3].Labels[corev1alpha1.AlertLabelComponent])
+	assert.Equal(t, corev1alpha1.AlertSeverityCritical, rules[3].Labels["severity"])
+
+	assert.Equal(t, `rate(http_requests_total{namespace="kapp-test",code="500"}[5m]) > 1`, rules[4].Expr.String())
+	assert.Equal(t, "shop", rules[4].Labels[corev1alpha1.AlertLabelApplication])
+	assert.Equal(t, "kapp-test", rules[4].Labels["namespace"])
+
+	// deleted with the alerts
+	app.Spec.Alerts = nil
+	assert.Nil(t, task.reconcilePrometheusRule())
+	assert.NotNil(t, task.reconciler.Get(ctx, ruleName, &monitoringv1.PrometheusRule{}))
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	kappV1Alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// name of the datasource of prometheus in the grafana of kube-prometheus
+const grafanaDatasource = "prometheus"
+
+func getDashboardConfigMapName(appName string) string {
+	return fmt.Sprintf("dashboard-%s", appName)
+}
+
+// reconcileDashboard keeps the grafana dashboard of the application in a ConfigMap,
+// which is loaded by the dashboard sidecar of grafana
+func (act *applicationReconcilerTask) reconcileDashboard() error {
+	ctx := act.ctx
+	log := act.log
+
+	desired, err := desiredDashboardConfigMap(act.app, act.spec)
+	if err != nil {
+		return err
+	}
+
+	var cm coreV1.ConfigMap
+	err = act.reconciler.Get(ctx, types.NamespacedName{Namespace: desired.Namespace, Name: desired.Name}, &cm)
+
+	if errors.IsNotFound(err) {
+		if err := ctrl.SetControllerReference(act.app, desired, act.reconciler.Scheme); err != nil {
+			return err
+		}
+
+		if err := act.reconciler.Create(ctx, desired); err != nil {
+			log.Error(err, "unable to create dashboard ConfigMap")
+			return err
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(cm.Labels, desired.Labels) && reflect.DeepEqual(cm.Data, desired.Data) {
+		return nil
+	}
+
+	cm.Labels = desired.Labels
+	cm.Data = desired.Data
+
+	if err := act.reconciler.Update(ctx, &cm); err != nil {
+		log.Error(err, "unable to update dashboard ConfigMap")
+		return err
+	}
+
+	return nil
+}
+
+func desiredDashboardConfigMap(app *kappV1Alpha1.Application, spec *kappV1Alpha1.ApplicationSpec) (*coreV1.ConfigMap, error) {
+	uid := kappV1Alpha1.GrafanaDashboardUID(app.Namespace, app.Name)
+
+	dashboard, err := json.MarshalIndent(genDashboard(uid, app, spec), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &coreV1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      getDashboardConfigMapName(app.Name),
+			Namespace: app.Namespace,
+			Labels: map[string]string{
+				"kapp-application":                 app.Name,
+				kappV1Alpha1.GrafanaDashboardLabel: "1",
+			},
+		},
+		// the sidecar writes files of all namespaces into one folder, the key is unique by the uid
+		Data: map[string]string{
+			uid + ".json": string(dashboard),
+		},
+	}, nil
+}
+
+type grafanaDashboard struct {
+	UID           string            `json:"uid"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	Editable      bool              `json:"editable"`
+	Refresh       string            `json:"refresh"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Time          map[string]string `json:"time"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaPanel struct {
+	ID         int             `json:"id"`
+	Type       string          `json:"type"`
+	Title      string          `json:"title"`
+	Datasource string          `json:"datasource,omitempty"`
+	GridPos    grafanaGridPos  `json:"gridPos"`
+	Targets    []grafanaTarget `json:"targets,omitempty"`
+	YAxes      []grafanaYAxis  `json:"yaxes,omitempty"`
+}
+
+type grafanaGridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type grafanaTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+type grafanaYAxis struct {
+	Format string `json:"format"`
+	Show   bool   `json:"show"`
+}
+
+// genDashboard has a row for each component, with cpu, memory and restarts of its pods,
+// and http requests if the component has metrics scraped by prometheus
+func genDashboard(uid string, app *kappV1Alpha1.Application, spec *kappV1Alpha1.ApplicationSpec) *grafanaDashboard {
+	dashboard := &grafanaDashboard{
+		UID:           uid,
+		Title:         fmt.Sprintf("%s / %s", app.Namespace, app.Name),
+		Tags:          []string{"kapp"},
+		Refresh:       "30s",
+		SchemaVersion: 20,
+		Time:          map[string]string{"from": "now-1h", "to": "now"},
+		Panels:        []grafanaPanel{},
+	}
+
+	id := 0
+	y := 0
+	addPanel := func(panel grafanaPanel) {
+		id++
+		panel.ID = id
+		dashboard.Panels = append(dashboard.Panels, panel)
+	}
+
+	for _, component := range spec.Components {
+		addPanel(grafanaPanel{
+			Type:    "row",
+			Title:   component.Name,
+			GridPos: grafanaGridPos{X: 0, Y: y, W: 24, H: 1},
+		})
+		y++
+
+		graphs := []struct {
+			title, expr, legend, format string
+		}{
+			{
+				"CPU",
+				fmt.Sprintf(`sum by (pod) (rate(container_cpu_usage_seconds_total%s[5m]) %s)`,
+					containersSelector(app.Namespace), joinComponentPods(app, component.Name)),
+				"{{pod}}",
+				"short",
+			},
+			{
+				"Memory",
+				fmt.Sprintf(`sum by (pod) (container_memory_working_set_bytes%s %s)`,
+					containersSelector(app.Namespace), joinComponentPods(app, component.Name)),
+				"{{pod}}",
+				"bytes",
+			},
+			{
+				"Restarts",
+				fmt.Sprintf(`sum by (pod) (increase(kube_pod_container_status_restarts_total{namespace=%s}[1h]) %s)`,
+					strconv.Quote(app.Namespace), joinComponentPods(app, component.Name)),
+				"{{pod}}",
+				"short",
+			},
+		}
+
+		if component.Metrics != nil {
+			graphs = append(graphs, struct {
+				title, expr, legend, format string
+			}{
+				"HTTP requests",
+				fmt.Sprintf(`sum by (code) (rate(http_requests_total{namespace=%s,service=%s}[5m]))`,
+					strconv.Quote(app.Namespace), strconv.Quote(getServiceName(app.Name, component.Name))),
+				"{{code}}",
+				"reqps",
+			})
+		}
+
+		width := 24 / len(graphs)
+		for i, graph := range graphs {
+			addPanel(grafanaPanel{
+				Type:       "graph",
+				Title:      graph.title,
+				Datasource: grafanaDatasource,
+				GridPos:    grafanaGridPos{X: i * width, Y: y, W: width, H: 8},
+				Targets:    []grafanaTarget{{Expr: graph.expr, LegendFormat: graph.legend, RefID: "A"}},
+				YAxes:      []grafanaYAxis{{Format: graph.format, Show: true}, {Format: "short", Show: false}},
+			})
+		}
+		y += 8
+	}
+
+	return dashboard
+}
+
+// cadvisor metrics of the containers in the namespace, without the pause containers
+func containersSelector(namespace string) string {
+	return fmt.Sprintf(`{job="kubelet",namespace=%s,container!="",container!="POD"}`, strconv.Quote(namespace))
+}
+
+// keeps the series of the pods of the component, by the pod labels of kube-state-metrics
+func joinComponentPods(app *kappV1Alpha1.Application, componentName string) string {
+	return fmt.Sprintf(
+		`* on (namespace, pod) group_left() max by (namespace, pod) (kube_pod_labels{namespace=%s,label_kapp_application=%s,label_kapp_component=%s})`,
+		strconv.Quote(app.Namespace), strconv.Quote(app.Name), strconv.Quote(componentName))
+}
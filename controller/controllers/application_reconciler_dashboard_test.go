@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileDashboard(t *testing.T) {
+	ctx := context.Background()
+
+	app := &corev1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kapp-test", Name: "shop", UID: "uid"},
+		Spec: corev1alpha1.ApplicationSpec{
+			Components: []corev1alpha1.ComponentSpec{
+				{
+					Name:    "web",
+					Ports:   []corev1alpha1.Port{{Name: "http", ContainerPort: 8080}},
+					Metrics: &corev1alpha1.ComponentMetrics{Port: "http"},
+				},
+				{
+					Name: "worker",
+				},
+			},
+		},
+	}
+
+	task := newFakeApplicationReconcilerTask(app)
+	assert.Nil(t, task.reconcileDashboard())
+
+	uid := corev1alpha1.GrafanaDashboardUID("kapp-test", "shop")
+	getDashboard := func() *grafanaDashboard {
+		var cm coreV1.ConfigMap
+		assert.Nil(t, task.reconciler.Get(ctx, types.NamespacedName{Namespace: "kapp-test", Name: getDashboardConfigMapName("shop")}, &cm))
+		assert.Equal(t, "1", cm.Labels[corev1alpha1.GrafanaDashboardLabel])
+		assert.Equal(t, "shop", metav1.GetControllerOf(&cm).Name)
+
+		var dashboard grafanaDashboard
+		assert.Nil(t, json.Unmarshal([]byte(cm.Data[uid+".json"]), &dashboard))
+		return &dashboard
+	}
+
+	dashboard := getDashboard()
+	assert.Equal(t, uid, dashboard.UID)
+	// a row and cpu, memory, restarts and http requests of web, a row and 3 graphs of worker
+	assert.Len(t, dashboard.Panels, 9)
+	assert.Equal(t, "web", dashboard.Panels[0].Title)
+	assert.Equal(t, "HTTP requests", dashboard.Panels[4].Title)
+	assert.Equal(t, "worker", dashboard.Panels[5].Title)
+
+	// updated with the components
+	app.Spec.Components = app.Spec.Components[:1]
+	app.Spec.Components[0].Metrics = nil
+	assert.Nil(t, task.reconcileDashboard())
+
+	dashboard = getDashboard()
+	assert.Len(t, dashboard.Panels, 4)
+	assert.Equal(t, "Restarts", dashboard.Panels[3].Title)
+}
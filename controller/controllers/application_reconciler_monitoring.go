@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	kappV1Alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// the dependency which installs the prometheus operator and the CRDs of monitoring.coreos.com
+const dependencyTypeKubePrometheus = "kube-prometheus"
+
+// isDependencyTypeRunning checks if a Dependency of the type is running
+func isDependencyTypeRunning(ctx context.Context, c client.Reader, depType string) (bool, error) {
+	var list kappV1Alpha1.DependencyList
+	if err := c.List(ctx, &list); err != nil {
+		return false, err
+	}
+
+	for _, dep := range list.Items {
+		if dep.Spec.Type == depType && dep.Status.Status == kappV1Alpha1.DependencyStatusRunning {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// reconcileMonitoring keeps the service monitors, the alerts and the grafana dashboard of the application.
+// The CRDs of monitoring.coreos.com and grafana are installed by kube-prometheus, nothing is done until it's running.
+func (act *applicationReconcilerTask) reconcileMonitoring() error {
+	running, err := isDependencyTypeRunning(act.ctx, act.reconciler, dependencyTypeKubePrometheus)
+	if err != nil || !running {
+		return err
+	}
+
+	if err := act.reconcileServiceMonitors(); err != nil {
+		return err
+	}
+
+	if err := act.reconcilePrometheusRule(); err != nil {
+		return err
+	}
+
+	return act.reconcileDashboard()
+}
+
+// reconcileServiceMonitors makes prometheus scrape the components with metrics, through their services.
+func (act *applicationReconcilerTask) reconcileServiceMonitors() error {
+	ctx := act.ctx
+	log := act.log
+
+	var list monitoringv1.ServiceMonitorList
+	if err := act.reconciler.Reader.List(
+		ctx,
+		&list,
+		client.InNamespace(act.app.Namespace),
+		client.MatchingLabels{"kapp-application": act.app.Name},
+	); err != nil {
+		log.Error(err, "unable to list child service monitors")
+		return err
+	}
+
+	existing := make(map[string]*monitoringv1.ServiceMonitor, len(list.Items))
+	for _, sm := range list.Items {
+		existing[sm.Name] = sm
+	}
+
+	for i := range act.spec.Components {
+		component := &act.spec.Components[i]
+		desired := desiredServiceMonitor(act.app, component)
+		if desired == nil {
+			continue
+		}
+
+		sm, exist := existing[desired.Name]
+		delete(existing, desired.Name)
+
+		if !exist {
+			if err := ctrl.SetControllerReference(act.app, desired, act.reconciler.Scheme); err != nil {
+				return err
+			}
+
+			if err := act.reconciler.Create(ctx, desired); err != nil {
+				log.Error(err, "unable to create ServiceMonitor for Component", "component", component.Name)
+				return err
+			}
+
+			continue
+		}
+
+		sm.Labels = desired.Labels
+		sm.Spec = desired.Spec
+
+		if err := act.reconciler.Update(ctx, sm); err != nil {
+			log.Error(err, "unable to update ServiceMonitor for Component", "component", component.Name)
+			return err
+		}
+	}
+
+	// components removed, or without metrics
+	for _, sm := range existing {
+		if err := act.reconciler.Delete(ctx, sm); err != nil {
+			log.Error(err, "unable to delete ServiceMonitor", "serviceMonitor", sm.Name)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// desiredServiceMonitor selects the service of the component, and scrapes the port of metrics.
+// nil if the component has no metrics.
+func desiredServiceMonitor(app *kappV1Alpha1.Application, component *kappV1Alpha1.ComponentSpec) *monitoringv1.ServiceMonitor {
+	if component.Metrics == nil || len(component.Ports) == 0 {
+		return nil
+	}
+
+	labels := getComponentLabels(app.Name, component.Name)
+
+	return &monitoringv1.ServiceMonitor{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      getServiceName(app.Name, component.Name),
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Spec: monitoringv1.ServiceMonitorSpec{
+			Selector: metaV1.LabelSelector{MatchLabels: labels},
+			Endpoints: []monitoringv1.Endpoint{
+				{
+					Port:     component.Metrics.Port,
+					Path:     component.Metrics.GetPath(),
+					Interval: component.Metrics.Interval,
+				},
+			},
+		},
+	}
+}
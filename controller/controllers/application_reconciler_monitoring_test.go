@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeApplicationReconcilerTask(app *corev1alpha1.Application) *applicationReconcilerTask {
+	sch := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(sch)
+	_ = corev1alpha1.AddToScheme(sch)
+	_ = monitoringv1.AddToScheme(sch)
+
+	c := fake.NewFakeClientWithScheme(sch)
+	r := &ApplicationReconciler{Client: c, Reader: c, Log: ctrl.Log.WithName("test"), Scheme: sch}
+
+	task := newApplicationReconcilerTask(r, app, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: app.Namespace, Name: app.Name}}, r.Log)
+	task.spec = &app.Spec
+
+	return task
+}
+
+func TestReconcileServiceMonitors(t *testing.T) {
+	ctx := context.Background()
+
+	app := &corev1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kapp-test", Name: "shop", UID: "uid"},
+		Spec: corev1alpha1.ApplicationSpec{
+			Components: []corev1alpha1.ComponentSpec{
+				{
+					Name:    "web",
+					Ports:   []corev1alpha1.Port{{Name: "http", ContainerPort: 8080}},
+					Metrics: &corev1alpha1.ComponentMetrics{Port: "http", Interval: "15s"},
+				},
+				{
+					Name:  "worker",
+					Ports: []corev1alpha1.Port{{Name: "http", ContainerPort: 8080}},
+				},
+			},
+		},
+	}
+
+	task := newFakeApplicationReconcilerTask(app)
+	smName := types.NamespacedName{Namespace: "kapp-test", Name: getServiceName("shop", "web")}
+
+	// nothing without kube-prometheus
+	assert.Nil(t, task.reconcileMonitoring())
+	assert.NotNil(t, task.reconciler.Get(ctx, smName, &monitoringv1.ServiceMonitor{}))
+
+	dep := corev1alpha1.Dependency{
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus"},
+		Spec:       corev1alpha1.DependencySpec{Type: dependencyTypeKubePrometheus},
+		Status:     corev1alpha1.DependencyStatus{Status: corev1alpha1.DependencyStatusRunning},
+	}
+	assert.Nil(t, task.reconciler.Create(ctx, &dep))
+
+	assert.Nil(t, task.reconcileMonitoring())
+
+	sm := monitoringv1.ServiceMonitor{}
+	assert.Nil(t, task.reconciler.Get(ctx, smName, &sm))
+	assert.Equal(t, getComponentLabels("shop", "web"), sm.Spec.Selector.MatchLabels)
+	assert.Equal(t, "http", sm.Spec.Endpoints[0].Port)
+	assert.Equal(t, "/metrics", sm.Spec.Endpoints[0].Path)
+	assert.Equal(t, "15s", sm.Spec.Endpoints[0].Interval)
+	assert.Equal(t, "shop", metav1.GetControllerOf(&sm).Name)
+
+	var list monitoringv1.ServiceMonitorList
+	assert.Nil(t, task.reconciler.List(ctx, &list))
+	assert.Len(t, list.Items, 1)
+
+	// removed with the metrics of the component
+	app.Spec.Components[0].Metrics = nil
+	assert.Nil(t, task.reconcileMonitoring())
+	assert.NotNil(t, task.reconciler.Get(ctx, smName, &monitoringv1.ServiceMonitor{}))
+}
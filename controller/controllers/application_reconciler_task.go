@@ -13,13 +13,13 @@ import (
 	batchV1Beta1 "k8s.io/api/batch/v1beta1"
 	coreV1 "k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"strings"
 )
 
 // There will be a new Task instance for each reconciliation
@@ -30,6 +30,9 @@ type applicationReconcilerTask struct {
 	req        ctrl.Request
 	log        logr.Logger
 
+	// app spec with the selected overlay applied
+	spec *kappV1Alpha1.ApplicationSpec
+
 	deployments []appsV1.Deployment
 	cronjobs    []batchV1Beta1.CronJob
 	services    []coreV1.Service
@@ -47,6 +50,7 @@ func newApplicationReconcilerTask(
 		app,
 		req,
 		log,
+		nil,
 		[]appsV1.Deployment{},
 		[]batchV1Beta1.CronJob{},
 		[]coreV1.Service{},
@@ -64,7 +68,14 @@ func (act *applicationReconcilerTask) Run() (err error) {
 		return err
 	}
 
-	if !act.app.Spec.IsActive {
+	act.spec, err = kappV1Alpha1.ResolveApplicationSpec(act.app.Spec, act.app.Spec.Overlay)
+
+	if err != nil {
+		log.Error(err, "unable to resolve overlay")
+		return err
+	}
+
+	if !act.spec.IsActive {
 		return act.deleteExternalResources()
 	}
 
@@ -102,12 +113,19 @@ func (act *applicationReconcilerTask) Run() (err error) {
 		return err
 	}
 
+	err = act.reconcileMonitoring()
+
+	if err != nil {
+		log.Error(err, "unable to construct monitoring")
+		return err
+	}
+
 	return nil
 }
 
 func (act *applicationReconcilerTask) reconcileComponents() (err error) {
-	for i := range act.app.Spec.Components {
-		component := act.app.Spec.Components[i]
+	for i := range act.spec.Components {
+		component := act.spec.Components[i]
 
 		if err = act.reconcileComponent(&component); err != nil {
 			return err
@@ -226,9 +244,9 @@ func (act *applicationReconcilerTask) generateTemplate(component *kappV1Alpha1.C
 	}
 
 	// set image secret
-	if act.app.Spec.ImagePullSecretName != "" {
+	if act.spec.ImagePullSecretName != "" {
 		secs := []coreV1.LocalObjectReference{
-			{Name: act.app.Spec.ImagePullSecretName},
+			{Name: act.spec.ImagePullSecretName},
 		}
 		template.Spec.ImagePullSecrets = secs
 	}
@@ -247,6 +265,11 @@ func (act *applicationReconcilerTask) generateTemplate(component *kappV1Alpha1.C
 			if err != nil {
 				continue
 			}
+
+			value, err = kappV1Alpha1.FormatExternalEnvValue(env, value)
+			if err != nil {
+				return nil, err
+			}
 		} else if env.Type == kappV1Alpha1.EnvVarTypeLinked {
 			value, err = act.getValueOfLinkedEnv(env)
 			if err != nil {
@@ -490,7 +513,7 @@ func (act *applicationReconcilerTask) reconcileServices() (err error) {
 	ctx := act.ctx
 	log := act.log
 
-	for _, component := range act.app.Spec.Components {
+	for _, component := range act.spec.Components {
 		// ports
 		service := act.getService(component.Name)
 
@@ -879,7 +902,7 @@ func (act *applicationReconcilerTask) FindService(componentName string) *coreV1.
 }
 
 func (act *applicationReconcilerTask) FindShareEnvValue(name string) (string, error) {
-	for _, env := range act.app.Spec.SharedEnv {
+	for _, env := range act.spec.SharedEnv {
 		if env.Name != name {
 			continue
 		}
@@ -921,19 +944,29 @@ func (act *applicationReconcilerTask) getValueOfLinkedEnv(env kappV1Alpha1.EnvVa
 		return env.Value, nil
 	}
 
-	parts := strings.Split(env.Value, "/")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("wrong componentPort config %s, format error", env.Value)
+	target, err := kappV1Alpha1.ParseLinkedEnvValue(env.Value)
+	if err != nil {
+		return "", err
+	}
+
+	var service *coreV1.Service
+
+	if target.IsCrossApplication() && (target.Namespace != act.app.Namespace || target.Application != act.app.Name) {
+		service, err = act.getServiceOfOtherApplication(target.Namespace, target.Application, target.Component)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		service = act.FindService(target.Component)
 	}
 
-	service := act.FindService(parts[0])
 	if service == nil {
 		return "", fmt.Errorf("wrong componentPort config %s, service not exist", env.Value)
 	}
 
 	var port int32
 	for _, servicePort := range service.Spec.Ports {
-		if servicePort.Name == parts[1] {
+		if servicePort.Name == target.Port {
 			port = servicePort.Port
 		}
 	}
@@ -942,11 +975,28 @@ func (act *applicationReconcilerTask) getValueOfLinkedEnv(env kappV1Alpha1.EnvVa
 		return "", fmt.Errorf("wrong componentPort config %s, port not exist", env.Value)
 	}
 
-	// svc.ns:port
-	value := fmt.Sprintf("%s.%s:%d", service.Name, act.app.Namespace, port)
+	// svc.ns:port by default, or in the format or template of env
+	return kappV1Alpha1.FormatLinkedEnvValue(env, kappV1Alpha1.NewLinkedEnvTemplateVariables(service.Name, service.Namespace, port))
+}
+
+// returns nil if the service doesn't exist
+func (act *applicationReconcilerTask) getServiceOfOtherApplication(namespace, appName, componentName string) (*coreV1.Service, error) {
+	var service coreV1.Service
+
+	err := act.reconciler.Get(act.ctx, types.NamespacedName{
+		Namespace: namespace,
+		Name:      getServiceName(appName, componentName),
+	}, &service)
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
 
-	// <prefix>value<suffix>
-	return fmt.Sprintf("%s%s%s", env.Prefix, value, env.Suffix), nil
+	return &service, nil
 }
 
 func getDeploymentName(appName, componentName string) string {
@@ -335,6 +335,43 @@ var _ = Describe("Application Envs", func() {
 				return len(mainContainer.Env) == 0
 			}, timeout, interval).Should(Equal(true))
 		})
+
+		It("Linked across applications", func() {
+			By("Create producer application")
+			producer := generateApplication()
+			createApplication(producer)
+
+			By("Create consumer application")
+			consumer := generateApplication()
+			consumer.Spec.Components[0].Env = []v1alpha1.EnvVar{
+				{
+					Name:  "producer",
+					Value: fmt.Sprintf("%s/%s/test/test", producer.Namespace, producer.Name),
+					Type:  v1alpha1.EnvVarTypeLinked,
+				},
+			}
+			createApplication(consumer)
+
+			var deployments []v1.Deployment
+			Eventually(func() bool {
+				deployments = getApplicationDeployments(consumer)
+				return len(deployments) == 1 &&
+					len(deployments[0].Spec.Template.Spec.Containers[0].Env) == 1
+			}, timeout, interval).Should(Equal(true))
+
+			serviceHost := fmt.Sprintf("%s.%s", getServiceName(producer.Name, "test"), producer.Namespace)
+			Expect(deployments[0].Spec.Template.Spec.Containers[0].Env[0].Value).Should(Equal(serviceHost + ":80"))
+
+			By("Update producer service port should update consumer env")
+			reloadApplication(producer)
+			producer.Spec.Components[0].Ports[0].ServicePort = 81
+			updateApplication(producer)
+			Eventually(func() bool {
+				deployments = getApplicationDeployments(consumer)
+				return len(deployments) == 1 &&
+					deployments[0].Spec.Template.Spec.Containers[0].Env[0].Value == serviceHost+":81"
+			}, timeout, interval).Should(Equal(true))
+		})
 	})
 
 	Context("Ports", func() {
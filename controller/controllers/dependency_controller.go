@@ -17,9 +17,10 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"github.com/go-logr/logr"
 	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/kapp-staging/kapp/util"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -28,6 +29,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+var dependencyFinalizerName = "dependency.finalizers.kapp.dev"
+
 // DependencyReconciler reconciles a Dependency object
 type DependencyReconciler struct {
 	client.Client
@@ -44,6 +47,7 @@ type DependencyReconciler struct {
 // +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=*
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=*,verbs=*
 // +kubebuilder:rbac:groups=monitoring.coreos.com,resources=*,verbs=*
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=*,verbs=*
 //
 // +kubebuilder:rbac:groups=apps,resources=*,verbs=*
 // +kubebuilder:rbac:groups="",resources=*,verbs=*
@@ -62,29 +66,79 @@ func (r *DependencyReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	switch dep.Spec.Type {
-	case "kong":
-		if err := r.reconcileKong(ctx, &dep); err != nil {
-			return returnRstForError(err)
+	driver, err := r.getDriver(dep.Spec.Type)
+	if err != nil {
+		log.Error(err, "ignored")
+	}
+
+	// the status is updated in memory during the reconciliation, and saved once in the end
+	original := dep.Status.DeepCopy()
+
+	shouldFinish, err := r.handleDelete(ctx, driver, &dep)
+
+	// the update of a migrated dependency triggers another reconciliation
+	migrated := false
+	if err == nil && !shouldFinish && driver != nil {
+		migrated, err = r.migrateSecretConfig(ctx, &dep)
+	}
+
+	if err == nil && !shouldFinish && !migrated && driver != nil {
+		err = r.reconcileWithDriver(ctx, driver, &dep)
+	}
+
+	if statusErr := r.updateStatusIfChanged(ctx, original, &dep); statusErr != nil && err == nil {
+		err = statusErr
+	}
+
+	if err != nil || shouldFinish || driver == nil {
+		return returnRstForError(err)
+	}
+
+	log.Info("finish reconciling dep...")
+	// come back later to correct drift
+	return ctrl.Result{RequeueAfter: driftCheckInterval}, nil
+}
+
+// uninstall the dependency before it's deleted, driver is nil for unknown types
+func (r *DependencyReconciler) handleDelete(ctx context.Context, driver DependencyDriver, dep *corev1alpha1.Dependency) (shouldFinishReconcilation bool, err error) {
+	if dep.ObjectMeta.DeletionTimestamp.IsZero() {
+		if driver != nil && !util.ContainsString(dep.ObjectMeta.Finalizers, dependencyFinalizerName) {
+			dep.ObjectMeta.Finalizers = append(dep.ObjectMeta.Finalizers, dependencyFinalizerName)
+			if err := r.Update(ctx, dep); err != nil {
+				return true, err
+			}
+			r.Log.Info("add finalizer", "dependency", dep.Name)
 		}
-	case "cert-manager":
-		if err := r.reconcileCertManager(ctx, &dep); err != nil {
-			return returnRstForError(err)
+
+		return false, nil
+	}
+
+	if !util.ContainsString(dep.ObjectMeta.Finalizers, dependencyFinalizerName) {
+		return true, nil
+	}
+
+	if driver != nil {
+		if err := r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusUninstalling); err != nil {
+			return true, err
 		}
-	case "kube-prometheus":
-		if err := r.reconcileKubePrometheus(ctx, &dep); err != nil {
-			return returnRstForError(err)
+		setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionReady, corev1.ConditionFalse, "Uninstalling", "")
+
+		version := dep.Status.InstalledVersion
+		if version == "" {
+			version = desiredDependencyVersion(dep)
 		}
-	case "log":
-		if err := r.reconcileELK(ctx, &dep); err != nil {
-			return returnRstForError(err)
+
+		if err := driver.Uninstall(ctx, dep, version); err != nil {
+			return true, err
 		}
-	default:
-		log.Error(fmt.Errorf("unkonwn dependency: %s", dep.Spec.Type), "ignored")
 	}
 
-	log.Info("finish reconciling dep...")
-	return ctrl.Result{}, nil
+	dep.ObjectMeta.Finalizers = util.RemoveString(dep.ObjectMeta.Finalizers, dependencyFinalizerName)
+	if err := r.Update(ctx, dep); err != nil {
+		return true, err
+	}
+
+	return true, nil
 }
 
 func (r *DependencyReconciler) SetupWithManager(mgr ctrl.Manager) error {
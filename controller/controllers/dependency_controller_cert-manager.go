@@ -2,191 +2,397 @@ package controllers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"github.com/jetstack/cert-manager/pkg/apis/acme/v1alpha2"
 	cmv1alpha2 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
 	cmmetav1 "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
 	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"math/big"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
 )
 
-func (r *DependencyReconciler) reconcileCertManager(ctx context.Context, dep *corev1alpha1.Dependency) error {
-	// check if cert-manger installed
-	status, err := r.getDependencyInstallStatus("cert-manager",
-		[]string{"cert-manager", "cert-manager-webhook", "cert-manager-cainjector"},
-		nil)
+func init() {
+	registerDependencyDriver("cert-manager", func(r *DependencyReconciler) DependencyDriver {
+		return certManagerDriver{bundledDriver{
+			DependencyReconciler: r,
+			namespace:            "cert-manager",
+			versions:             map[string][]string{"1.0.0": {"cert-manager-1.0.0.yaml"}},
+			dpNames:              []string{"cert-manager", "cert-manager-webhook", "cert-manager-cainjector"},
+		}}
+	})
+}
+
+type certManagerDriver struct {
+	bundledDriver
+}
+
+func (certManagerDriver) ConfigSchema() []corev1alpha1.DependencyConfigField {
+	return configSchemaOf("cert-manager")
+}
+
+// the keypair of the CA is kept if dep.Spec.KeepData is set,
+// so the certificates signed by it are still trusted when cert-manager is installed again
+func (r certManagerDriver) Uninstall(ctx context.Context, dep *corev1alpha1.Dependency, version string) error {
+	if dep.Spec.KeepData {
+		if err := r.releaseCAKeyPair(ctx, dep); err != nil {
+			return err
+		}
+	}
+
+	return r.bundledDriver.Uninstall(ctx, dep, version)
+}
+
+func (r certManagerDriver) Configure(ctx context.Context, dep *corev1alpha1.Dependency, rawConfig map[string]string) error {
+	config, err := corev1alpha1.ParseCertManagerConfig(rawConfig)
 	if err != nil {
 		return err
 	}
 
-	r.Log.Info("cert-manager install status",
-		"status", status)
+	return r.reconcileClusterIssuer(ctx, dep, config)
+}
+
+// reconcileClusterIssuer makes sure there is a ClusterIssuer named after the dependency, of the tlsType in config
+// - selfSigned, each certificate is signed by its own private key
+// - ca, certificates are signed by a CA, the keypair of the CA is generated and kept in a Secret
+// - acme, certificates are issued by let's encrypt
+func (r *DependencyReconciler) reconcileClusterIssuer(ctx context.Context, dep *corev1alpha1.Dependency, config *corev1alpha1.CertManagerConfig) error {
+	var issuerConfig cmv1alpha2.IssuerConfig
+
+	switch config.TLSType {
+	case "selfSigned":
+		issuerConfig.SelfSigned = &cmv1alpha2.SelfSignedIssuer{}
+
+	case "ca":
+		secName, err := r.reconcileCAKeyPair(ctx, dep, config.CACommonName)
+		if err != nil {
+			return err
+		}
+
+		issuerConfig.CA = &cmv1alpha2.CAIssuer{SecretName: secName}
+
+	case "acme":
+		acme, err := r.getACMEIssuer(ctx, dep, config.ACME)
+		if err != nil {
+			return err
+		}
+
+		issuerConfig.ACME = acme
+
+	default:
+		return fmt.Errorf("unknown tlsType: %s", config.TLSType)
+	}
+
+	clusterIssuer := cmv1alpha2.ClusterIssuer{}
+	if err := r.Get(ctx, client.ObjectKey{Name: dep.Name}, &clusterIssuer); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
 
-	switch status {
-	case NotInstalled:
-		//if err := r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusInstalling); err != nil {
-		//	return err
-		//}
+		clusterIssuer := cmv1alpha2.ClusterIssuer{
+			TypeMeta: v1.TypeMeta{
+				APIVersion: cmv1alpha2.SchemeGroupVersion.String(),
+				Kind:       "ClusterIssuer",
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Name: dep.Name,
+			},
+			Spec: cmv1alpha2.IssuerSpec{
+				IssuerConfig: issuerConfig,
+			},
+		}
 
-		// try installing first
-		if err := r.reconcileExternalController(ctx, "cert-manager-1.0.0.yaml"); err != nil {
+		if err := ctrl.SetControllerReference(dep, &clusterIssuer, r.Scheme); err != nil {
 			return err
 		}
 
-		return retryLaterErr
-	case Installing:
-		// wait
-		r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusInstalling)
-		return retryLaterErr
-	case InstallFailed:
-		// failed, nothing can be done
-		if err := r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusInstallFailed); err != nil {
+		r.Log.Info("creating clusterIssuer", "name", dep.Name)
+		if err := r.Create(ctx, &clusterIssuer); err != nil {
+			r.Log.Error(err, "fail create clusterIssuer")
 			return err
 		}
+
 		return nil
-	case Installed:
-		r.Log.Info("cert-manager installed")
-		//r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusInstalled)
-		// go on to do more
 	}
 
-	// check if config for ClusterIssuer is provided
-	if dep.Spec.Config == nil {
-		r.Log.Error(fmt.Errorf("must porvide config for cert-manager"), "")
+	if apiequality.Semantic.DeepEqual(clusterIssuer.Spec.IssuerConfig, issuerConfig) {
 		return nil
 	}
 
-	r.Log.Info("cert-m detail", "dep", dep)
+	r.Log.Info("updating clusterIssuer", "name", dep.Name)
+	clusterIssuer.Spec.IssuerConfig = issuerConfig
 
-	return r.reconcileClusterIssuer(ctx, dep)
+	return r.Update(ctx, &clusterIssuer)
 }
 
-// support only one tlsType: acme
-// todo support selfsign for local test
-func (r *DependencyReconciler) reconcileClusterIssuer(ctx context.Context, dep *corev1alpha1.Dependency) error {
-	config := dep.Spec.Config
-
-	switch config["tlsType"] {
-	case "selfSigned":
-		//todo
-		return fmt.Errorf("tlsType not supported yet: selfSigned")
+func (r *DependencyReconciler) getACMEIssuer(ctx context.Context, dep *corev1alpha1.Dependency, config *corev1alpha1.CertManagerACMEConfig) (*v1alpha2.ACMEIssuer, error) {
+	if credentials := config.Credentials(); credentials != nil {
+		if err := r.reconcileSecretForClusterIssuer(ctx, dep, credentials); err != nil {
+			return nil, err
+		}
+	}
 
-	case "acme":
-		provider := config["challengeProvider"]
-		if provider != "cloudflare" {
-			return fmt.Errorf("acme provider not supported yet: %s", provider)
+	// the http01 challenge is served by the ingress dependency, unless a class is set
+	if config.Challenge == corev1alpha1.ACMEChallengeHTTP01 && config.IngressClass == "" {
+		ingressClass, err := r.defaultIngressClass(ctx)
+		if err != nil {
+			return nil, err
 		}
 
-		email := config["challengeEmail"]
-		plainSecret := config["challengeSecret"]
+		withClass := *config
+		withClass.IngressClass = ingressClass
+		config = &withClass
+	}
 
-		secKey := "sec-content"
+	return &v1alpha2.ACMEIssuer{
+		Email:  config.Email,
+		Server: config.Server,
+		PrivateKey: cmmetav1.SecretKeySelector{
+			LocalObjectReference: cmmetav1.LocalObjectReference{
+				Name: getPrvKeyNameForClusterIssuer(dep),
+			},
+		},
+		Solvers: []v1alpha2.ACMEChallengeSolver{
+			acmeChallengeSolver(config, getSecNameForClusterIssuer(dep)),
+		},
+	}, nil
+}
 
-		sec := corev1.Secret{}
-		if err := r.Get(ctx, types.NamespacedName{
-			Namespace: "cert-manager",
-			Name:      getSecNameForClusterIssuer(dep),
-		}, &sec); err != nil {
-			if !errors.IsNotFound(err) {
-				return err
-			}
-
-			sec := corev1.Secret{
-				TypeMeta: v1.TypeMeta{
-					APIVersion: corev1.SchemeGroupVersion.String(),
-					Kind:       "Secret",
-				},
-				ObjectMeta: v1.ObjectMeta{
-					Namespace: "cert-manager",
-					Name:      getSecNameForClusterIssuer(dep),
-				},
-				StringData: map[string]string{
-					secKey: plainSecret,
+// ref: https://cert-manager.io/docs/configuration/acme/
+func acmeChallengeSolver(config *corev1alpha1.CertManagerACMEConfig, secName string) v1alpha2.ACMEChallengeSolver {
+	secretRef := func(key string) cmmetav1.SecretKeySelector {
+		return cmmetav1.SecretKeySelector{
+			LocalObjectReference: cmmetav1.LocalObjectReference{Name: secName},
+			Key:                  key,
+		}
+	}
+
+	if config.Challenge == corev1alpha1.ACMEChallengeHTTP01 {
+		ingressClass := config.IngressClass
+		return v1alpha2.ACMEChallengeSolver{
+			HTTP01: &v1alpha2.ACMEChallengeSolverHTTP01{
+				Ingress: &v1alpha2.ACMEChallengeSolverHTTP01Ingress{
+					Class: &ingressClass,
 				},
-				Type: "Opaque",
-			}
+			},
+		}
+	}
 
-			if err := ctrl.SetControllerReference(dep, &sec, r.Scheme); err != nil {
-				return err
-			}
+	dns01 := &v1alpha2.ACMEChallengeSolverDNS01{}
 
-			if err := r.Create(ctx, &sec); err != nil {
-				return err
-			}
+	switch {
+	case config.Cloudflare != nil:
+		apiKey := secretRef(corev1alpha1.CloudflareAPIKeySecretKey)
+		dns01.Cloudflare = &v1alpha2.ACMEIssuerDNS01ProviderCloudflare{
+			Email:  config.Cloudflare.Email,
+			APIKey: &apiKey,
+		}
+	case config.Route53 != nil:
+		dns01.Route53 = &v1alpha2.ACMEIssuerDNS01ProviderRoute53{
+			Region:          config.Route53.Region,
+			AccessKeyID:     config.Route53.AccessKeyID,
+			SecretAccessKey: secretRef(corev1alpha1.Route53SecretAccessKeySecretKey),
+			HostedZoneID:    config.Route53.HostedZoneID,
+			Role:            config.Route53.Role,
+		}
+	case config.CloudDNS != nil:
+		serviceAccount := secretRef(corev1alpha1.CloudDNSServiceAccountSecretKey)
+		dns01.CloudDNS = &v1alpha2.ACMEIssuerDNS01ProviderCloudDNS{
+			Project:        config.CloudDNS.Project,
+			ServiceAccount: &serviceAccount,
+		}
+	case config.DigitalOcean != nil:
+		dns01.DigitalOcean = &v1alpha2.ACMEIssuerDNS01ProviderDigitalOcean{
+			Token: secretRef(corev1alpha1.DigitalOceanTokenSecretKey),
+		}
+	case config.RFC2136 != nil:
+		dns01.RFC2136 = &v1alpha2.ACMEIssuerDNS01ProviderRFC2136{
+			Nameserver:    config.RFC2136.Nameserver,
+			TSIGKeyName:   config.RFC2136.TSIGKeyName,
+			TSIGAlgorithm: config.RFC2136.TSIGAlgorithm,
+		}
 
-			r.Log.Info("secret created")
+		if config.RFC2136.TSIGSecret != "" {
+			dns01.RFC2136.TSIGSecret = secretRef(corev1alpha1.RFC2136TSIGSecretSecretKey)
 		}
-		// todo update
+	}
 
-		// ref: https://cert-manager.io/docs/configuration/acme/dns01/cloudflare/
-		clusterIssuer := cmv1alpha2.ClusterIssuer{}
-		if err := r.Get(ctx, client.ObjectKey{Name: dep.Name}, &clusterIssuer); err != nil {
-			if !errors.IsNotFound(err) {
-				return err
-			}
+	return v1alpha2.ACMEChallengeSolver{DNS01: dns01}
+}
 
-			clusterIssuer := cmv1alpha2.ClusterIssuer{
-				TypeMeta: v1.TypeMeta{
-					APIVersion: cmv1alpha2.SchemeGroupVersion.String(),
-					Kind:       "ClusterIssuer",
-				},
-				ObjectMeta: v1.ObjectMeta{
-					//Namespace: "cert-manager", //?
-					Name: dep.Name,
-				},
-				Spec: cmv1alpha2.IssuerSpec{
-					IssuerConfig: cmv1alpha2.IssuerConfig{
-						ACME: &v1alpha2.ACMEIssuer{
-							Email: email,
-							//Server: "https://acme-staging-v02.api.letsencrypt.org/directory",
-							Server: "https://acme-v02.api.letsencrypt.org/directory",
-							PrivateKey: cmmetav1.SecretKeySelector{
-								LocalObjectReference: cmmetav1.LocalObjectReference{
-									Name: getPrvKeyNameForClusterIssuer(dep),
-								},
-								//Key: secKey,
-							},
-							Solvers: []v1alpha2.ACMEChallengeSolver{
-								{
-									DNS01: &v1alpha2.ACMEChallengeSolverDNS01{
-										Cloudflare: &v1alpha2.ACMEIssuerDNS01ProviderCloudflare{
-											Email: email,
-											APIKey: &cmmetav1.SecretKeySelector{
-												LocalObjectReference: cmmetav1.LocalObjectReference{
-													Name: getSecNameForClusterIssuer(dep),
-												},
-												Key: secKey,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			}
+// credentials of the dns01 provider are kept in a Secret owned by the dependency,
+// in the cert-manager namespace, which is where a ClusterIssuer reads secrets from
+func (r *DependencyReconciler) reconcileSecretForClusterIssuer(ctx context.Context, dep *corev1alpha1.Dependency, credentials map[string]string) error {
+	data := make(map[string][]byte, len(credentials))
+	for k, v := range credentials {
+		data[k] = []byte(v)
+	}
 
-			if err := ctrl.SetControllerReference(dep, &clusterIssuer, r.Scheme); err != nil {
-				return err
-			}
+	sec := corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Namespace: "cert-manager",
+		Name:      getSecNameForClusterIssuer(dep),
+	}, &sec); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
 
-			r.Log.Info("creating clusterIssuer", "name", dep.Name)
-			if err := r.Create(ctx, &clusterIssuer); err != nil {
-				r.Log.Error(err, "fail create clusterIssuer")
-				return err
-			}
+		sec := corev1.Secret{
+			TypeMeta: v1.TypeMeta{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "Secret",
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: "cert-manager",
+				Name:      getSecNameForClusterIssuer(dep),
+			},
+			Data: data,
+			Type: "Opaque",
 		}
 
-		// todo update
+		if err := ctrl.SetControllerReference(dep, &sec, r.Scheme); err != nil {
+			return err
+		}
 
-		return r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusRunning)
+		if err := r.Create(ctx, &sec); err != nil {
+			return err
+		}
 
-	default:
-		return fmt.Errorf("unknown tlsType: %s", config["tlsType"])
+		r.Log.Info("secret created")
+		return nil
+	}
+
+	if apiequality.Semantic.DeepEqual(sec.Data, data) {
+		return nil
+	}
+
+	sec.Data = data
+
+	return r.Update(ctx, &sec)
+}
+
+// releaseCAKeyPair removes the dependency from the owners of the Secret of the CA keypair,
+// so it's not deleted by the garbage collector with the dependency
+func (r *DependencyReconciler) releaseCAKeyPair(ctx context.Context, dep *corev1alpha1.Dependency) error {
+	sec := corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: getCASecNameForClusterIssuer(dep)}, &sec); err != nil {
+		return client.IgnoreNotFound(err)
 	}
+
+	var owners []v1.OwnerReference
+	for _, owner := range sec.OwnerReferences {
+		if owner.UID != dep.UID {
+			owners = append(owners, owner)
+		}
+	}
+
+	if len(owners) == len(sec.OwnerReferences) {
+		return nil
+	}
+
+	r.Log.Info("keeping CA keypair", "secret", sec.Name)
+	sec.OwnerReferences = owners
+
+	return r.Update(ctx, &sec)
+}
+
+// reconcileCAKeyPair generates the keypair of the CA once, and keeps it in a Secret in the cert-manager namespace,
+// which is where a ClusterIssuer reads secrets from. The name of the Secret is returned.
+func (r *DependencyReconciler) reconcileCAKeyPair(ctx context.Context, dep *corev1alpha1.Dependency, commonName string) (string, error) {
+	name := getCASecNameForClusterIssuer(dep)
+
+	sec := corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: name}, &sec); err == nil {
+		if v1.GetControllerOf(&sec) != nil {
+			return name, nil
+		}
+
+		// kept by a dependency of the same name, which was uninstalled with keepData
+		if err := ctrl.SetControllerReference(dep, &sec, r.Scheme); err != nil {
+			return "", err
+		}
+
+		return name, r.Update(ctx, &sec)
+	} else if !errors.IsNotFound(err) {
+		return "", err
+	}
+
+	if commonName == "" {
+		commonName = "kapp " + dep.Name + " CA"
+	}
+
+	certPEM, keyPEM, err := generateCAKeyPair(commonName, caValidity)
+	if err != nil {
+		return "", err
+	}
+
+	sec = corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "cert-manager",
+			Name:      name,
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+		Type: corev1.SecretTypeTLS,
+	}
+
+	if err := ctrl.SetControllerReference(dep, &sec, r.Scheme); err != nil {
+		return "", err
+	}
+
+	r.Log.Info("creating CA keypair", "secret", name)
+	if err := r.Create(ctx, &sec); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+var caValidity = 10 * 365 * 24 * time.Hour
+
+// generateCAKeyPair returns a self-signed CA certificate and its private key, PEM encoded
+func generateCAKeyPair(commonName string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"kapp"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return certPEM, keyPEM, nil
 }
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	cmv1alpha2 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestGenerateCAKeyPair(t *testing.T) {
+	certPEM, keyPEM, err := generateCAKeyPair("test CA", time.Hour)
+	assert.Nil(t, err)
+
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	assert.Nil(t, err)
+	assert.True(t, cert.IsCA)
+	assert.Equal(t, "test CA", cert.Subject.CommonName)
+	assert.Nil(t, cert.CheckSignatureFrom(cert))
+
+	block, _ = pem.Decode(keyPEM)
+	_, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	assert.Nil(t, err)
+}
+
+// reconciles the ClusterIssuer of dep the way Configure does
+func configureClusterIssuer(ctx context.Context, r *DependencyReconciler, dep *corev1alpha1.Dependency) error {
+	rawConfig, err := r.resolveDependencyConfig(ctx, dep)
+	if err != nil {
+		return err
+	}
+
+	config, err := corev1alpha1.ParseCertManagerConfig(rawConfig)
+	if err != nil {
+		return err
+	}
+
+	return r.reconcileClusterIssuer(ctx, dep, config)
+}
+
+func TestReconcileCAClusterIssuer(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	_ = cmv1alpha2.AddToScheme(r.Scheme)
+	_ = corev1alpha1.AddToScheme(r.Scheme)
+	ctx := context.Background()
+
+	dep := &corev1alpha1.Dependency{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", UID: "uid"},
+		Spec: corev1alpha1.DependencySpec{
+			Type:   "cert-manager",
+			Config: map[string]string{"tlsType": "ca"},
+		},
+	}
+
+	assert.Nil(t, configureClusterIssuer(ctx, r, dep))
+
+	var sec corev1.Secret
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: "ca-cm"}, &sec))
+	assert.NotEmpty(t, sec.Data[corev1.TLSCertKey])
+	crt := sec.Data[corev1.TLSCertKey]
+
+	var issuer cmv1alpha2.ClusterIssuer
+	assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: "cm"}, &issuer))
+	assert.Equal(t, "ca-cm", issuer.Spec.CA.SecretName)
+
+	// keypair is kept, issuer is updated when tlsType changes
+	assert.Nil(t, configureClusterIssuer(ctx, r, dep))
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: "ca-cm"}, &sec))
+	assert.Equal(t, crt, sec.Data[corev1.TLSCertKey])
+
+	dep.Spec.Config["tlsType"] = "selfSigned"
+	assert.Nil(t, configureClusterIssuer(ctx, r, dep))
+	issuer = cmv1alpha2.ClusterIssuer{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: "cm"}, &issuer))
+	assert.NotNil(t, issuer.Spec.SelfSigned)
+	assert.Nil(t, issuer.Spec.CA)
+}
+
+func TestReleaseCAKeyPair(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	_ = corev1alpha1.AddToScheme(r.Scheme)
+	ctx := context.Background()
+
+	dep := &corev1alpha1.Dependency{ObjectMeta: metav1.ObjectMeta{Name: "cm", UID: "uid"}}
+
+	name, err := r.reconcileCAKeyPair(ctx, dep, "")
+	assert.Nil(t, err)
+
+	var sec corev1.Secret
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: name}, &sec))
+	crt := sec.Data[corev1.TLSCertKey]
+	assert.Len(t, sec.OwnerReferences, 1)
+
+	// kept with keepData, not deleted with the dependency
+	assert.Nil(t, r.releaseCAKeyPair(ctx, dep))
+	sec = corev1.Secret{}
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: name}, &sec))
+	assert.Len(t, sec.OwnerReferences, 0)
+
+	// and adopted by the dependency installed again
+	dep = &corev1alpha1.Dependency{ObjectMeta: metav1.ObjectMeta{Name: "cm", UID: "uid-2"}}
+	_, err = r.reconcileCAKeyPair(ctx, dep, "")
+	assert.Nil(t, err)
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: name}, &sec))
+	assert.Equal(t, crt, sec.Data[corev1.TLSCertKey])
+	assert.Equal(t, types.UID("uid-2"), sec.OwnerReferences[0].UID)
+}
+
+func TestReconcileACMEClusterIssuer(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	_ = cmv1alpha2.AddToScheme(r.Scheme)
+	_ = corev1alpha1.AddToScheme(r.Scheme)
+	ctx := context.Background()
+
+	dep := &corev1alpha1.Dependency{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", UID: "uid"},
+		Spec: corev1alpha1.DependencySpec{
+			Type: "cert-manager",
+			Config: map[string]string{
+				"tlsType":            "acme",
+				"acmeServer":         "staging",
+				"challengeEmail":     "a@b.c",
+				"challengeProvider":  "route53",
+				"route53Region":      "us-east-1",
+				"route53AccessKeyID": "id",
+			},
+			SecretRefs: map[string]corev1alpha1.DependencySecretKeyRef{
+				"route53SecretAccessKey": {Namespace: "default", Name: "aws", Key: "key"},
+			},
+		},
+	}
+
+	assert.NotNil(t, configureClusterIssuer(ctx, r, dep), "referenced secret doesn't exist")
+
+	awsSec := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "aws"},
+		Data:       map[string][]byte{"key": []byte("secret")},
+	}
+	assert.Nil(t, r.Create(ctx, &awsSec))
+
+	assert.Nil(t, configureClusterIssuer(ctx, r, dep))
+
+	var issuer cmv1alpha2.ClusterIssuer
+	assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: "cm"}, &issuer))
+	assert.Equal(t, corev1alpha1.ACMEServerStaging, issuer.Spec.ACME.Server)
+
+	route53 := issuer.Spec.ACME.Solvers[0].DNS01.Route53
+	assert.Equal(t, "id", route53.AccessKeyID)
+
+	var sec corev1.Secret
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: route53.SecretAccessKey.Name}, &sec))
+	assert.Equal(t, "secret", string(sec.Data[route53.SecretAccessKey.Key]))
+	assert.Equal(t, "cm", sec.OwnerReferences[0].Name)
+
+	// credentials are updated
+	awsSec.Data["key"] = []byte("new-secret")
+	assert.Nil(t, r.Update(ctx, &awsSec))
+	assert.Nil(t, configureClusterIssuer(ctx, r, dep))
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: "cert-manager", Name: route53.SecretAccessKey.Name}, &sec))
+	assert.Equal(t, "new-secret", string(sec.Data[route53.SecretAccessKey.Key]))
+
+	// http01 through the kong ingress class
+	dep.Spec.Config = map[string]string{"tlsType": "acme", "challengeEmail": "a@b.c", "challengeType": "http01"}
+	dep.Spec.SecretRefs = nil
+	assert.Nil(t, configureClusterIssuer(ctx, r, dep))
+	issuer = cmv1alpha2.ClusterIssuer{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: "cm"}, &issuer))
+	assert.Equal(t, "kong", *issuer.Spec.ACME.Solvers[0].HTTP01.Ingress.Class)
+	assert.Equal(t, corev1alpha1.ACMEServerProduction, issuer.Spec.ACME.Server)
+
+	// through the class of the installed ingress dependency
+	assert.Nil(t, r.Create(ctx, &corev1alpha1.Dependency{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx"},
+		Spec:       corev1alpha1.DependencySpec{Type: "ingress-nginx"},
+	}))
+	assert.Nil(t, configureClusterIssuer(ctx, r, dep))
+	issuer = cmv1alpha2.ClusterIssuer{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: "cm"}, &issuer))
+	assert.Equal(t, ingressClassNginx, *issuer.Spec.ACME.Solvers[0].HTTP01.Ingress.Class)
+
+	// or the class in the config
+	dep.Spec.Config["challengeIngressClass"] = "kong"
+	assert.Nil(t, configureClusterIssuer(ctx, r, dep))
+	issuer = cmv1alpha2.ClusterIssuer{}
+	assert.Nil(t, r.Get(ctx, client.ObjectKey{Name: "cm"}, &issuer))
+	assert.Equal(t, "kong", *issuer.Spec.ACME.Solvers[0].HTTP01.Ingress.Class)
+}
@@ -12,9 +12,15 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	apiregistration "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	"log"
@@ -25,6 +31,9 @@ import (
 
 type DepInstallStatus int
 
+// field manager of the bundled manifests applied by the dependency controller
+const dependencyFieldOwner = "kapp-dependency-controller"
+
 const (
 	NotInstalled = iota
 	Installing
@@ -32,52 +41,55 @@ const (
 	Installed
 )
 
-// todo, support more than just deployments
-// stateful set, daemon set, etc...
-//func (r *DependencyReconciler) getInstallStatus(namespace string, kind string, names ...string) (DepInstallStatus, error) {
-//	return 0, nil
-//}
-
-func (r *DependencyReconciler) getDependencyInstallStatus(namespace string, dpNames []string, stsNames []string) (DepInstallStatus, error) {
+// getDependencyInstallStatus rolls the status of the deployments and statefulsets into one,
+// the status of each of them is returned too
+func (r *DependencyReconciler) getDependencyInstallStatus(namespace string, dpNames []string, stsNames []string) (DepInstallStatus, []corev1alpha1.DependencyComponentStatus, error) {
 	var statusList []DepInstallStatus
+	var components []corev1alpha1.DependencyComponentStatus
 
 	// deployment
 	for _, dpName := range dpNames {
-		dpStatus, err := r.getDpStatus(namespace, dpName)
+		dpStatus, component, err := r.getDpStatus(namespace, dpName)
 		if err != nil {
-			return 0, err
+			return 0, nil, err
 		}
 
 		r.Log.Info("dpStatus", dpName, dpStatus)
 		statusList = append(statusList, dpStatus)
+		components = append(components, component)
 	}
 
 	// statefulSet
 	for _, stsName := range stsNames {
-		stsStatus, err := r.getStsStatus(namespace, stsName)
+		stsStatus, component, err := r.getStsStatus(namespace, stsName)
 		if err != nil {
-			return 0, err
+			return 0, nil, err
 		}
 
 		r.Log.Info("stsStatus", stsName, stsStatus)
 		statusList = append(statusList, stsStatus)
+		components = append(components, component)
 	}
 
+	return rollUpInstallStatus(statusList), components, nil
+}
+
+func rollUpInstallStatus(statusList []DepInstallStatus) DepInstallStatus {
 	if hasStatus(statusList, InstallFailed) {
-		return InstallFailed, nil
+		return InstallFailed
 	}
 	if hasStatus(statusList, NotInstalled) {
-		return NotInstalled, nil
+		return NotInstalled
 	}
 	if hasStatus(statusList, Installing) {
-		return Installing, nil
+		return Installing
 	}
 
 	if allIsStatus(statusList, Installed) {
-		return Installed, nil
+		return Installed
 	}
 
-	return NotInstalled, nil
+	return NotInstalled
 }
 
 func hasStatus(statusList []DepInstallStatus, target DepInstallStatus) bool {
@@ -104,86 +116,315 @@ func allIsStatus(statusList []DepInstallStatus, target DepInstallStatus) bool {
 	return true
 }
 
-// todo more strict check
-// currently only check if given dp exist under ns
-func (r *DependencyReconciler) getDpStatus(namespace, dpName string) (DepInstallStatus, error) {
-	dpList := appsv1.DeploymentList{}
-	if err := r.List(context.TODO(), &dpList, client.InNamespace(namespace)); err != nil {
-		return 0, err
+// todo when is installFailed?
+func (r *DependencyReconciler) getDpStatus(namespace, dpName string) (DepInstallStatus, corev1alpha1.DependencyComponentStatus, error) {
+	component := corev1alpha1.DependencyComponentStatus{
+		Kind:      "Deployment",
+		Namespace: namespace,
+		Name:      dpName,
 	}
 
-	for _, dp := range dpList.Items {
-		if dp.Name != dpName {
-			continue
+	dp := appsv1.Deployment{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: dpName}, &dp); err != nil {
+		if errors.IsNotFound(err) {
+			component.LastError = "not installed"
+			return NotInstalled, component, nil
 		}
 
-		if dp.Status.ReadyReplicas >= dp.Status.Replicas {
-			return Installed, nil
-		} else {
-			return Installing, nil
+		return 0, component, err
+	}
+
+	component.Ready = dp.Status.ReadyReplicas
+	component.Desired = desiredReplicas(dp.Spec.Replicas)
+
+	if dp.Status.ReadyReplicas >= dp.Status.Replicas {
+		return Installed, component, nil
+	}
+
+	component.LastError = deploymentLastError(&dp)
+	if component.LastError == "" {
+		component.LastError = r.podsLastError(namespace, dp.Spec.Selector)
+	}
+
+	return Installing, component, nil
+}
+
+func (r *DependencyReconciler) getStsStatus(namespace, stsName string) (DepInstallStatus, corev1alpha1.DependencyComponentStatus, error) {
+	component := corev1alpha1.DependencyComponentStatus{
+		Kind:      "StatefulSet",
+		Namespace: namespace,
+		Name:      stsName,
+	}
+
+	sts := appsv1.StatefulSet{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: stsName}, &sts); err != nil {
+		if errors.IsNotFound(err) {
+			component.LastError = "not installed"
+			return NotInstalled, component, nil
 		}
 
-		// todo when is installFailed?
+		return 0, component, err
 	}
 
-	return NotInstalled, nil
+	component.Ready = sts.Status.ReadyReplicas
+	component.Desired = desiredReplicas(sts.Spec.Replicas)
+
+	if sts.Status.ReadyReplicas >= sts.Status.Replicas {
+		return Installed, component, nil
+	}
+
+	component.LastError = r.podsLastError(namespace, sts.Spec.Selector)
+
+	return Installing, component, nil
 }
 
-func (r *DependencyReconciler) getStsStatus(namespace, stsName string) (DepInstallStatus, error) {
-	list := appsv1.StatefulSetList{}
-	if err := r.List(context.TODO(), &list, client.InNamespace(namespace)); err != nil {
-		return 0, err
+// getDaemonSetsInstallStatus is getDependencyInstallStatus for daemonsets
+func (r *DependencyReconciler) getDaemonSetsInstallStatus(namespace string, dsNames []string) (DepInstallStatus, []corev1alpha1.DependencyComponentStatus, error) {
+	var statusList []DepInstallStatus
+	var components []corev1alpha1.DependencyComponentStatus
+
+	for _, dsName := range dsNames {
+		dsStatus, component, err := r.getDsStatus(namespace, dsName)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		r.Log.Info("dsStatus", dsName, dsStatus)
+		statusList = append(statusList, dsStatus)
+		components = append(components, component)
 	}
 
-	for _, item := range list.Items {
-		if item.Name != stsName {
-			continue
+	return rollUpInstallStatus(statusList), components, nil
+}
+
+func (r *DependencyReconciler) getDsStatus(namespace, dsName string) (DepInstallStatus, corev1alpha1.DependencyComponentStatus, error) {
+	component := corev1alpha1.DependencyComponentStatus{
+		Kind:      "DaemonSet",
+		Namespace: namespace,
+		Name:      dsName,
+	}
+
+	ds := appsv1.DaemonSet{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: dsName}, &ds); err != nil {
+		if errors.IsNotFound(err) {
+			component.LastError = "not installed"
+			return NotInstalled, component, nil
 		}
 
-		if item.Status.ReadyReplicas >= item.Status.Replicas {
-			return Installed, nil
-		} else {
-			return Installing, nil
+		return 0, component, err
+	}
+
+	component.Ready = ds.Status.NumberReady
+	component.Desired = ds.Status.DesiredNumberScheduled
+
+	if ds.Status.NumberReady >= ds.Status.DesiredNumberScheduled {
+		return Installed, component, nil
+	}
+
+	component.LastError = r.podsLastError(namespace, ds.Spec.Selector)
+
+	return Installing, component, nil
+}
+
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+
+	return *replicas
+}
+
+func deploymentLastError(dp *appsv1.Deployment) string {
+	for _, cond := range dp.Status.Conditions {
+		if cond.Type == appsv1.DeploymentReplicaFailure && cond.Status == corev1.ConditionTrue ||
+			cond.Type == appsv1.DeploymentProgressing && cond.Status == corev1.ConditionFalse {
+			return fmt.Sprintf("%s: %s", cond.Reason, cond.Message)
+		}
+	}
+
+	return ""
+}
+
+// podsLastError finds why the pods of a workload are not ready,
+// e.g. a pod can't be scheduled or a container is waiting in ImagePullBackOff
+func (r *DependencyReconciler) podsLastError(namespace string, selector *metav1.LabelSelector) string {
+	if selector == nil {
+		return ""
+	}
+
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return ""
+	}
+
+	var pods corev1.PodList
+	if err := r.List(context.TODO(), &pods, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: sel}); err != nil {
+		r.Log.Error(err, "fail to list pods", "namespace", namespace)
+		return ""
+	}
+
+	for _, pod := range pods.Items {
+		if msg := podLastError(&pod); msg != "" {
+			return fmt.Sprintf("pod %s: %s", pod.Name, msg)
 		}
 	}
 
-	return NotInstalled, nil
+	return ""
+}
+
+func podLastError(pod *corev1.Pod) string {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse {
+			return fmt.Sprintf("%s: %s", cond.Reason, cond.Message)
+		}
+	}
+
+	statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if waiting := status.State.Waiting; waiting != nil &&
+			waiting.Reason != "ContainerCreating" && waiting.Reason != "PodInitializing" {
+			if waiting.Message == "" {
+				return fmt.Sprintf("container %s: %s", status.Name, waiting.Reason)
+			}
+
+			return fmt.Sprintf("container %s: %s: %s", status.Name, waiting.Reason, waiting.Message)
+		}
+
+		if terminated := status.LastTerminationState.Terminated; terminated != nil && terminated.ExitCode != 0 && !status.Ready {
+			return fmt.Sprintf("container %s: %s, exit code %d", status.Name, terminated.Reason, terminated.ExitCode)
+		}
+	}
+
+	return ""
 }
 
 func (r *DependencyReconciler) reconcileExternalController(ctx context.Context, fileOrDirName string) error {
 	//load yaml for external-controller
-	files, fileNames := loadFiles(fileOrDirName)
+	files, fileNames, err := loadFiles(fileOrDirName)
+	if err != nil {
+		return err
+	}
+
 	for i, file := range files {
 		r.Log.Info("parsing file", "i", i, "fileName", fileNames[i])
 
 		objs := parseK8sYaml(file)
 
-		// only create, no update yet
-		if err := r.createMany(ctx, objs...); err != nil {
+		if err := r.applyMany(ctx, objs...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// uninstallExternalController deletes the objects of the bundled manifests in reverse order.
+// It returns retryLaterErr until all the objects are gone.
+// CustomResourceDefinitions are never deleted, that would delete all their objects too,
+// including those created by kapp and users, e.g. Certificates and ServiceMonitors of applications.
+// Namespaces are kept if keepData is set, so the PersistentVolumeClaims in them survive.
+func (r *DependencyReconciler) uninstallExternalController(ctx context.Context, keepData bool, fileOrDirNames ...string) error {
+	var objs []runtime.Object
+	for _, fileOrDirName := range fileOrDirNames {
+		files, _, err := loadFiles(fileOrDirName)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			objs = append(objs, parseK8sYaml(file)...)
+		}
+	}
+
+	remaining := 0
+	for i := len(objs) - 1; i >= 0; i-- {
+		obj := objs[i]
+
+		if _, isCRD := obj.(*apiextv1beta1.CustomResourceDefinition); isCRD {
+			continue
+		}
+
+		if keepData {
+			switch obj.(type) {
+			case *corev1.Namespace, *corev1.PersistentVolumeClaim:
+				continue
+			}
+		}
+
+		if err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			// the CRD of the object may be deleted already
+			if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+
+			// a namespace which is terminating already can't be deleted again, wait for it to disappear
+			if _, isNamespace := obj.(*corev1.Namespace); isNamespace && errors.IsConflict(err) {
+				remaining++
+				continue
+			}
 
 			return err
 		}
+
+		remaining++
+	}
+
+	if remaining > 0 {
+		r.Log.Info("waiting for objects of bundled manifests to be deleted", "remaining", remaining)
+		return retryLaterErr
 	}
 
 	return nil
 }
 
-func isAlreadyExistsErr(err error) bool {
-	if errors.IsAlreadyExists(err) {
-		fmt.Println("errors.IsAlreadyExists, err:", err)
-		return true
+// kinds of objects which are not deleted when they are removed from the bundled manifests, their data would be lost
+var unprunableKinds = map[string]bool{
+	"CustomResourceDefinition": true,
+	"Namespace":                true,
+	"PersistentVolumeClaim":    true,
+}
+
+// pruneObjects deletes the objects applied before which are not in the bundled manifests any more.
+// Objects are matched by group, kind, namespace and name, an object moved to another version of its api is kept.
+func (r *DependencyReconciler) pruneObjects(ctx context.Context, previous, current []corev1alpha1.DependencyAppliedObject) error {
+	key := func(obj corev1alpha1.DependencyAppliedObject) string {
+		gv, _ := schema.ParseGroupVersion(obj.APIVersion)
+		return strings.Join([]string{gv.Group, obj.Kind, obj.Namespace, obj.Name}, "/")
 	}
 
-	// todo
-	if strings.Contains(err.Error(), "already exists") {
-		fmt.Println("isAlreadyExistsErr, str match:", err)
-		return true
+	keep := make(map[string]bool, len(current))
+	for _, obj := range current {
+		keep[key(obj)] = true
 	}
 
-	return false
+	for _, obj := range previous {
+		if keep[key(obj)] || unprunableKinds[obj.Kind] {
+			continue
+		}
+
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(obj.APIVersion)
+		u.SetKind(obj.Kind)
+		u.SetNamespace(obj.Namespace)
+		u.SetName(obj.Name)
+
+		r.Log.Info("deleting object removed from bundled manifests", "kind", obj.Kind, "namespace", obj.Namespace, "name", obj.Name)
+		if err := r.Delete(ctx, u, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+
+			return err
+		}
+	}
+
+	return nil
 }
 
-func loadFiles(fileOrDirName string) (files [][]byte, fileNames []string) {
+// loadFiles reads the bundled manifest, or the .yaml files directly under the dir if it starts or ends with /,
+// from the first resources dir which has it. It's an error if no manifest is found,
+// an empty set of objects would be taken as applied, and the live objects pruned.
+func loadFiles(fileOrDirName string) (files [][]byte, fileNames []string, err error) {
 	searchDirs := []string{
 		"./resources",
 		"/resources",
@@ -214,7 +455,10 @@ func loadFiles(fileOrDirName string) (files [][]byte, fileNames []string) {
 					continue
 				}
 
-				dat, _ := ioutil.ReadFile(fmt.Sprintf("%s/%s", dirPath, fileInfo.Name()))
+				dat, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", dirPath, fileInfo.Name()))
+				if err != nil {
+					return nil, nil, err
+				}
 
 				files = append(files, dat)
 				fileNames = append(fileNames, fileInfo.Name())
@@ -223,7 +467,6 @@ func loadFiles(fileOrDirName string) (files [][]byte, fileNames []string) {
 
 			dat, err := ioutil.ReadFile(fmt.Sprintf("%s/%s", searchDir, fileOrDirName))
 			if err != nil {
-				fmt.Println("err loadFile:", err)
 				continue
 			}
 
@@ -232,11 +475,11 @@ func loadFiles(fileOrDirName string) (files [][]byte, fileNames []string) {
 		}
 
 		if len(files) > 0 {
-			return
+			return files, fileNames, nil
 		}
 	}
 
-	return
+	return nil, nil, fmt.Errorf("no manifest %s in %s", fileOrDirName, strings.Join(searchDirs, ", "))
 }
 
 // ref: https://github.com/kubernetes/client-go/issues/193#issuecomment-363318588
@@ -247,7 +490,8 @@ func parseK8sYaml(fileR []byte) []runtime.Object {
 	//	more
 	//}
 
-	acceptedK8sTypes := regexp.MustCompile(`(StatefulSet|Prometheus|DaemonSet|Alertmanager|Secret|ValidatingWebhookConfiguration|MutatingWebhookConfiguration|CustomResourceDefinition|ConfigMap|Service|Deployment|Namespace|Role|ClusterRole|RoleBinding|ClusterRoleBinding|ServiceAccount)`)
+	// whole kinds only, every kind of the bundled manifests has to be listed
+	acceptedK8sTypes := regexp.MustCompile(`^(StatefulSet|Prometheus|PrometheusRule|ServiceMonitor|DaemonSet|Alertmanager|Secret|ValidatingWebhookConfiguration|MutatingWebhookConfiguration|CustomResourceDefinition|ConfigMap|ConfigMapList|Service|APIService|Deployment|Namespace|LimitRange|PersistentVolumeClaim|Role|RoleList|ClusterRole|RoleBinding|RoleBindingList|ClusterRoleBinding|ServiceAccount)$`)
 	fileAsString := string(fileR[:])
 	sepYamlFiles := strings.Split(fileAsString, "\n---\n")
 	retVal := make([]runtime.Object, 0, len(sepYamlFiles))
@@ -328,13 +572,11 @@ func isCommentOnly(f string) bool {
 	return true
 }
 
-func (r *DependencyReconciler) createMany(ctx context.Context, objs ...runtime.Object) error {
+// applyMany applies the objects with server-side apply,
+// fields set in the bundled manifests are owned by kapp, changes made to them by others are reverted.
+func (r *DependencyReconciler) applyMany(ctx context.Context, objs ...runtime.Object) error {
 	for _, obj := range objs {
-		if err := r.Create(ctx, obj); err != nil {
-			if isAlreadyExistsErr(err) {
-				continue
-			}
-
+		if err := r.Patch(ctx, obj, client.Apply, client.FieldOwner(dependencyFieldOwner), client.ForceOwnership); err != nil {
 			return err
 		}
 	}
@@ -342,30 +584,68 @@ func (r *DependencyReconciler) createMany(ctx context.Context, objs ...runtime.O
 	return nil
 }
 
+// UpdateStatusIfNotMatch sets the status of the dependency,
+// it's saved by updateStatusIfChanged at the end of Reconcile
 func (r *DependencyReconciler) UpdateStatusIfNotMatch(ctx context.Context, dep *corev1alpha1.Dependency, status string) error {
 	if dep.Status.Status == status {
-		r.Log.Info("same status, ignored",
-			"status", status, "dep", dep)
 		return nil
 	}
 
-	dep.Status = corev1alpha1.DependencyStatus{
-		Status: status,
+	r.Log.Info("status changed", "dep", dep.Name, "from", dep.Status.Status, "to", status)
+
+	now := metav1.Now()
+	dep.Status.Status = status
+	dep.Status.LastTransitionTime = &now
+
+	return nil
+}
+
+// setDependencyCondition adds or updates the condition, LastTransitionTime changes only if Status changes
+func setDependencyCondition(status *corev1alpha1.DependencyStatus, condType corev1alpha1.DependencyConditionType, condStatus corev1.ConditionStatus, reason, message string) {
+	for i := range status.Conditions {
+		cond := &status.Conditions[i]
+		if cond.Type != condType {
+			continue
+		}
+
+		if cond.Status != condStatus {
+			cond.LastTransitionTime = metav1.Now()
+		}
+
+		cond.Status = condStatus
+		cond.Reason = reason
+		cond.Message = message
+
+		return
+	}
+
+	status.Conditions = append(status.Conditions, corev1alpha1.DependencyCondition{
+		Type:               condType,
+		Status:             condStatus,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	})
+}
+
+func (r *DependencyReconciler) updateStatusIfChanged(ctx context.Context, original *corev1alpha1.DependencyStatus, dep *corev1alpha1.Dependency) error {
+	if apiequality.Semantic.DeepEqual(original, &dep.Status) {
+		return nil
 	}
 
 	if err := r.Status().Update(ctx, dep); err != nil {
 		if errors.IsConflict(err) {
 			r.Log.Info("errors.IsConflict, retry later",
-				"err", err, "dep", dep, "status", status)
+				"err", err, "dep", dep, "status", dep.Status)
 
 			return nil
 		}
 
 		r.Log.Error(err, "fail to update status")
-		return err
+		return client.IgnoreNotFound(err)
 	}
 
-	r.Log.Info("finish updating status", "to", status)
+	r.Log.Info("finish updating status", "to", dep.Status)
 
 	return nil
 }
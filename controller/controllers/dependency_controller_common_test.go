@@ -1,19 +1,32 @@
 package controllers
 
 import (
+	"context"
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v2"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"strings"
 	"testing"
 )
 
 func TestLoadFiles(t *testing.T) {
-	files, _ := loadFiles("/kube-prometheus/setup")
+	files, _, err := loadFiles("/kube-prometheus/setup")
 
 	//fmt.Println(len(files))
 
+	assert.Nil(t, err)
 	assert.NotNil(t, files)
 	assert.Greater(t, len(files), 0)
+
+	// a missing manifest is not an empty one
+	_, _, err = loadFiles("missing_1.0.0.yaml")
+	assert.NotNil(t, err)
+
+	_, _, err = loadFiles("/missing/")
+	assert.NotNil(t, err)
 }
 
 func TestSplit(t *testing.T) {
@@ -70,3 +83,97 @@ func TestParseK8sYaml2(t *testing.T) {
 
 	assert.Equal(t, 2, len(objs))
 }
+
+func TestParseK8sYamlKinds(t *testing.T) {
+	objs := parseK8sYaml([]byte(`
+apiVersion: v1
+kind: LimitRange
+metadata:
+  name: limits
+  namespace: kapp-logging
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+  namespace: kapp-logging
+---
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: loki
+  namespace: kapp-logging
+---
+apiVersion: v1
+kind: SecretList
+items: []
+`))
+
+	var kinds []string
+	for _, obj := range objs {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+
+	// SecretList is not a supported kind, though it contains Secret
+	assert.Equal(t, []string{"LimitRange", "PersistentVolumeClaim", "ServiceMonitor"}, kinds)
+}
+
+func TestGetDpStatus(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	ctx := context.Background()
+
+	status, component, err := r.getDpStatus("kapp-kong", "ingress-kong")
+	assert.Nil(t, err)
+	assert.Equal(t, DepInstallStatus(NotInstalled), status)
+	assert.Equal(t, "not installed", component.LastError)
+
+	replicas := int32(1)
+	labels := map[string]string{"app": "ingress-kong"}
+	assert.Nil(t, r.Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kapp-kong", Name: "ingress-kong"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: 1},
+	}))
+
+	assert.Nil(t, r.Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kapp-kong", Name: "ingress-kong-xyz", Labels: labels},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  "proxy",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+				},
+			},
+		},
+	}))
+
+	status, component, err = r.getDpStatus("kapp-kong", "ingress-kong")
+	assert.Nil(t, err)
+	assert.Equal(t, DepInstallStatus(Installing), status)
+	assert.Equal(t, int32(0), component.Ready)
+	assert.Equal(t, int32(1), component.Desired)
+	assert.Equal(t, "pod ingress-kong-xyz: container proxy: ImagePullBackOff", component.LastError)
+
+	assert.Equal(t,
+		"Deployment kapp-kong/ingress-kong 0/1: pod ingress-kong-xyz: container proxy: ImagePullBackOff",
+		componentsNotReadyMessage([]corev1alpha1.DependencyComponentStatus{component}))
+}
+
+func TestSetDependencyCondition(t *testing.T) {
+	status := corev1alpha1.DependencyStatus{}
+
+	setDependencyCondition(&status, corev1alpha1.DependencyConditionInstalled, corev1.ConditionFalse, "Installing", "")
+	assert.Equal(t, 1, len(status.Conditions))
+
+	status.Conditions[0].LastTransitionTime = metav1.Time{}
+	setDependencyCondition(&status, corev1alpha1.DependencyConditionInstalled, corev1.ConditionFalse, "Installing", "still")
+	assert.True(t, status.Conditions[0].LastTransitionTime.IsZero(), "same status, no transition")
+	assert.Equal(t, "still", status.Conditions[0].Message)
+
+	setDependencyCondition(&status, corev1alpha1.DependencyConditionInstalled, corev1.ConditionTrue, "Installed", "")
+	assert.False(t, status.Conditions[0].LastTransitionTime.IsZero())
+	assert.Equal(t, 1, len(status.Conditions))
+}
@@ -2,6 +2,9 @@ package controllers
 
 import (
 	"context"
+	"crypto/md5"
+	"fmt"
+
 	elkcommonv1 "github.com/elastic/cloud-on-k8s/pkg/apis/common/v1"
 	elkv1 "github.com/elastic/cloud-on-k8s/pkg/apis/elasticsearch/v1"
 	kibanav1 "github.com/elastic/cloud-on-k8s/pkg/apis/kibana/v1"
@@ -18,51 +21,47 @@ import (
 	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
 )
 
-func (r *DependencyReconciler) reconcileELK(ctx context.Context, d *corev1alpha1.Dependency) error {
+func init() {
+	registerDependencyDriver("log", func(r *DependencyReconciler) DependencyDriver {
+		return elkDriver{bundledDriver{
+			DependencyReconciler: r,
+			namespace:            nsKappLog,
+			versions:             map[string][]string{"1.0.0": {"elk/ECK-all-in-one.yaml"}},
+			stsNames:             []string{"elastic-operator"},
+		}}
+	})
+}
 
-	operatorStatus, err := r.getDependencyInstallStatus("kapp-log", nil, []string{"elastic-operator"})
-	if err != nil {
-		return err
-	}
+type elkDriver struct {
+	bundledDriver
+}
 
-	switch operatorStatus {
-	case NotInstalled:
-		// try install Prometheus Operator
-		if err := r.reconcileExternalController(ctx, "elk/ECK-all-in-one.yaml"); err != nil {
-			return err
-		}
+func (elkDriver) ConfigSchema() []corev1alpha1.DependencyConfigField {
+	return configSchemaOf("log")
+}
 
-		return retryLaterErr
-	case Installing:
-		r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusInstalling)
-		return retryLaterErr
-	case InstallFailed:
-		r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusInstallFailed)
-		return nil
-	case Installed:
-		r.Log.Info("ELK operator installed")
+func (r elkDriver) Configure(ctx context.Context, d *corev1alpha1.Dependency, rawConfig map[string]string) error {
+	config, err := corev1alpha1.ParseLogConfig(rawConfig)
+	if err != nil {
+		return err
 	}
 
 	// elastic search
-	if err := r.reconcileES(ctx, d); err != nil {
+	if err := r.reconcileES(ctx, d, config); err != nil {
 		return err
 	}
 
 	// kibana
-	if err := r.reconcileKibana(ctx, d); err != nil {
+	if err := r.reconcileKibana(ctx, d, config); err != nil {
 		return err
 	}
 
 	// filebeat
-	if err := r.reconcileFileBeat(ctx, d); err != nil {
-		return err
-	}
-
-	return r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusRunning)
+	return r.reconcileFileBeat(ctx, d)
 }
 
-func (r *DependencyReconciler) reconcileES(ctx context.Context, d *corev1alpha1.Dependency) error {
-	es, exist, err := r.getElasticSearch(ctx, d)
+func (r *DependencyReconciler) reconcileES(ctx context.Context, d *corev1alpha1.Dependency, config *corev1alpha1.LogConfig) error {
+	es, exist, err := r.getElasticSearch(ctx, d, config)
 	if err != nil {
 		return err
 	}
@@ -81,7 +80,7 @@ func (r *DependencyReconciler) reconcileES(ctx context.Context, d *corev1alpha1.
 		}
 	}
 
-	es, exist, err = r.getElasticSearch(ctx, d)
+	es, exist, err = r.getElasticSearch(ctx, d, config)
 	if err != nil {
 		return err
 	}
@@ -98,9 +97,9 @@ var nsKappLog = "kapp-log"
 var esName = "elasticsearch"
 var kibanaName = "kibana"
 
-func (r *DependencyReconciler) getElasticSearch(ctx context.Context, d *corev1alpha1.Dependency) (rst *elkv1.Elasticsearch, exist bool, err error) {
+func (r *DependencyReconciler) getElasticSearch(ctx context.Context, d *corev1alpha1.Dependency, config *corev1alpha1.LogConfig) (rst *elkv1.Elasticsearch, exist bool, err error) {
 
-	desiredES := desiredElasticSearch(d)
+	desiredES := desiredElasticSearch(config)
 
 	es := elkv1.Elasticsearch{}
 	if err := r.Get(ctx, types.NamespacedName{Namespace: nsKappLog, Name: esName}, &es); err != nil {
@@ -116,42 +115,65 @@ func (r *DependencyReconciler) getElasticSearch(ctx context.Context, d *corev1al
 	}
 
 	//make sure matches config
+	es.Spec.Version = desiredES.Spec.Version
+
 	for i, _ := range desiredES.Spec.NodeSets {
 		desiredNodeSet := desiredES.Spec.NodeSets[i]
 
-		es.Spec.NodeSets[i].Name = desiredNodeSet.Name
 		es.Spec.NodeSets[i].Count = desiredNodeSet.Count
 		es.Spec.NodeSets[i].PodTemplate.Spec = desiredNodeSet.PodTemplate.Spec
 		es.Spec.NodeSets[i].Config = desiredNodeSet.Config
+
+		// volumes of a node set can't be changed, a node set with another name is used instead,
+		// the operator moves the data to the new nodes before the old ones are removed
+		if !esVolumesMatch(es.Spec.NodeSets[i].VolumeClaimTemplates, config) {
+			es.Spec.NodeSets[i].Name = fmt.Sprintf("%s-%x", desiredNodeSet.Name,
+				md5.Sum([]byte(config.Storage.String()+"/"+config.StorageClass)))[:len(desiredNodeSet.Name)+9]
+			es.Spec.NodeSets[i].VolumeClaimTemplates = desiredNodeSet.VolumeClaimTemplates
+		}
 	}
 
 	return &es, true, err
 }
 
-func desiredElasticSearch(dep *corev1alpha1.Dependency) elkv1.Elasticsearch {
-	// todo should be configurable
-	storageClassName := "standard"
+// esVolumesMatch checks the volume of a node set against the config,
+// any storage class matches if it's not configured
+func esVolumesMatch(volumes []corev1.PersistentVolumeClaim, config *corev1alpha1.LogConfig) bool {
+	if len(volumes) != 1 {
+		return false
+	}
 
-	storage := resource.MustParse("512Mi")
-	if s, exist := dep.Spec.Config["storage"]; exist {
-		if parsed, err := resource.ParseQuantity(s); err == nil {
-			if parsed.Cmp(storage) > 0 {
-				storage = parsed
-			}
-		}
+	storage := volumes[0].Spec.Resources.Requests[corev1.ResourceStorage]
+	if storage.Cmp(config.Storage) != 0 {
+		return false
 	}
 
+	if config.StorageClass == "" {
+		return true
+	}
+
+	return volumes[0].Spec.StorageClassName != nil && *volumes[0].Spec.StorageClassName == config.StorageClass
+}
+
+func desiredElasticSearch(config *corev1alpha1.LogConfig) elkv1.Elasticsearch {
+	var storageClassName *string
+	if config.StorageClass != "" {
+		storageClassName = &config.StorageClass
+	}
+
+	storage := config.Storage
+
 	desiredES := elkv1.Elasticsearch{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      esName,
 			Namespace: nsKappLog,
 		},
 		Spec: elkv1.ElasticsearchSpec{
-			Version: "7.6.1",
+			Version: config.Version,
 			NodeSets: []elkv1.NodeSet{
 				{
 					Name:  "default",
-					Count: 1,
+					Count: config.ElasticsearchReplicas,
 					PodTemplate: corev1.PodTemplateSpec{
 						Spec: corev1.PodSpec{
 							Containers: []corev1.Container{
@@ -160,19 +182,10 @@ func desiredElasticSearch(dep *corev1alpha1.Dependency) elkv1.Elasticsearch {
 									Env: []corev1.EnvVar{
 										{
 											Name:  "ES_JAVA_OPTS",
-											Value: "-Xms512m -Xmx512m",
-										},
-									},
-									Resources: corev1.ResourceRequirements{
-										Limits: corev1.ResourceList{
-											"cpu":    resource.MustParse("2"),
-											"memory": resource.MustParse("1Gi"),
-										},
-										Requests: corev1.ResourceList{
-											"cpu":    resource.MustParse("0.5"),
-											"memory": resource.MustParse("512Mi"),
+											Value: config.ElasticsearchJavaOpts(),
 										},
 									},
+									Resources: config.ElasticsearchResources,
 								},
 							},
 						},
@@ -190,7 +203,7 @@ func desiredElasticSearch(dep *corev1alpha1.Dependency) elkv1.Elasticsearch {
 										"storage": storage,
 									},
 								},
-								StorageClassName: &storageClassName,
+								StorageClassName: storageClassName,
 							},
 						},
 					},
@@ -211,8 +224,8 @@ func desiredElasticSearch(dep *corev1alpha1.Dependency) elkv1.Elasticsearch {
 	return desiredES
 }
 
-func (r *DependencyReconciler) reconcileKibana(ctx context.Context, d *corev1alpha1.Dependency) error {
-	k, exist, err := r.getKibana(ctx, d)
+func (r *DependencyReconciler) reconcileKibana(ctx context.Context, d *corev1alpha1.Dependency, config *corev1alpha1.LogConfig) error {
+	k, exist, err := r.getKibana(ctx, d, config)
 	if err != nil {
 		return err
 	}
@@ -231,7 +244,7 @@ func (r *DependencyReconciler) reconcileKibana(ctx context.Context, d *corev1alp
 		}
 	}
 
-	k, exist, err = r.getKibana(ctx, d)
+	k, exist, err = r.getKibana(ctx, d, config)
 	if err != nil {
 		return err
 	}
@@ -240,7 +253,7 @@ func (r *DependencyReconciler) reconcileKibana(ctx context.Context, d *corev1alp
 		return retryLaterErr
 	}
 
-	if host, exist := d.Spec.Config["kibanaHost"]; exist && host != "" {
+	if host := config.KibanaHost; host != "" {
 		pluginIng := &corev1alpha1.PluginIngress{
 			Name:        "kibana",
 			Type:        pluginIngress,
@@ -250,7 +263,12 @@ func (r *DependencyReconciler) reconcileKibana(ctx context.Context, d *corev1alp
 			ServicePort: 5601,
 		}
 
-		ing, exist, err := r.getIngress(ctx, d, []*corev1alpha1.PluginIngress{pluginIng})
+		ingressClass, err := r.defaultIngressClass(ctx)
+		if err != nil {
+			return err
+		}
+
+		ing, exist, err := r.getIngress(ctx, d, []*corev1alpha1.PluginIngress{pluginIng}, ingressClass, config.CertManager)
 		if err != nil {
 			return err
 		}
@@ -268,8 +286,8 @@ func (r *DependencyReconciler) reconcileKibana(ctx context.Context, d *corev1alp
 	return nil
 }
 
-func (r *DependencyReconciler) getKibana(ctx context.Context, d *corev1alpha1.Dependency) (*kibanav1.Kibana, bool, error) {
-	desired := r.desiredKibana(d)
+func (r *DependencyReconciler) getKibana(ctx context.Context, d *corev1alpha1.Dependency, config *corev1alpha1.LogConfig) (*kibanav1.Kibana, bool, error) {
+	desired := r.desiredKibana(d, config)
 	k := kibanav1.Kibana{}
 
 	if err := r.Get(ctx, types.NamespacedName{Namespace: nsKappLog, Name: kibanaName}, &k); err != nil {
@@ -287,19 +305,32 @@ func (r *DependencyReconciler) getKibana(ctx context.Context, d *corev1alpha1.De
 	k.Spec.Version = desired.Spec.Version
 	k.Spec.Count = desired.Spec.Count
 	k.Spec.HTTP = desired.Spec.HTTP
+	k.Spec.PodTemplate.Spec = desired.Spec.PodTemplate.Spec
 
 	return &k, true, nil
 }
 
-func (r *DependencyReconciler) desiredKibana(d *corev1alpha1.Dependency) kibanav1.Kibana {
+func (r *DependencyReconciler) desiredKibana(d *corev1alpha1.Dependency, config *corev1alpha1.LogConfig) kibanav1.Kibana {
+	// resources set by the operator if not configured
+	var podTemplate corev1.PodTemplateSpec
+	if len(config.KibanaResources) > 0 {
+		podTemplate.Spec.Containers = []corev1.Container{
+			{
+				Name:      "kibana",
+				Resources: corev1.ResourceRequirements{Requests: config.KibanaResources},
+			},
+		}
+	}
+
 	return kibanav1.Kibana{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      kibanaName,
 			Namespace: nsKappLog,
 		},
 		Spec: kibanav1.KibanaSpec{
-			Version: "7.6.1",
-			Count:   1,
+			Version:     config.Version,
+			Count:       config.KibanaReplicas,
+			PodTemplate: podTemplate,
 			ElasticsearchRef: elkcommonv1.ObjectSelector{
 				Name: esName,
 			},
@@ -333,11 +364,7 @@ func (r *DependencyReconciler) reconcileFileBeat(ctx context.Context, d *corev1a
 	}
 
 	// ds of filebeat
-	if err := r.reconcileDaemonSetForFileBeat(ctx, d); err != nil {
-		return err
-	}
-
-	return r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusRunning)
+	return r.reconcileDaemonSetForFileBeat(ctx, d)
 }
 
 func (r *DependencyReconciler) reconcileConfigMapForFileBeat(ctx context.Context, d *corev1alpha1.Dependency) error {
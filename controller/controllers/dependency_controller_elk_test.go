@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDesiredElasticSearch(t *testing.T) {
+	config, err := corev1alpha1.ParseLogConfig(map[string]string{
+		"storage":               "2Gi",
+		"version":               "7.8.0",
+		"elasticsearchReplicas": "3",
+	})
+	assert.Nil(t, err)
+
+	es := desiredElasticSearch(config)
+	assert.Equal(t, "7.8.0", es.Spec.Version)
+
+	nodeSet := es.Spec.NodeSets[0]
+	assert.Equal(t, int32(3), nodeSet.Count)
+	assert.Equal(t, "-Xms512m -Xmx512m", nodeSet.PodTemplate.Spec.Containers[0].Env[0].Value)
+	assert.Nil(t, nodeSet.VolumeClaimTemplates[0].Spec.StorageClassName)
+	assert.True(t, esVolumesMatch(nodeSet.VolumeClaimTemplates, config))
+
+	// volumes of existing installs have the standard storage class
+	standard := "standard"
+	nodeSet.VolumeClaimTemplates[0].Spec.StorageClassName = &standard
+	assert.True(t, esVolumesMatch(nodeSet.VolumeClaimTemplates, config))
+
+	config.StorageClass = "ssd"
+	assert.False(t, esVolumesMatch(nodeSet.VolumeClaimTemplates, config))
+
+	config, _ = corev1alpha1.ParseLogConfig(map[string]string{"storage": "4Gi"})
+	assert.False(t, esVolumesMatch(nodeSet.VolumeClaimTemplates, config))
+}
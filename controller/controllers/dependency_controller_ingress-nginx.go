@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"context"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+)
+
+func init() {
+	registerDependencyDriver("ingress-nginx", func(r *DependencyReconciler) DependencyDriver {
+		return ingressNginxDriver{bundledDriver{
+			DependencyReconciler: r,
+			namespace:            "kapp-ingress-nginx",
+			versions:             map[string][]string{"1.0.0": {"ingress-nginx_1.0.0.yaml"}},
+			dpNames:              []string{"nginx-ingress-controller"},
+		}}
+	})
+}
+
+// ingressNginxDriver installs the nginx ingress controller,
+// which serves the same ingress plugins as kong, with ingress class nginx
+type ingressNginxDriver struct {
+	bundledDriver
+}
+
+func (ingressNginxDriver) ConfigSchema() []corev1alpha1.DependencyConfigField {
+	return configSchemaOf("ingress-nginx")
+}
+
+func (r ingressNginxDriver) Configure(ctx context.Context, dep *corev1alpha1.Dependency, config map[string]string) error {
+	ingressConfig, err := corev1alpha1.ParseIngressConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return r.reconcileIngressPlugins(ctx, dep, ingressClassNginx, ingressConfig.CertManager)
+}
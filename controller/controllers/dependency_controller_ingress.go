@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ingressClassKong  = "kong"
+	ingressClassNginx = "nginx"
+)
+
+// ingress class served by each type of ingress dependency
+var ingressClassOfDependencyType = map[string]string{
+	"kong":          ingressClassKong,
+	"ingress-nginx": ingressClassNginx,
+}
+
+// reconcileIngressPlugins collects ingress plugins of all applications,
+// and keeps 1 ingress of the ingress class per namespace for them
+func (r *DependencyReconciler) reconcileIngressPlugins(ctx context.Context, dep *corev1alpha1.Dependency, ingressClass, certManager string) error {
+	var kappList corev1alpha1.ApplicationList
+	if err := r.List(ctx, &kappList, client.InNamespace("")); err != nil {
+		return nil
+	}
+
+	r.Log.Info("kapps", "size:", len(kappList.Items))
+	// collect ingress info & update the ingress controller
+
+	ns2ingPluginsMap := make(map[string][]*corev1alpha1.PluginIngress)
+	for _, kapp := range kappList.Items {
+		ns := kapp.Namespace
+
+		if existPlugins, exist := ns2ingPluginsMap[ns]; !exist {
+			ns2ingPluginsMap[ns] = GetIngressPlugins(&kapp)
+		} else {
+			ns2ingPluginsMap[ns] = append(existPlugins, GetIngressPlugins(&kapp)...)
+		}
+	}
+
+	r.Log.Info("ns2ingPluginsMap", "size:", len(ns2ingPluginsMap))
+
+	// 1 ingress per namespace
+	for ns, ingPlugins := range ns2ingPluginsMap {
+		if len(ingPlugins) <= 0 {
+			continue
+		}
+
+		r.Log.Info("plugins",
+			"ns", ns,
+			"size:", len(ingPlugins),
+			"ingPlugins", ingPlugins)
+
+		ing, exist, err := r.getIngress(ctx, dep, ingPlugins, ingressClass, certManager)
+		if err != nil {
+			return err
+		}
+
+		if !exist {
+			r.Log.Info("creating ing")
+			if err := r.Create(ctx, ing); err != nil {
+				return err
+			}
+		} else {
+			r.Log.Info("updating ing")
+			if err := r.Update(ctx, ing); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *DependencyReconciler) getIngress(
+	ctx context.Context,
+	dep *corev1alpha1.Dependency,
+	ingPlugins []*corev1alpha1.PluginIngress,
+	ingressClass string,
+	certManager string,
+) (*v1beta1.Ingress, bool, error) {
+
+	ns := ingPlugins[0].Namespace
+
+	desiredIng := r.desiredIngress(dep, ingPlugins, ingressClass, certManager)
+
+	ing := v1beta1.Ingress{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ns, Name: dep.Name}, &ing); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, false, err
+		}
+
+		ing := desiredIng
+		ctrl.SetControllerReference(dep, &ing, r.Scheme)
+
+		return &ing, false, nil
+	}
+
+	// make sure config matches
+	ing.Spec.Rules = desiredIng.Spec.Rules
+	ing.Spec.TLS = desiredIng.Spec.TLS
+	ing.Annotations = desiredIng.Annotations
+
+	return &ing, true, nil
+}
+
+func (r *DependencyReconciler) desiredIngress(
+	dep *corev1alpha1.Dependency,
+	ingPlugins []*corev1alpha1.PluginIngress,
+	ingressClass string,
+	certManager string,
+) v1beta1.Ingress {
+
+	ns := ingPlugins[0].Namespace
+
+	var rules []v1beta1.IngressRule
+	var hosts []string
+	for _, ingPlugin := range ingPlugins {
+		rules = append(rules, GenRulesOfIngressPlugin(ingPlugin)...)
+
+		hosts = append(hosts, ingPlugin.Hosts...)
+	}
+
+	// ref: https://github.com/Kong/kubernetes-ingress-controller/blob/master/docs/guides/cert-manager.md#request-tls-certificate-from-lets-encrypt
+	ing := v1beta1.Ingress{
+		TypeMeta: v1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "Ingress"},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      dep.Name,
+			Namespace: ns,
+			Annotations: map[string]string{
+				"kubernetes.io/ingress.class": ingressClass,
+			},
+		},
+		Spec: v1beta1.IngressSpec{
+			Rules: rules,
+		},
+	}
+
+	// certificates are issued by the ClusterIssuer of the cert-manager dependency
+	if cmName := certManager; cmName != "" {
+		ing.Spec.TLS = []v1beta1.IngressTLS{
+			{
+				Hosts: hosts,
+				//todo can not set ns here?
+				// todo should delete this secret if ing deleted
+				SecretName: "this-sec-name-does-not-matter-" + cmName,
+			},
+		}
+
+		if ing.Annotations == nil {
+			ing.Annotations = make(map[string]string)
+		}
+
+		ing.Annotations["kubernetes.io/tls-acme"] = "true"
+		ing.Annotations["cert-manager.io/cluster-issuer"] = cmName
+	}
+
+	return ing
+}
+
+// defaultIngressClass is the class of ingresses of other dependencies, e.g. grafana and kibana.
+// It's served by the ingress dependency of the cluster, kong if there is none.
+func (r *DependencyReconciler) defaultIngressClass(ctx context.Context) (string, error) {
+	var list corev1alpha1.DependencyList
+	if err := r.List(ctx, &list); err != nil {
+		return "", err
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+
+	for _, dep := range list.Items {
+		if class, exist := ingressClassOfDependencyType[dep.Spec.Type]; exist && dep.DeletionTimestamp.IsZero() {
+			return class, nil
+		}
+	}
+
+	return ingressClassKong, nil
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileIngressPlugins(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	_ = corev1alpha1.AddToScheme(r.Scheme)
+	ctx := context.Background()
+
+	class, err := r.defaultIngressClass(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, ingressClassKong, class)
+
+	dep := &corev1alpha1.Dependency{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", UID: "uid"},
+		Spec:       corev1alpha1.DependencySpec{Type: "ingress-nginx"},
+	}
+	assert.Nil(t, r.Create(ctx, dep))
+
+	class, err = r.defaultIngressClass(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, ingressClassNginx, class)
+
+	app := &corev1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: corev1alpha1.ApplicationSpec{
+			Components: []corev1alpha1.ComponentSpec{
+				{
+					Name:  "web",
+					Ports: []corev1alpha1.Port{{Name: "http", ContainerPort: 80}},
+					Plugins: []runtime.RawExtension{
+						{Raw: []byte(`{"name":"ing","type":"plugins.core.kapp.dev/v1alpha1.ingress","hosts":["app.example.com"],"path":"/"}`)},
+					},
+				},
+			},
+		},
+	}
+	assert.Nil(t, r.Create(ctx, app))
+
+	driver, err := r.getDriver("ingress-nginx")
+	assert.Nil(t, err)
+	assert.Nil(t, driver.Configure(ctx, dep, map[string]string{"cert-manager": "cm"}))
+
+	var ing v1beta1.Ingress
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "nginx"}, &ing))
+	assert.Equal(t, ingressClassNginx, ing.Annotations["kubernetes.io/ingress.class"])
+	assert.Equal(t, "cm", ing.Annotations["cert-manager.io/cluster-issuer"])
+	assert.Equal(t, "app.example.com", ing.Spec.Rules[0].Host)
+	assert.Equal(t, int32(80), ing.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort.IntVal)
+}
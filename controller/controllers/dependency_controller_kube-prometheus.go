@@ -2,143 +2,197 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
 	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *DependencyReconciler) reconcileKubePrometheus(ctx context.Context, d *corev1alpha1.Dependency) error {
-	operatorStatus, err := r.getDependencyInstallStatus("kapp-monitoring", []string{"prometheus-operator"}, nil)
+func init() {
+	registerDependencyDriver("kube-prometheus", func(r *DependencyReconciler) DependencyDriver {
+		return kubePrometheusDriver{bundledDriver{
+			DependencyReconciler: r,
+			namespace:            kubePromethuesNS,
+			// the operator and CRDs first, then other parts
+			versions: map[string][]string{"1.0.0": {"/kube-prometheus/setup", "/kube-prometheus"}},
+		}}
+	})
+}
+
+// kube-prometheus is installed in 2 stages,
+// the prometheus operator and CRDs first, then other parts which use the CRDs.
+type kubePrometheusDriver struct {
+	bundledDriver
+}
+
+// other parts, including
+// grafana
+// prome-adapter
+// kube-state-metrics
+//
+// alertmanager
+// prometheus-k8s(?)
+//
+// node-exporter
+var kubePrometheusOtherParts = []string{
+	"grafana",
+	"kube-state-metrics",
+	"prometheus-adapter",
+}
+
+func (kubePrometheusDriver) ConfigSchema() []corev1alpha1.DependencyConfigField {
+	return configSchemaOf("kube-prometheus")
+}
+
+func (r kubePrometheusDriver) Status(ctx context.Context, d *corev1alpha1.Dependency) (DepInstallStatus, []corev1alpha1.DependencyComponentStatus, error) {
+	operatorStatus, operatorComponents, err := r.getDependencyInstallStatus(kubePromethuesNS, []string{"prometheus-operator"}, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	otherPartsStatus, otherPartsComponents, err := r.getDependencyInstallStatus(kubePromethuesNS, kubePrometheusOtherParts, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	components := append(operatorComponents, otherPartsComponents...)
+
+	if operatorStatus != Installed {
+		return operatorStatus, components, nil
+	}
+
+	return otherPartsStatus, components, nil
+}
+
+func (r kubePrometheusDriver) Install(ctx context.Context, d *corev1alpha1.Dependency, version string) error {
+	manifests, err := r.manifestsOf(version)
 	if err != nil {
 		return err
 	}
 
-	switch operatorStatus {
-	case NotInstalled:
-		if err := r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusInstalling); err != nil {
-			return err
-		}
+	operatorStatus, _, err := r.getDependencyInstallStatus(kubePromethuesNS, []string{"prometheus-operator"}, nil)
+	if err != nil {
+		return err
+	}
 
+	if operatorStatus != Installed {
 		// try install Prometheus Operator
-		if err := r.reconcileExternalController(ctx, "/kube-prometheus/setup"); err != nil {
-			return err
-		}
+		return r.reconcileExternalController(ctx, manifests[0])
+	}
 
-		return retryLaterErr
-	case Installing:
-		r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusInstalling)
-		return retryLaterErr
-	case InstallFailed:
-		r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusInstallFailed)
-		return nil
-	case Installed:
-		r.Log.Info("prometheus operator installed")
+	r.Log.Info("prometheus operator installed, installing other parts")
+
+	return r.applyConfiguredManifests(ctx, d, manifests[1])
+}
+
+func (r kubePrometheusDriver) Upgrade(ctx context.Context, d *corev1alpha1.Dependency, version string) error {
+	manifests, err := r.manifestsOf(version)
+	if err != nil {
+		return err
 	}
 
-	// other parts
-	otherParts := []string{
-		"grafana",
-		"kube-state-metrics",
-		"prometheus-adapter",
+	if err := r.reconcileExternalController(ctx, manifests[0]); err != nil {
+		return err
 	}
-	otherPartsStatus, err := r.getDependencyInstallStatus("kapp-monitoring", otherParts, nil)
+
+	return r.applyConfiguredManifests(ctx, d, manifests[1])
+}
+
+// applyConfiguredManifests applies the bundled manifests with the config set on the Prometheus and the Alertmanager,
+// so applying them again to correct drift doesn't revert the config
+func (r kubePrometheusDriver) applyConfiguredManifests(ctx context.Context, d *corev1alpha1.Dependency, fileOrDirName string) error {
+	rawConfig, err := r.resolveDependencyConfig(ctx, d)
 	if err != nil {
 		return err
 	}
-	switch otherPartsStatus {
-	case NotInstalled:
-		r.Log.Info("prometheus operator otherParts not installed")
 
-		// try install remaining parts, including
-		// grafana
-		// prome-adapter
-		// kube-state-metrics
-		//
-		// alertmanager
-		// prometheus-k8s(?)
-		//
-		// node-exporter
+	config, err := corev1alpha1.ParseKubePrometheusConfig(rawConfig)
+	if err != nil {
+		return err
+	}
 
-		if err := r.reconcileExternalController(ctx, "/kube-prometheus"); err != nil {
+	files, _, err := loadFiles(fileOrDirName)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		objs := parseK8sYaml(file)
+
+		if err := r.configureKubePrometheusObjects(ctx, objs, config); err != nil {
 			return err
 		}
 
-		return retryLaterErr
-	case Installing:
-		r.Log.Info("prometheus operator otherParts installing")
-		r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusInstalling)
-		return retryLaterErr
-	case InstallFailed:
-		r.Log.Info("prometheus operator otherParts install failed")
-		r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusInstallFailed)
-		return nil
-	case Installed:
-		r.Log.Info("prometheus other parts installed too")
-		//r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusInstalled)
+		if err := r.applyMany(ctx, objs...); err != nil {
+			return err
+		}
 	}
 
-	// check config
-	config := d.Spec.Config
-	if config == nil {
-		return nil
-	}
+	return nil
+}
 
-	// config pv
-	var pvSize string
-	if v, exist := config["persistenVolumeSize"]; !exist || v == "" {
-		// default size, for dev
-		pvSize = "128Mi"
-	} else {
-		// todo check if valid
-		pvSize = v
+func (r kubePrometheusDriver) Configure(ctx context.Context, d *corev1alpha1.Dependency, rawConfig map[string]string) error {
+	config, err := corev1alpha1.ParseKubePrometheusConfig(rawConfig)
+	if err != nil {
+		return err
 	}
 
-	prometheus := monitoringv1.Prometheus{}
-	err = r.Get(ctx, types.NamespacedName{Namespace: "kapp-monitoring", Name: "k8s"}, &prometheus)
+	// the Prometheus and the Alertmanager are applied with the config, the same way as in Install and Upgrade
+	version := d.Status.InstalledVersion
+	manifests, err := r.manifestsOf(version)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			return retryLaterErr
-		}
+		return err
+	}
 
+	files, _, err := loadFiles(manifests[1])
+	if err != nil {
 		return err
 	}
 
-	// todo also update if size don't match
-	if prometheus.Spec.Storage == nil {
-		pvc := corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:              prometheus.Name,
-				Namespace:         prometheus.Namespace,
-				CreationTimestamp: metav1.Now(),
-			},
-			Spec: corev1.PersistentVolumeClaimSpec{
-				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-				Resources: corev1.ResourceRequirements{
-					Requests: corev1.ResourceList{
-						corev1.ResourceStorage: resource.MustParse(pvSize),
-					},
-				},
-				StorageClassName: nil,
-			},
+	var objs []runtime.Object
+	for _, file := range files {
+		for _, obj := range parseK8sYaml(file) {
+			switch obj.(type) {
+			case *monitoringv1.Prometheus, *monitoringv1.Alertmanager:
+				objs = append(objs, obj)
+			}
 		}
+	}
 
-		prometheus.Spec.Storage = &monitoringv1.StorageSpec{
-			VolumeClaimTemplate: pvc,
-		}
+	if len(objs) == 0 {
+		return fmt.Errorf("no Prometheus or Alertmanager in the manifests %s", manifests[1])
+	}
 
-		if err := r.Update(ctx, &prometheus); err != nil {
-			r.Log.Error(err, "fail to update prometheus pv")
-			return err
-		}
+	if err := r.configureKubePrometheusObjects(ctx, objs, config); err != nil {
+		return err
+	}
+
+	if err := r.applyMany(ctx, objs...); err != nil {
+		return err
+	}
+
+	if err := r.expandKubePrometheusVolumes(ctx, config); err != nil {
+		return err
 	}
 
 	ingPlugins := genIngressPluginsIfExist(config)
-	ing, exist, err := r.getIngress(ctx, d, ingPlugins)
+	if len(ingPlugins) == 0 {
+		return nil
+	}
+
+	ingressClass, err := r.defaultIngressClass(ctx)
+	if err != nil {
+		return err
+	}
+
+	ing, exist, err := r.getIngress(ctx, d, ingPlugins, ingressClass, config.CertManager)
 	if err != nil {
 		return err
 	}
@@ -159,7 +213,151 @@ func (r *DependencyReconciler) reconcileKubePrometheus(ctx context.Context, d *c
 
 	//todo what to do if dep is deleted? delete all prometheus infra?
 
-	return r.UpdateStatusIfNotMatch(ctx, d, corev1alpha1.DependencyStatusRunning)
+	return nil
+}
+
+// configureKubePrometheusObjects sets the config on the Prometheus and the Alertmanager of the bundled manifests,
+// the operator rolls out their statefulsets when the spec is changed. Other objects are not changed.
+func (r *DependencyReconciler) configureKubePrometheusObjects(ctx context.Context, objs []runtime.Object, config *corev1alpha1.KubePrometheusConfig) error {
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *monitoringv1.Prometheus:
+			var current *monitoringv1.Prometheus
+			var fetched monitoringv1.Prometheus
+			if err := r.Get(ctx, types.NamespacedName{Namespace: o.Namespace, Name: o.Name}, &fetched); err == nil {
+				current = &fetched
+			} else if !errors.IsNotFound(err) {
+				return err
+			}
+
+			configurePrometheus(o, current, config)
+		case *monitoringv1.Alertmanager:
+			var current *monitoringv1.Alertmanager
+			var fetched monitoringv1.Alertmanager
+			if err := r.Get(ctx, types.NamespacedName{Namespace: o.Namespace, Name: o.Name}, &fetched); err == nil {
+				current = &fetched
+			} else if !errors.IsNotFound(err) {
+				return err
+			}
+
+			configureAlertmanager(o, current, config)
+		}
+	}
+
+	return nil
+}
+
+// configurePrometheus sets the config on the Prometheus of the bundled manifests,
+// current is the Prometheus in the cluster, nil if it's not created yet
+func configurePrometheus(prometheus, current *monitoringv1.Prometheus, config *corev1alpha1.KubePrometheusConfig) {
+	// labels of the volumes created by the statefulset, from its selector
+	volumeLabels := map[string]string{"app": "prometheus", "prometheus": prometheus.Name}
+
+	var currentStorage *monitoringv1.StorageSpec
+	if current != nil {
+		currentStorage = current.Spec.Storage
+	}
+
+	prometheus.Spec.Retention = config.Retention
+	prometheus.Spec.Replicas = &config.PrometheusReplicas
+	prometheus.Spec.Resources.Requests = config.PrometheusResources
+	// the name of the template is kept, the volumes of existing installs are named after it
+	prometheus.Spec.Storage = desiredStorageSpec(currentStorage, prometheus.Name, volumeLabels, config.PersistentVolumeSize, config.StorageClass)
+}
+
+// configureAlertmanager sets the config on the Alertmanager of the bundled manifests,
+// current is the Alertmanager in the cluster, nil if it's not created yet
+func configureAlertmanager(alertmanager, current *monitoringv1.Alertmanager, config *corev1alpha1.KubePrometheusConfig) {
+	volumeLabels := map[string]string{"app": "alertmanager", "alertmanager": alertmanager.Name}
+
+	var currentStorage *monitoringv1.StorageSpec
+	if current != nil {
+		currentStorage = current.Spec.Storage
+	}
+
+	alertmanager.Spec.Retention = config.AlertmanagerRetention
+	alertmanager.Spec.Replicas = &config.AlertmanagerReplicas
+	alertmanager.Spec.Resources.Requests = config.AlertmanagerResources
+	alertmanager.Spec.Storage = desiredStorageSpec(currentStorage, "alertmanager-"+alertmanager.Name+"-db", volumeLabels, config.AlertmanagerStorage, config.StorageClass)
+}
+
+// expandKubePrometheusVolumes expands the volumes of prometheus and alertmanager to the configured sizes
+func (r *DependencyReconciler) expandKubePrometheusVolumes(ctx context.Context, config *corev1alpha1.KubePrometheusConfig) error {
+	if err := r.expandVolumes(ctx, kubePromethuesNS, map[string]string{"app": "prometheus", "prometheus": "k8s"}, config.PersistentVolumeSize); err != nil {
+		return err
+	}
+
+	if config.AlertmanagerStorage.IsZero() {
+		return nil
+	}
+
+	return r.expandVolumes(ctx, kubePromethuesNS, map[string]string{"app": "alertmanager", "alertmanager": "main"}, config.AlertmanagerStorage)
+}
+
+// desiredStorageSpec is the volume claim template of the statefulset of prometheus or alertmanager,
+// nil for emptyDir volumes if the size is zero.
+func desiredStorageSpec(current *monitoringv1.StorageSpec, name string, labels map[string]string, size resource.Quantity, storageClass string) *monitoringv1.StorageSpec {
+	if size.IsZero() {
+		return nil
+	}
+
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+			// the CRD of the operator doesn't accept a null creationTimestamp
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: size,
+				},
+			},
+		},
+	}
+
+	if current != nil && current.VolumeClaimTemplate.Name == name {
+		pvc.CreationTimestamp = current.VolumeClaimTemplate.CreationTimestamp
+	}
+
+	if storageClass != "" {
+		pvc.Spec.StorageClassName = &storageClass
+	}
+
+	return &monitoringv1.StorageSpec{VolumeClaimTemplate: pvc}
+}
+
+// expandVolumes expands the volumes created by a statefulset if a bigger size is configured,
+// volumes of statefulsets are not changed with the template once created.
+// The storage class of the volumes should allow expansion.
+func (r *DependencyReconciler) expandVolumes(ctx context.Context, namespace string, labels map[string]string, size resource.Quantity) error {
+	var list corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &list, client.InNamespace(namespace), client.MatchingLabels(labels)); err != nil {
+		return err
+	}
+
+	for i := range list.Items {
+		pvc := &list.Items[i]
+
+		current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if size.Cmp(current) <= 0 {
+			continue
+		}
+
+		r.Log.Info("expanding volume", "volume", pvc.Name, "from", current.String(), "to", size.String())
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = size
+
+		if err := r.Update(ctx, pvc); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 const (
@@ -167,8 +365,8 @@ const (
 	pluginIngress    = "plugins.core.kapp.dev/v1alpha1.ingress"
 )
 
-func genIngressPluginsIfExist(config map[string]string) (rst []*corev1alpha1.PluginIngress) {
-	if gHost, exist := config["grafanaHost"]; exist {
+func genIngressPluginsIfExist(config *corev1alpha1.KubePrometheusConfig) (rst []*corev1alpha1.PluginIngress) {
+	if gHost := config.GrafanaHost; gHost != "" {
 		rst = append(rst, &corev1alpha1.PluginIngress{
 			Name:        "grafana",
 			Type:        pluginIngress,
@@ -179,7 +377,7 @@ func genIngressPluginsIfExist(config map[string]string) (rst []*corev1alpha1.Plu
 		})
 	}
 
-	if pHost, exist := config["prometheusHost"]; exist {
+	if pHost := config.PrometheusHost; pHost != "" {
 		rst = append(rst, &corev1alpha1.PluginIngress{
 			Name:        "prometheus",
 			Type:        pluginIngress,
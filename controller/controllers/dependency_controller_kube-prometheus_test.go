@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// the Prometheus and the Alertmanager of the bundled manifests
+func bundledKubePrometheusObjects(t *testing.T) (*monitoringv1.Prometheus, *monitoringv1.Alertmanager) {
+	var prometheus *monitoringv1.Prometheus
+	var alertmanager *monitoringv1.Alertmanager
+
+	files, _, err := loadFiles("/kube-prometheus")
+	assert.Nil(t, err)
+	for _, file := range files {
+		for _, obj := range parseK8sYaml(file) {
+			switch o := obj.(type) {
+			case *monitoringv1.Prometheus:
+				prometheus = o
+			case *monitoringv1.Alertmanager:
+				alertmanager = o
+			}
+		}
+	}
+
+	assert.NotNil(t, prometheus)
+	assert.NotNil(t, alertmanager)
+
+	return prometheus, alertmanager
+}
+
+func TestConfigurePrometheus(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	ctx := context.Background()
+
+	config, err := corev1alpha1.ParseKubePrometheusConfig(map[string]string{
+		"persistenVolumeSize":     "1Gi",
+		"storageClass":            "ssd",
+		"retention":               "7d",
+		"prometheusReplicas":      "1",
+		"prometheusMemoryRequest": "1Gi",
+	})
+	assert.Nil(t, err)
+
+	prometheus, _ := bundledKubePrometheusObjects(t)
+	assert.Equal(t, "30d", prometheus.Spec.Retention)
+
+	// not created yet
+	assert.Nil(t, r.configureKubePrometheusObjects(ctx, []runtime.Object{prometheus}, config))
+	assert.Equal(t, "7d", prometheus.Spec.Retention)
+	assert.Equal(t, int32(1), *prometheus.Spec.Replicas)
+	memory := prometheus.Spec.Resources.Requests[corev1.ResourceMemory]
+	assert.Equal(t, "1Gi", memory.String())
+	// other fields of the manifests are kept
+	assert.Equal(t, "prometheus-k8s", prometheus.Spec.ServiceAccountName)
+
+	template := prometheus.Spec.Storage.VolumeClaimTemplate
+	assert.Equal(t, "k8s", template.Name)
+	assert.Equal(t, "ssd", *template.Spec.StorageClassName)
+	storage := template.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "1Gi", storage.String())
+
+	assert.Nil(t, r.Create(ctx, prometheus))
+
+	// the objects applied again are the same, so the spec is not changed by applying the manifests to correct drift
+	again, _ := bundledKubePrometheusObjects(t)
+	assert.Nil(t, r.configureKubePrometheusObjects(ctx, []runtime.Object{again}, config))
+	created := monitoringv1.Prometheus{}
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: kubePromethuesNS, Name: "k8s"}, &created))
+	assert.True(t, equality.Semantic.DeepEqual(created.Spec, again.Spec))
+
+	volume := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: kubePromethuesNS,
+			Name:      "k8s-prometheus-k8s-0",
+			Labels:    map[string]string{"app": "prometheus", "prometheus": "k8s"},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("128Mi")},
+			},
+		},
+	}
+	assert.Nil(t, r.Create(ctx, &volume))
+
+	// existing volumes are expanded
+	assert.Nil(t, r.expandKubePrometheusVolumes(ctx, config))
+	volume = corev1.PersistentVolumeClaim{}
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: kubePromethuesNS, Name: "k8s-prometheus-k8s-0"}, &volume))
+	storage = volume.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "1Gi", storage.String())
+}
+
+func TestConfigureAlertmanager(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	ctx := context.Background()
+
+	config, err := corev1alpha1.ParseKubePrometheusConfig(nil)
+	assert.Nil(t, err)
+
+	_, alertmanager := bundledKubePrometheusObjects(t)
+	assert.Nil(t, r.configureKubePrometheusObjects(ctx, []runtime.Object{alertmanager}, config))
+	assert.Equal(t, int32(3), *alertmanager.Spec.Replicas)
+	assert.Equal(t, "120h", alertmanager.Spec.Retention)
+	// emptyDir by default
+	assert.Nil(t, alertmanager.Spec.Storage)
+
+	config, err = corev1alpha1.ParseKubePrometheusConfig(map[string]string{"alertmanagerStorage": "1Gi", "alertmanagerReplicas": "1"})
+	assert.Nil(t, err)
+
+	_, alertmanager = bundledKubePrometheusObjects(t)
+	assert.Nil(t, r.configureKubePrometheusObjects(ctx, []runtime.Object{alertmanager}, config))
+	assert.Equal(t, int32(1), *alertmanager.Spec.Replicas)
+	assert.Equal(t, "alertmanager-main-db", alertmanager.Spec.Storage.VolumeClaimTemplate.Name)
+	assert.Nil(t, alertmanager.Spec.Storage.VolumeClaimTemplate.Spec.StorageClassName)
+}
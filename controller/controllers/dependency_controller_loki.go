@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	nsKappLoki     = "kapp-loki"
+	lokiPVCName    = "loki-storage"
+	lokiDpName     = "loki"
+	promtailDsName = "promtail"
+)
+
+func init() {
+	registerDependencyDriver("loki", func(r *DependencyReconciler) DependencyDriver {
+		return lokiDriver{bundledDriver{
+			DependencyReconciler: r,
+			namespace:            nsKappLoki,
+			versions:             map[string][]string{"1.0.0": {"loki_1.0.0.yaml"}},
+			dpNames:              []string{lokiDpName},
+			dsNames:              []string{promtailDsName},
+		}}
+	})
+}
+
+// lokiDriver installs a single binary loki, and promtail on each node which ships logs of all pods to loki.
+// The volume of loki is created from the config before the bundled manifests are applied,
+// it's not owned by the Dependency, so it's kept with the namespace if dep.Spec.KeepData is set.
+type lokiDriver struct {
+	bundledDriver
+}
+
+func (lokiDriver) ConfigSchema() []corev1alpha1.DependencyConfigField {
+	return configSchemaOf("loki")
+}
+
+func (r lokiDriver) Install(ctx context.Context, dep *corev1alpha1.Dependency, version string) error {
+	if err := r.reconcileLokiStorage(ctx, dep); err != nil {
+		return err
+	}
+
+	return r.bundledDriver.Install(ctx, dep, version)
+}
+
+func (r lokiDriver) Upgrade(ctx context.Context, dep *corev1alpha1.Dependency, version string) error {
+	return r.Install(ctx, dep, version)
+}
+
+func (r lokiDriver) Configure(ctx context.Context, dep *corev1alpha1.Dependency, config map[string]string) error {
+	lokiConfig, err := corev1alpha1.ParseLokiConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return r.reconcileLokiPVC(ctx, lokiConfig)
+}
+
+func (r lokiDriver) reconcileLokiStorage(ctx context.Context, dep *corev1alpha1.Dependency) error {
+	config, err := r.resolveDependencyConfig(ctx, dep)
+	if err != nil {
+		return err
+	}
+
+	lokiConfig, err := corev1alpha1.ParseLokiConfig(config)
+	if err != nil {
+		return err
+	}
+
+	// the namespace is in the bundled manifests too, it's created here as the volume is in it
+	ns := corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: nsKappLoki}, &ns); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		ns = corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: nsKappLoki}}
+		if err := r.Create(ctx, &ns); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return r.reconcileLokiPVC(ctx, lokiConfig)
+}
+
+// reconcileLokiPVC creates the volume of loki, and expands it if a bigger storage is configured.
+// The storage class can't be changed once created.
+func (r *DependencyReconciler) reconcileLokiPVC(ctx context.Context, config *corev1alpha1.LokiConfig) error {
+	pvc := corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: nsKappLoki, Name: lokiPVCName}, &pvc); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		pvc = corev1.PersistentVolumeClaim{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: nsKappLoki,
+				Name:      lokiPVCName,
+				Labels:    map[string]string{"app": "loki"},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: config.Storage,
+					},
+				},
+			},
+		}
+
+		if config.StorageClass != "" {
+			storageClass := config.StorageClass
+			pvc.Spec.StorageClassName = &storageClass
+		}
+
+		r.Log.Info("creating loki volume", "storage", config.Storage.String())
+		return r.Create(ctx, &pvc)
+	}
+
+	current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if config.Storage.Cmp(current) <= 0 {
+		return nil
+	}
+
+	r.Log.Info("expanding loki volume", "from", current.String(), "to", config.Storage.String())
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = config.Storage
+
+	return r.Update(ctx, &pvc)
+}
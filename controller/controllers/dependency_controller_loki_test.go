@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileLokiPVC(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	ctx := context.Background()
+
+	config, err := corev1alpha1.ParseLokiConfig(map[string]string{"storage": "2Gi", "storageClass": "ssd"})
+	assert.Nil(t, err)
+	assert.Nil(t, r.reconcileLokiPVC(ctx, config))
+
+	pvc := corev1.PersistentVolumeClaim{}
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: nsKappLoki, Name: lokiPVCName}, &pvc))
+	assert.Equal(t, "ssd", *pvc.Spec.StorageClassName)
+	storage := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "2Gi", storage.String())
+
+	// expanded, never shrunk
+	config, _ = corev1alpha1.ParseLokiConfig(map[string]string{"storage": "5Gi"})
+	assert.Nil(t, r.reconcileLokiPVC(ctx, config))
+	config, _ = corev1alpha1.ParseLokiConfig(map[string]string{"storage": "1Gi"})
+	assert.Nil(t, r.reconcileLokiPVC(ctx, config))
+
+	pvc = corev1.PersistentVolumeClaim{}
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Namespace: nsKappLoki, Name: lokiPVCName}, &pvc))
+	storage = pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	assert.Equal(t, "5Gi", storage.String())
+	assert.Equal(t, "ssd", *pvc.Spec.StorageClassName)
+}
+
+func TestGetDsStatus(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	ctx := context.Background()
+
+	status, component, err := r.getDsStatus(nsKappLoki, promtailDsName)
+	assert.Nil(t, err)
+	assert.Equal(t, DepInstallStatus(NotInstalled), status)
+	assert.Equal(t, "DaemonSet", component.Kind)
+
+	ds := appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: nsKappLoki, Name: promtailDsName},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "promtail"}},
+		},
+		Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, NumberReady: 2},
+	}
+	assert.Nil(t, r.Create(ctx, &ds))
+
+	status, component, err = r.getDsStatus(nsKappLoki, promtailDsName)
+	assert.Nil(t, err)
+	assert.Equal(t, DepInstallStatus(Installing), status)
+	assert.Equal(t, int32(2), component.Ready)
+	assert.Equal(t, int32(3), component.Desired)
+
+	ds.Status.NumberReady = 3
+	assert.Nil(t, r.Update(ctx, &ds))
+
+	status, _, err = r.getDsStatus(nsKappLoki, promtailDsName)
+	assert.Nil(t, err)
+	assert.Equal(t, DepInstallStatus(Installed), status)
+}
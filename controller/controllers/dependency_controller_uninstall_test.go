@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	monitoringv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeDependencyReconciler() *DependencyReconciler {
+	sch := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(sch)
+	_ = apiextv1beta1.AddToScheme(sch)
+	_ = monitoringv1.AddToScheme(sch)
+
+	return &DependencyReconciler{
+		Client: fake.NewFakeClientWithScheme(sch),
+		Log:    ctrl.Log.WithName("test"),
+		Scheme: sch,
+	}
+}
+
+func TestUninstallExternalController(t *testing.T) {
+	ctx := context.Background()
+
+	for _, keepData := range []bool{true, false} {
+		r := newFakeDependencyReconciler()
+
+		// the fake client doesn't support server-side apply
+		files, _, err := loadFiles("kong_1.0.0.yaml")
+		assert.Nil(t, err)
+		for _, obj := range parseK8sYaml(files[0]) {
+			assert.Nil(t, r.Create(ctx, obj))
+		}
+
+		// objects are deleted, wait for them to disappear
+		assert.Equal(t, retryLaterErr, r.uninstallExternalController(ctx, keepData, "kong_1.0.0.yaml"))
+		assert.Nil(t, r.uninstallExternalController(ctx, keepData, "kong_1.0.0.yaml"))
+
+		var ns corev1.Namespace
+		err = r.Get(ctx, types.NamespacedName{Name: "kapp-kong"}, &ns)
+		assert.Equal(t, keepData, err == nil, "keepData: %v", keepData)
+
+		// CRDs are kept, with the objects of them
+		var crds apiextv1beta1.CustomResourceDefinitionList
+		assert.Nil(t, r.List(ctx, &crds))
+		assert.NotEmpty(t, crds.Items)
+	}
+}
+
+// namespaceTerminatingClient fails to delete namespaces the way the api server does for terminating namespaces
+type namespaceTerminatingClient struct {
+	client.Client
+}
+
+func (c namespaceTerminatingClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	if ns, ok := obj.(*corev1.Namespace); ok {
+		return errors.NewConflict(corev1.Resource("namespaces"), ns.Name, fmt.Errorf("namespace is terminating"))
+	}
+
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestUninstallExternalControllerTerminatingNamespace(t *testing.T) {
+	ctx := context.Background()
+	r := newFakeDependencyReconciler()
+
+	files, _, err := loadFiles("kong_1.0.0.yaml")
+	assert.Nil(t, err)
+	for _, obj := range parseK8sYaml(files[0]) {
+		assert.Nil(t, r.Create(ctx, obj))
+	}
+
+	r.Client = namespaceTerminatingClient{r.Client}
+
+	// waiting for the namespace to disappear, not failed
+	assert.Equal(t, retryLaterErr, r.uninstallExternalController(ctx, false, "kong_1.0.0.yaml"))
+}
@@ -0,0 +1,509 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DependencyDriver installs and manages one type of Dependency.
+// The DependencyReconciler runs the install state machine and calls the driver at each step,
+// so a new type of dependency can be added by registering a driver, without changing the reconciler.
+type DependencyDriver interface {
+	// Status returns the install status of the components the driver installs, and the status of each component
+	Status(ctx context.Context, dep *corev1alpha1.Dependency) (DepInstallStatus, []corev1alpha1.DependencyComponentStatus, error)
+	// Install applies the bundled manifests of the version, it's called until Status returns Installed
+	Install(ctx context.Context, dep *corev1alpha1.Dependency, version string) error
+	// Configure reconciles the resources derived from the config once installed,
+	// config is dep.Spec.Config with the values of dep.Spec.SecretRefs
+	Configure(ctx context.Context, dep *corev1alpha1.Dependency, config map[string]string) error
+	// Upgrade applies all the bundled manifests of the version to an installed dependency,
+	// it's also called periodically with the installed version to correct drift
+	Upgrade(ctx context.Context, dep *corev1alpha1.Dependency, version string) error
+	// Uninstall removes what Install created, it's called when the Dependency is being deleted,
+	// until it returns nil. dep.Spec.KeepData asks to preserve PersistentVolumeClaims.
+	Uninstall(ctx context.Context, dep *corev1alpha1.Dependency, version string) error
+	// ConfigSchema describes the keys of dep.Spec.Config
+	ConfigSchema() []corev1alpha1.DependencyConfigField
+	// ManifestObjects returns the objects of the bundled manifests of the version, which Install and Upgrade apply
+	ManifestObjects(version string) ([]runtime.Object, error)
+}
+
+type dependencyDriverFactory func(r *DependencyReconciler) DependencyDriver
+
+var dependencyDrivers = make(map[string]dependencyDriverFactory)
+
+// bundled manifests are applied again after this interval, to correct drift
+var driftCheckInterval = 10 * time.Minute
+
+// registerDependencyDriver makes a type of dependency available to the DependencyReconciler.
+// The type must also be registered in v1alpha1, which is what the api lists as available dependencies.
+func registerDependencyDriver(depType string, factory dependencyDriverFactory) {
+	if _, exist := corev1alpha1.GetDependencyTypeInfo(depType); !exist {
+		panic("dependency type is not registered in v1alpha1: " + depType)
+	}
+
+	if _, exist := dependencyDrivers[depType]; exist {
+		panic("dependency driver registered twice: " + depType)
+	}
+
+	dependencyDrivers[depType] = factory
+}
+
+func (r *DependencyReconciler) getDriver(depType string) (DependencyDriver, error) {
+	factory, exist := dependencyDrivers[depType]
+	if !exist {
+		return nil, fmt.Errorf("unkonwn dependency: %s", depType)
+	}
+
+	return factory(r), nil
+}
+
+// the version which should be installed, the newest one if not specified
+func desiredDependencyVersion(dep *corev1alpha1.Dependency) string {
+	if dep.Spec.Version != "" {
+		return dep.Spec.Version
+	}
+
+	info, _ := corev1alpha1.GetDependencyTypeInfo(dep.Spec.Type)
+	return info.DefaultVersion()
+}
+
+// preflightUpgrade checks if an installed dependency can be upgraded between the versions
+func preflightUpgrade(info corev1alpha1.DependencyTypeInfo, from, to string) error {
+	depType := info.Type
+
+	toIdx := info.VersionIndex(to)
+	if toIdx < 0 {
+		return fmt.Errorf("version %s of %s is not available", to, depType)
+	}
+
+	fromIdx := info.VersionIndex(from)
+	if fromIdx < 0 {
+		return fmt.Errorf("installed version %s of %s is unknown, can't upgrade from it", from, depType)
+	}
+
+	if toIdx < fromIdx {
+		return fmt.Errorf("downgrade of %s from %s to %s is not supported", depType, from, to)
+	}
+
+	return nil
+}
+
+// 1. install the version of the dependency with the driver, wait until it's installed
+// 2. upgrade it if the version is changed, or apply the manifests again to correct drift
+// 3. configure it and mark it Running
+// the status of dep is updated in memory along the way
+func (r *DependencyReconciler) reconcileWithDriver(ctx context.Context, driver DependencyDriver, dep *corev1alpha1.Dependency) error {
+	version := desiredDependencyVersion(dep)
+	installedVersion := dep.Status.InstalledVersion
+	upgrading := installedVersion != "" && installedVersion != version
+
+	dep.Status.ObservedGeneration = dep.Generation
+
+	status, components, err := driver.Status(ctx, dep)
+	if err != nil {
+		return err
+	}
+
+	dep.Status.Components = components
+
+	r.Log.Info("dependency install status", "type", dep.Spec.Type, "status", status,
+		"version", version, "installedVersion", installedVersion)
+
+	if status == Installed {
+		setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionInstalled, corev1.ConditionTrue, "Installed", "")
+	} else {
+		reason := map[DepInstallStatus]string{
+			NotInstalled:  "NotInstalled",
+			Installing:    "Installing",
+			InstallFailed: "InstallFailed",
+		}[status]
+
+		setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionInstalled, corev1.ConditionFalse, reason, componentsNotReadyMessage(components))
+		setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionReady, corev1.ConditionFalse, reason, "")
+	}
+
+	if upgrading {
+		setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionUpToDate, corev1.ConditionFalse, "Upgrading",
+			fmt.Sprintf("upgrading from %s to %s", installedVersion, version))
+	}
+
+	switch status {
+	case NotInstalled:
+		info, _ := corev1alpha1.GetDependencyTypeInfo(dep.Spec.Type)
+		if info.VersionIndex(version) < 0 {
+			err := fmt.Errorf("version %s of %s is not available", version, dep.Spec.Type)
+			r.Log.Error(err, "")
+			setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionInstalled, corev1.ConditionFalse, "VersionNotAvailable", err.Error())
+			return r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusInstallFailed)
+		}
+
+		if err := r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusInstalling); err != nil {
+			return err
+		}
+
+		applied, err := newAppliedManifests(driver, version)
+		if err != nil {
+			return err
+		}
+
+		if err := driver.Install(ctx, dep, version); err != nil {
+			setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionInstalled, corev1.ConditionFalse, "ApplyFailed", err.Error())
+			return err
+		}
+
+		if err := r.markManifestsApplied(ctx, dep, applied); err != nil {
+			return err
+		}
+
+		return retryLaterErr
+	case Installing:
+		// wait
+		if upgrading {
+			if err := r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusUpgrading); err != nil {
+				return err
+			}
+		} else {
+			if err := r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusInstalling); err != nil {
+				return err
+			}
+		}
+		return retryLaterErr
+	case InstallFailed:
+		// failed, nothing can be done
+		return r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusInstallFailed)
+	case Installed:
+		// go on
+	}
+
+	applied, err := newAppliedManifests(driver, version)
+	if err != nil {
+		return err
+	}
+
+	previous := dep.Status.AppliedManifests
+
+	if upgrading && (previous == nil || previous.Version != version) {
+		info, _ := corev1alpha1.GetDependencyTypeInfo(dep.Spec.Type)
+		if err := preflightUpgrade(info, installedVersion, version); err != nil {
+			r.Log.Error(err, "pre-flight check of upgrade failed")
+			setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionUpToDate, corev1.ConditionFalse, "PreflightFailed", err.Error())
+			return r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusUpgradeFailed)
+		}
+
+		if err := r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusUpgrading); err != nil {
+			return err
+		}
+
+		r.Log.Info("upgrading dependency", "from", installedVersion, "to", version)
+		if err := driver.Upgrade(ctx, dep, version); err != nil {
+			setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionUpToDate, corev1.ConditionFalse, "ApplyFailed", err.Error())
+			return err
+		}
+
+		if err := r.markManifestsApplied(ctx, dep, applied); err != nil {
+			return err
+		}
+
+		return retryLaterErr
+	}
+
+	// the manifests of a version change with the controller, they are applied once they are changed
+	changed := previous == nil || previous.Version != version || previous.Hash != applied.Hash
+
+	if !upgrading && (changed || time.Since(previous.AppliedAt.Time) > driftCheckInterval) {
+		r.Log.Info("applying manifests to correct drift", "version", version, "changed", changed)
+		if err := driver.Upgrade(ctx, dep, version); err != nil {
+			setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionUpToDate, corev1.ConditionFalse, "ApplyFailed", err.Error())
+			return err
+		}
+
+		if err := r.markManifestsApplied(ctx, dep, applied); err != nil {
+			return err
+		}
+	}
+
+	dep.Status.InstalledVersion = version
+	setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionUpToDate, corev1.ConditionTrue, "UpToDate", "")
+
+	config, err := r.resolveDependencyConfig(ctx, dep)
+	if err != nil {
+		setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionConfigured, corev1.ConditionFalse, "SecretRefFailed", err.Error())
+		return err
+	}
+
+	if err := driver.Configure(ctx, dep, config); err != nil {
+		if isRetryLaterErr(err) {
+			setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionConfigured, corev1.ConditionFalse, "Waiting", "")
+		} else {
+			setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionConfigured, corev1.ConditionFalse, "ConfigureFailed", err.Error())
+		}
+
+		return err
+	}
+
+	setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionConfigured, corev1.ConditionTrue, "Configured", "")
+	setDependencyCondition(&dep.Status, corev1alpha1.DependencyConditionReady, corev1.ConditionTrue, "Running", "")
+
+	return r.UpdateStatusIfNotMatch(ctx, dep, corev1alpha1.DependencyStatusRunning)
+}
+
+// e.g. "Deployment cert-manager/cert-manager 0/1: pod xxx: container yyy: ImagePullBackOff"
+func componentsNotReadyMessage(components []corev1alpha1.DependencyComponentStatus) string {
+	var msgs []string
+	for _, c := range components {
+		if c.LastError == "" && c.Desired > 0 && c.Ready >= c.Desired {
+			continue
+		}
+
+		msg := fmt.Sprintf("%s %s/%s %d/%d", c.Kind, c.Namespace, c.Name, c.Ready, c.Desired)
+		if c.LastError != "" {
+			msg += ": " + c.LastError
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// newAppliedManifests returns the record of the bundled manifests of the version, before they are applied
+func newAppliedManifests(driver DependencyDriver, version string) (*corev1alpha1.DependencyAppliedManifests, error) {
+	objs, err := driver.ManifestObjects(version)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := &corev1alpha1.DependencyAppliedManifests{Version: version}
+	h := sha256.New()
+
+	for _, obj := range objs {
+		bts, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		h.Write(bts)
+
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		applied.Objects = append(applied.Objects, corev1alpha1.DependencyAppliedObject{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Namespace:  accessor.GetNamespace(),
+			Name:       accessor.GetName(),
+		})
+	}
+
+	applied.Hash = hex.EncodeToString(h.Sum(nil))
+
+	return applied, nil
+}
+
+// markManifestsApplied saves the applied manifests in the status of the dependency,
+// objects applied last time which are not in them any more are deleted
+func (r *DependencyReconciler) markManifestsApplied(ctx context.Context, dep *corev1alpha1.Dependency, applied *corev1alpha1.DependencyAppliedManifests) error {
+	if previous := dep.Status.AppliedManifests; previous != nil {
+		if err := r.pruneObjects(ctx, previous.Objects, applied.Objects); err != nil {
+			return err
+		}
+	}
+
+	applied.AppliedAt = metav1.Now()
+	dep.Status.AppliedManifests = applied
+
+	return nil
+}
+
+// bundledDriver is embedded by drivers which install a set of bundled manifests
+type bundledDriver struct {
+	*DependencyReconciler
+	namespace string
+	// files or dirs under resources of each version, applied in order
+	versions map[string][]string
+	dpNames  []string
+	stsNames []string
+	dsNames  []string
+}
+
+func (d bundledDriver) manifestsOf(version string) ([]string, error) {
+	manifests, exist := d.versions[version]
+	if !exist {
+		return nil, fmt.Errorf("no bundled manifests for version %s", version)
+	}
+
+	return manifests, nil
+}
+
+func (d bundledDriver) ManifestObjects(version string) ([]runtime.Object, error) {
+	manifests, err := d.manifestsOf(version)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []runtime.Object
+	for _, manifest := range manifests {
+		files, _, err := loadFiles(manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			objs = append(objs, parseK8sYaml(file)...)
+		}
+	}
+
+	return objs, nil
+}
+
+func (d bundledDriver) Status(ctx context.Context, dep *corev1alpha1.Dependency) (DepInstallStatus, []corev1alpha1.DependencyComponentStatus, error) {
+	status, components, err := d.getDependencyInstallStatus(d.namespace, d.dpNames, d.stsNames)
+	if err != nil || len(d.dsNames) == 0 {
+		return status, components, err
+	}
+
+	dsStatus, dsComponents, err := d.getDaemonSetsInstallStatus(d.namespace, d.dsNames)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return rollUpInstallStatus([]DepInstallStatus{status, dsStatus}), append(components, dsComponents...), nil
+}
+
+func (d bundledDriver) Install(ctx context.Context, dep *corev1alpha1.Dependency, version string) error {
+	manifests, err := d.manifestsOf(version)
+	if err != nil {
+		return err
+	}
+
+	for _, manifest := range manifests {
+		if err := d.reconcileExternalController(ctx, manifest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d bundledDriver) Upgrade(ctx context.Context, dep *corev1alpha1.Dependency, version string) error {
+	return d.Install(ctx, dep, version)
+}
+
+// objects created in Configure are owned by the Dependency and removed by the garbage collector
+func (d bundledDriver) Uninstall(ctx context.Context, dep *corev1alpha1.Dependency, version string) error {
+	manifests, err := d.manifestsOf(version)
+	if err != nil {
+		return err
+	}
+
+	return d.uninstallExternalController(ctx, dep.Spec.KeepData, manifests...)
+}
+
+// resolveDependencyConfig returns a copy of dep.Spec.Config, with the values of dep.Spec.SecretRefs read from the Secrets
+func (r *DependencyReconciler) resolveDependencyConfig(ctx context.Context, dep *corev1alpha1.Dependency) (map[string]string, error) {
+	config := make(map[string]string, len(dep.Spec.Config)+len(dep.Spec.SecretRefs))
+	for k, v := range dep.Spec.Config {
+		config[k] = v
+	}
+
+	for k, ref := range dep.Spec.SecretRefs {
+		var sec corev1.Secret
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &sec); err != nil {
+			return nil, fmt.Errorf("fail to get secret %s/%s of config %s: %s", ref.Namespace, ref.Name, k, err)
+		}
+
+		v, exist := sec.Data[ref.Key]
+		if !exist {
+			return nil, fmt.Errorf("no key %s in secret %s/%s of config %s", ref.Key, ref.Namespace, ref.Name, k)
+		}
+
+		config[k] = string(v)
+	}
+
+	return config, nil
+}
+
+// sensitive config set in plain text before it had to be referenced from a Secret is moved into a Secret in this namespace,
+// which is where the controller is deployed
+var dependencyConfigSecretNamespace = "kapp-system"
+
+func getConfigSecNameForDependency(dep *corev1alpha1.Dependency) string {
+	return "dependency-" + dep.Name + "-config"
+}
+
+// migrateSecretConfig moves sensitive values in dep.Spec.Config into a Secret owned by the dependency,
+// and references them in dep.Spec.SecretRefs instead. It returns whether dep is updated.
+func (r *DependencyReconciler) migrateSecretConfig(ctx context.Context, dep *corev1alpha1.Dependency) (bool, error) {
+	info, _ := corev1alpha1.GetDependencyTypeInfo(dep.Spec.Type)
+
+	data := make(map[string][]byte)
+	for _, field := range info.ConfigSchema {
+		if v, exist := dep.Spec.Config[field.Name]; exist && field.Secret {
+			data[field.Name] = []byte(v)
+		}
+	}
+
+	if len(data) == 0 {
+		return false, nil
+	}
+
+	sec := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: dependencyConfigSecretNamespace,
+			Name:      getConfigSecNameForDependency(dep),
+		},
+	}
+
+	if _, err := ctrl.CreateOrUpdate(ctx, r.Client, &sec, func() error {
+		if sec.Data == nil {
+			sec.Data = make(map[string][]byte, len(data))
+		}
+
+		for k, v := range data {
+			sec.Data[k] = v
+		}
+
+		return ctrl.SetControllerReference(dep, &sec, r.Scheme)
+	}); err != nil {
+		return false, err
+	}
+
+	if dep.Spec.SecretRefs == nil {
+		dep.Spec.SecretRefs = make(map[string]corev1alpha1.DependencySecretKeyRef, len(data))
+	}
+
+	for k := range data {
+		delete(dep.Spec.Config, k)
+		dep.Spec.SecretRefs[k] = corev1alpha1.DependencySecretKeyRef{
+			Namespace: sec.Namespace,
+			Name:      sec.Name,
+			Key:       k,
+		}
+	}
+
+	r.Log.Info("moved sensitive config into secret", "dependency", dep.Name, "secret", sec.Namespace+"/"+sec.Name)
+
+	return true, r.Update(ctx, dep)
+}
+
+func configSchemaOf(depType string) []corev1alpha1.DependencyConfigField {
+	info, _ := corev1alpha1.GetDependencyTypeInfo(depType)
+	return info.ConfigSchema
+}
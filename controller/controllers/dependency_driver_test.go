@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1alpha1 "github.com/kapp-staging/kapp/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDependencyDriverRegistry(t *testing.T) {
+	r := &DependencyReconciler{}
+
+	for _, info := range corev1alpha1.ListDependencyTypes() {
+		driver, err := r.getDriver(info.Type)
+		assert.Nil(t, err, info.Type)
+		assert.Equal(t, info.ConfigSchema, driver.ConfigSchema())
+
+		for _, version := range info.Versions {
+			manifests, err := driver.(interface {
+				manifestsOf(version string) ([]string, error)
+			}).manifestsOf(version)
+			assert.Nil(t, err, info.Type)
+
+			for _, manifest := range manifests {
+				files, _, err := loadFiles(manifest)
+				assert.Nil(t, err, manifest)
+				assert.NotEmpty(t, files, manifest)
+			}
+		}
+	}
+
+	assert.Equal(t, len(corev1alpha1.ListDependencyTypes()), len(dependencyDrivers))
+
+	_, err := r.getDriver("not-exist")
+	assert.NotNil(t, err)
+}
+
+func TestPreflightUpgrade(t *testing.T) {
+	info := corev1alpha1.DependencyTypeInfo{
+		Type:     "test",
+		Versions: []string{"1.0.0", "1.1.0", "2.0.0"},
+	}
+
+	assert.Nil(t, preflightUpgrade(info, "1.0.0", "1.1.0"))
+	assert.Nil(t, preflightUpgrade(info, "1.0.0", "2.0.0"))
+	assert.NotNil(t, preflightUpgrade(info, "2.0.0", "1.1.0"), "downgrade")
+	assert.NotNil(t, preflightUpgrade(info, "1.0.0", "3.0.0"), "unknown target")
+	assert.NotNil(t, preflightUpgrade(info, "0.9.0", "1.0.0"), "unknown installed version")
+}
+
+func TestMigrateSecretConfig(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	_ = corev1alpha1.AddToScheme(r.Scheme)
+	ctx := context.Background()
+
+	dep := &corev1alpha1.Dependency{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm"},
+		Spec: corev1alpha1.DependencySpec{
+			Type: "cert-manager",
+			Config: map[string]string{
+				"tlsType":           "acme",
+				"challengeEmail":    "a@b.c",
+				"challengeProvider": "cloudflare",
+				"challengeSecret":   "api-key",
+			},
+		},
+	}
+	assert.Nil(t, r.Create(ctx, dep))
+
+	migrated, err := r.migrateSecretConfig(ctx, dep)
+	assert.Nil(t, err)
+	assert.True(t, migrated)
+
+	var fetched corev1alpha1.Dependency
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Name: "cm"}, &fetched))
+	assert.NotContains(t, fetched.Spec.Config, "challengeSecret")
+	assert.Equal(t, "a@b.c", fetched.Spec.Config["challengeEmail"])
+
+	config, err := r.resolveDependencyConfig(ctx, &fetched)
+	assert.Nil(t, err)
+	assert.Equal(t, "api-key", config["challengeSecret"])
+
+	// nothing to move any more
+	migrated, err = r.migrateSecretConfig(ctx, &fetched)
+	assert.Nil(t, err)
+	assert.False(t, migrated)
+}
+
+func TestMarkManifestsApplied(t *testing.T) {
+	r := newFakeDependencyReconciler()
+	ctx := context.Background()
+
+	driver, err := r.getDriver("kong")
+	assert.Nil(t, err)
+
+	applied, err := newAppliedManifests(driver, "1.0.0")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, applied.Hash)
+	assert.NotEmpty(t, applied.Objects)
+
+	again, err := newAppliedManifests(driver, "1.0.0")
+	assert.Nil(t, err)
+	assert.Equal(t, applied.Hash, again.Hash)
+
+	// an object which is removed from the manifests
+	removed := corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "kapp-kong", Name: "removed"}}
+	assert.Nil(t, r.Create(ctx, &removed))
+	// and a namespace, which is kept
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kapp-removed"}}
+	assert.Nil(t, r.Create(ctx, &ns))
+
+	dep := &corev1alpha1.Dependency{
+		Status: corev1alpha1.DependencyStatus{
+			AppliedManifests: &corev1alpha1.DependencyAppliedManifests{
+				Version: "1.0.0",
+				Hash:    "old",
+				Objects: append([]corev1alpha1.DependencyAppliedObject{
+					{APIVersion: "v1", Kind: "ConfigMap", Namespace: "kapp-kong", Name: "removed"},
+					{APIVersion: "v1", Kind: "Namespace", Name: "kapp-removed"},
+					// already deleted
+					{APIVersion: "v1", Kind: "Secret", Namespace: "kapp-kong", Name: "deleted"},
+				}, applied.Objects...),
+			},
+		},
+	}
+
+	assert.Nil(t, r.markManifestsApplied(ctx, dep, applied))
+	assert.Equal(t, applied, dep.Status.AppliedManifests)
+	assert.False(t, dep.Status.AppliedManifests.AppliedAt.IsZero())
+
+	assert.True(t, errors.IsNotFound(r.Get(ctx, types.NamespacedName{Namespace: "kapp-kong", Name: "removed"}, &removed)))
+	assert.Nil(t, r.Get(ctx, types.NamespacedName{Name: "kapp-removed"}, &ns))
+}
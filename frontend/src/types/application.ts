@@ -19,17 +19,55 @@ export type SharedEnv = ImmutableMap<{
   name: string;
   type: string;
   value: string;
+  prefix?: string;
+  suffix?: string;
+  // linked env only, hostPort | host | port | fqdn | fqdnPort
+  format?: string;
+  // go template for linked and external env, e.g. postgres://{{.Host}}:{{.Port}}/db
+  template?: string;
 }>;
 
 export type EnvItem = SharedEnv;
 export type EnvItems = Immutable.List<EnvItem>;
 
+export type ComponentOverlay = ImmutableMap<{
+  name: string;
+  replicas?: number;
+  imageTag?: string;
+  env?: Immutable.List<EnvItem>;
+  cpu?: string;
+  memory?: string;
+}>;
+
+export type ApplicationOverlay = ImmutableMap<{
+  name: string;
+  sharedEnv?: Immutable.List<SharedEnv>;
+  components?: Immutable.List<ComponentOverlay>;
+}>;
+
+// restarts | availability | memoryNearLimit | custom
+export type ApplicationAlert = ImmutableMap<{
+  name: string;
+  type: string;
+  component?: string;
+  threshold?: number;
+  window?: string;
+  expr?: string;
+  for?: string;
+  // warning | critical
+  severity?: string;
+  summary?: string;
+}>;
+
 export interface ApplicationContent {
   isActive: boolean;
   name: string;
   namespace: string;
   sharedEnvs: Immutable.List<SharedEnv>;
   components: Immutable.List<ApplicationComponent>;
+  overlays?: Immutable.List<ApplicationOverlay>;
+  overlay?: string;
+  alerts?: Immutable.List<ApplicationAlert>;
 }
 
 export interface ApplicationComponentContent extends ComponentLikeContent {}
@@ -105,6 +143,31 @@ export type Metrics = ImmutableMap<{
   memory: MetricList;
 }>;
 
+export type PrometheusTarget = ImmutableMap<{
+  component: string;
+  pod: string;
+  scrapeUrl: string;
+  health: string;
+  lastError?: string;
+  lastScrape: string;
+}>;
+
+export type PrometheusAlert = ImmutableMap<{
+  name: string;
+  component?: string;
+  severity: string;
+  summary?: string;
+  activeAt: string;
+  value: string;
+}>;
+
+export type ApplicationMonitoring = ImmutableMap<{
+  targetsUrl?: string;
+  dashboardUrl?: string;
+  targets: Immutable.List<PrometheusTarget>;
+  alerts: Immutable.List<PrometheusAlert>;
+}>;
+
 export type ApplicationDetails = ImmutableMap<{
   // application inline
   name: string;
@@ -113,11 +176,15 @@ export type ApplicationDetails = ImmutableMap<{
   isActive: boolean;
   sharedEnvs: Immutable.List<SharedEnv>;
   components: Immutable.List<ApplicationComponent>;
+  overlays?: Immutable.List<ApplicationOverlay>;
+  overlay?: string;
+  alerts?: Immutable.List<ApplicationAlert>;
 
   // addition fields
   componentsStatus: Immutable.List<ComponentStatus>;
   podNames: Immutable.List<string>;
   metrics: Metrics;
+  monitoring?: ApplicationMonitoring;
 }>;
 
 export type ApplicationDetailsList = Immutable.List<ApplicationDetails>;
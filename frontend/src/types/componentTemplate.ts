@@ -152,9 +152,36 @@ export interface ComponentLikeContent {
   ReadinessProbe?: Probe;
   nodeSelectorLabels?: NodeSelectorLabels;
   podAffinityType?: PodAffinityType;
+  metrics?: ComponentMetrics;
 }
 
-export interface ComponentTemplateContent extends ComponentLikeContent {}
+export type ComponentMetrics = ImmutableMap<{
+  port: string;
+  path?: string;
+  interval?: string;
+}>;
+
+export type ComponentTemplateParameterType = string;
+export const ComponentTemplateParameterTypeString: ComponentTemplateParameterType = "string";
+export const ComponentTemplateParameterTypeInteger: ComponentTemplateParameterType = "integer";
+export const ComponentTemplateParameterTypeBoolean: ComponentTemplateParameterType = "boolean";
+export const ComponentTemplateParameterTypeQuantity: ComponentTemplateParameterType = "quantity";
+
+// used to render the form of a template, values are referenced as ${name} in the template
+export type ComponentTemplateParameter = ImmutableMap<{
+  name: string;
+  type: ComponentTemplateParameterType;
+  default?: string;
+  required?: boolean;
+  description?: string;
+}>;
+
+export interface ComponentTemplateContent extends ComponentLikeContent {
+  version?: string;
+  deprecated?: boolean;
+  description?: string;
+  parameters?: Immutable.List<ComponentTemplateParameter>;
+}
 
 export type ComponentLike = ImmutableMap<ComponentLikeContent>;
 export type ComponentTemplate = ImmutableMap<ComponentTemplateContent>;
@@ -9,10 +9,38 @@ export enum KappDependencyStatus {
   InstallFailed,
   Installing,
   Uninstalling,
-  Running
+  Running,
+  Upgrading,
+  UpgradeFailed
 }
 
-export const KappDependencyStatusText = ["Not Installed", "Install Failed", "Installing", "Uninstalling", "Running"];
+export const KappDependencyStatusText = [
+  "Not Installed",
+  "Install Failed",
+  "Installing",
+  "Uninstalling",
+  "Running",
+  "Upgrading",
+  "Upgrade Failed"
+];
+
+export interface DependencyCondition {
+  type: "Installed" | "UpToDate" | "Configured" | "Ready";
+  status: "True" | "False" | "Unknown";
+  reason?: string;
+  message?: string;
+  lastTransitionTime: string;
+}
+
+// a deployment or statefulset installed by the dependency
+export interface DependencyComponentStatus {
+  kind: string;
+  namespace: string;
+  name: string;
+  ready: number;
+  desired: number;
+  lastError?: string;
+}
 
 export interface KappDependencyContent {
   name: string;
@@ -23,11 +51,45 @@ export interface KappDependencyContent {
   provider: string;
   status: KappDependencyStatus;
   statusText?: string;
+  installedVersion?: string;
+  observedGeneration?: number;
+  lastTransitionTime?: string;
+  conditions?: DependencyCondition[];
+  components?: DependencyComponentStatus[];
   projectHomepageLink: string;
+  keepData?: boolean;
+  config?: { [key: string]: string };
+  secretRefs?: { [key: string]: DependencySecretKeyRef };
 }
 
 export type KappDependency = ImmutableMap<KappDependencyContent>;
 
+export interface DependencyConfigField {
+  name: string;
+  type: string;
+  description?: string;
+  required?: boolean;
+  default?: string;
+  // allowed values
+  enum?: string[];
+  // the value must be referenced from a Secret in secretRefs
+  secret?: boolean;
+}
+
+export interface DependencySecretKeyRef {
+  namespace: string;
+  name: string;
+  key: string;
+}
+
+// returned by /v1/dependencies/available
+export interface AvailableDependency {
+  type: string;
+  description: string;
+  versions: string[];
+  configSchema: DependencyConfigField[];
+}
+
 export interface LoadDependenciesPendingAction {
   type: typeof LOAD_DEPENDENCIES_PENDING;
 }